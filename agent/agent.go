@@ -0,0 +1,357 @@
+// Package agent keeps passphrases in a per-user daemon, like ssh-agent, so scripts running spt many times only ask
+// once. The agent listens on a unix socket in a directory only its owner can access; clients find it through the
+// SPT_AGENT_SOCK environment variable. Each connection carries one JSON request and one JSON response.
+//
+// The passphrases are kept in locked memory and removed after their time to live. The agent can be locked with a
+// password, which withholds the passphrases until it is unlocked again. Every file has its own salt, so the agent
+// saves the prompts, not the key derivation: each file still runs the KeyGen once.
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	SocketEnv = "SPT_AGENT_SOCK"
+
+	maxRequestSize = 64 * 1024
+	requestTimeout = 10 * time.Second
+)
+
+var (
+	ErrNotFound        = errors.New("no such passphrase in the agent")
+	ErrLocked          = errors.New("agent is locked")
+	ErrNotLocked       = errors.New("agent is not locked")
+	ErrWrongPassword   = errors.New("wrong agent lock password")
+	ErrInvalidRequest  = errors.New("invalid agent request")
+	ErrNoAgent         = errors.New(SocketEnv + " is not set")
+	ErrInsecureSockDir = errors.New("agent socket directory is accessible by other users")
+	ErrInsecureSocket  = errors.New("agent socket isn't owned by the current user")
+)
+
+// errs maps the error messages of responses back to the errors above.
+var errs = []error{ErrNotFound, ErrLocked, ErrNotLocked, ErrWrongPassword, ErrInvalidRequest}
+
+const (
+	opAdd    = "add"
+	opGet    = "get"
+	opList   = "list"
+	opRemove = "remove"
+	opClear  = "clear"
+	opLock   = "lock"
+	opUnlock = "unlock"
+)
+
+type request struct {
+	Op         string        `json:"op"`
+	Name       string        `json:"name,omitempty"`
+	Passphrase string        `json:"passphrase,omitempty"`
+	TTL        time.Duration `json:"ttl,omitempty"`
+}
+
+type response struct {
+	Error      string  `json:"error,omitempty"`
+	Passphrase string  `json:"passphrase,omitempty"`
+	Entries    []Entry `json:"entries,omitempty"`
+}
+
+// Entry describes a passphrase held by the agent. Expires is zero for passphrases without a time to live.
+type Entry struct {
+	Name    string    `json:"name"`
+	Expires time.Time `json:"expires,omitempty"`
+}
+
+type entry struct {
+	Entry
+	passphrase *secret
+	timer      *time.Timer
+}
+
+// Agent holds the passphrases. It is safe for concurrent use.
+type Agent struct {
+	mu      sync.Mutex
+	entries map[string]*entry
+	lock    *secret
+}
+
+func New() *Agent {
+	return &Agent{
+		entries: make(map[string]*entry),
+	}
+}
+
+// DefaultSocket returns the socket path used when SPT_AGENT_SOCK isn't set for the agent: spt-agent.sock in
+// $XDG_RUNTIME_DIR, or in a per-user directory in the temporary directory.
+func DefaultSocket() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "spt-agent.sock")
+	}
+
+	return filepath.Join(os.TempDir(), fmt.Sprintf("spt-agent-%d", os.Getuid()), "agent.sock")
+}
+
+// Listen creates the socket at path, and its directory if missing. The directory must be a real directory owned by
+// the current user and not accessible by other users, so nobody else can replace the socket; a stale socket is
+// replaced.
+func Listen(path string) (l net.Listener, err error) {
+	var (
+		fi os.FileInfo
+	)
+
+	dir := filepath.Dir(path)
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return
+	}
+	if err = checkSockDir(dir); err != nil {
+		return
+	}
+
+	if fi, err = os.Lstat(path); err == nil && fi.Mode()&fs.ModeSocket != 0 {
+		if err = os.Remove(path); err != nil {
+			return
+		}
+	}
+
+	if l, err = net.Listen("unix", path); err != nil {
+		return
+	}
+
+	if err = os.Chmod(path, 0600); err != nil {
+		_ = l.Close()
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// checkSockDir verifies that dir is a directory, not a symlink, owned by the current user and only accessible by them.
+func checkSockDir(dir string) error {
+	fi, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+
+	if !fi.IsDir() || fi.Mode().Perm()&0077 != 0 || !ownedByUser(fi) {
+		return ErrInsecureSockDir
+	}
+
+	return nil
+}
+
+// Serve answers requests on l until l is closed. It keeps the process from writing core dumps first, where the
+// platform allows it.
+func (a *Agent) Serve(l net.Listener) error {
+	if err := harden(); err != nil {
+		return fmt.Errorf("protecting the agent process: %w", err)
+	}
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+
+		go a.serveConn(conn)
+	}
+}
+
+func (a *Agent) serveConn(conn net.Conn) {
+	var (
+		req  request
+		resp response
+	)
+
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	_ = conn.SetDeadline(time.Now().Add(requestTimeout))
+	dec := json.NewDecoder(&limitedConn{conn: conn, n: maxRequestSize})
+	if err := dec.Decode(&req); err != nil {
+		resp.Error = ErrInvalidRequest.Error()
+	} else {
+		resp = a.handle(req)
+	}
+
+	_ = json.NewEncoder(conn).Encode(resp)
+}
+
+// limitedConn fails reads after n bytes, so a client can't make the agent buffer an endless request.
+type limitedConn struct {
+	conn net.Conn
+	n    int
+}
+
+func (l *limitedConn) Read(b []byte) (n int, err error) {
+	if l.n <= 0 {
+		return 0, ErrInvalidRequest
+	}
+
+	n, err = l.conn.Read(b[:min(len(b), l.n)])
+	l.n -= n
+	return
+}
+
+func (a *Agent) handle(req request) (resp response) {
+	var (
+		err error
+	)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	switch req.Op {
+	case opAdd:
+		err = a.add(req.Name, req.Passphrase, req.TTL)
+	case opGet:
+		resp.Passphrase, err = a.get(req.Name)
+	case opList:
+		resp.Entries, err = a.list()
+	case opRemove:
+		err = a.remove(req.Name)
+	case opClear:
+		a.clear()
+	case opLock:
+		err = a.setLock(req.Passphrase)
+	case opUnlock:
+		err = a.unlock(req.Passphrase)
+	default:
+		err = ErrInvalidRequest
+	}
+
+	if err != nil {
+		resp = response{Error: err.Error()}
+	}
+
+	return
+}
+
+func (a *Agent) add(name, passphrase string, ttl time.Duration) (err error) {
+	if a.lock != nil {
+		return ErrLocked
+	}
+
+	if name == "" || ttl < 0 {
+		return ErrInvalidRequest
+	}
+
+	e := &entry{
+		Entry: Entry{Name: name},
+	}
+	if e.passphrase, err = newSecret(passphrase); err != nil {
+		return
+	}
+
+	if ttl > 0 {
+		e.Expires = time.Now().Add(ttl).Truncate(time.Second)
+		e.timer = time.AfterFunc(ttl, func() {
+			a.mu.Lock()
+			defer a.mu.Unlock()
+
+			if a.entries[name] == e {
+				a.delete(name)
+			}
+		})
+	}
+
+	if a.entries[name] != nil {
+		a.delete(name)
+	}
+	a.entries[name] = e
+
+	return nil
+}
+
+func (a *Agent) get(name string) (string, error) {
+	if a.lock != nil {
+		return "", ErrLocked
+	}
+
+	e, ok := a.entries[name]
+	if !ok {
+		return "", ErrNotFound
+	}
+
+	return e.passphrase.String(), nil
+}
+
+func (a *Agent) list() ([]Entry, error) {
+	if a.lock != nil {
+		return nil, ErrLocked
+	}
+
+	entries := make([]Entry, 0, len(a.entries))
+	for _, e := range a.entries {
+		entries = append(entries, e.Entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+
+	return entries, nil
+}
+
+func (a *Agent) remove(name string) error {
+	if _, ok := a.entries[name]; !ok {
+		return ErrNotFound
+	}
+
+	a.delete(name)
+	return nil
+}
+
+// delete requires mu to be held.
+func (a *Agent) delete(name string) {
+	e := a.entries[name]
+	if e.timer != nil {
+		e.timer.Stop()
+	}
+	e.passphrase.destroy()
+	delete(a.entries, name)
+}
+
+func (a *Agent) clear() {
+	for name := range a.entries {
+		a.delete(name)
+	}
+}
+
+func (a *Agent) setLock(password string) (err error) {
+	if a.lock != nil {
+		return ErrLocked
+	}
+
+	if password == "" {
+		return ErrInvalidRequest
+	}
+
+	a.lock, err = newSecret(password)
+	return
+}
+
+func (a *Agent) unlock(password string) error {
+	if a.lock == nil {
+		return ErrNotLocked
+	}
+
+	if !a.lock.equal(password) {
+		return ErrWrongPassword
+	}
+
+	a.lock.destroy()
+	a.lock = nil
+
+	return nil
+}
@@ -0,0 +1,201 @@
+package agent
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func startAgent(t *testing.T) *Client {
+	path := filepath.Join(t.TempDir(), "agent", "agent.sock")
+	l, err := Listen(path)
+	if err != nil {
+		t.Fatal("unexpected: Listen failed", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- New().Serve(l)
+	}()
+	t.Cleanup(func() {
+		_ = l.Close()
+		if err := <-done; err != nil {
+			t.Error("unexpected: Serve failed", err)
+		}
+	})
+
+	return NewClient(path)
+}
+
+func TestAgent(t *testing.T) {
+	c := startAgent(t)
+
+	if _, err := c.Get("default"); err != ErrNotFound {
+		t.Fatal("unexpected: it should return ErrNotFound, got", err)
+	}
+
+	if err := c.Add("default", "some passphrase", 0); err != nil {
+		t.Fatal("unexpected: Add failed", err)
+	}
+	if err := c.Add("backup", "other passphrase", time.Hour); err != nil {
+		t.Fatal("unexpected: Add failed", err)
+	}
+	if err := c.Add("", "x", 0); err != ErrInvalidRequest {
+		t.Fatal("unexpected: it should return ErrInvalidRequest, got", err)
+	}
+
+	if p, err := c.Get("default"); err != nil || p != "some passphrase" {
+		t.Fatal("unexpected: Get", p, err)
+	}
+
+	entries, err := c.List()
+	if err != nil || len(entries) != 2 || entries[0].Name != "backup" || entries[1].Name != "default" ||
+		entries[0].Expires.Before(time.Now().Add(59*time.Minute)) || !entries[1].Expires.IsZero() {
+		t.Fatal("unexpected: List", entries, err)
+	}
+
+	if err = c.Lock("lock password"); err != nil {
+		t.Fatal("unexpected: Lock failed", err)
+	}
+	if _, err = c.Get("default"); err != ErrLocked {
+		t.Fatal("unexpected: it should return ErrLocked, got", err)
+	}
+	if _, err = c.List(); err != ErrLocked {
+		t.Fatal("unexpected: it should return ErrLocked, got", err)
+	}
+	if err = c.Unlock("wrong"); err != ErrWrongPassword {
+		t.Fatal("unexpected: it should return ErrWrongPassword, got", err)
+	}
+	if err = c.Unlock("lock password"); err != nil {
+		t.Fatal("unexpected: Unlock failed", err)
+	}
+	if err = c.Unlock("lock password"); err != ErrNotLocked {
+		t.Fatal("unexpected: it should return ErrNotLocked, got", err)
+	}
+
+	if err = c.Remove("backup"); err != nil {
+		t.Fatal("unexpected: Remove failed", err)
+	}
+	if err = c.Clear(); err != nil {
+		t.Fatal("unexpected: Clear failed", err)
+	}
+	if entries, err = c.List(); err != nil || len(entries) != 0 {
+		t.Fatal("unexpected: List after Clear", entries, err)
+	}
+}
+
+func TestTTL(t *testing.T) {
+	c := startAgent(t)
+
+	if err := c.Add("default", "some passphrase", 20*time.Millisecond); err != nil {
+		t.Fatal("unexpected: Add failed", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := c.Get("default"); err == ErrNotFound {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("unexpected: the passphrase didn't expire")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestInvalidRequest(t *testing.T) {
+	c := startAgent(t)
+
+	if _, err := c.call(request{Op: "dump"}); err != ErrInvalidRequest {
+		t.Fatal("unexpected: it should return ErrInvalidRequest, got", err)
+	}
+
+	conn, err := net.Dial("unix", c.path)
+	if err != nil {
+		t.Fatal("unexpected: Dial failed", err)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	// an endless request is cut off
+	go func() {
+		b := make([]byte, 4096)
+		b[0] = '"'
+		for {
+			if _, err := conn.Write(b); err != nil {
+				return
+			}
+			b[0] = 'x'
+		}
+	}()
+	var resp response
+	if err = json.NewDecoder(conn).Decode(&resp); err != nil || resp.Error != ErrInvalidRequest.Error() {
+		t.Fatal("unexpected: response", resp, err)
+	}
+}
+
+func TestListen(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Chmod(dir, 0755); err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+	if _, err := Listen(filepath.Join(dir, "agent.sock")); err != ErrInsecureSockDir {
+		t.Fatal("unexpected: it should return ErrInsecureSockDir, got", err)
+	}
+
+	// like /tmp, a sticky world-writable directory lets others replace the socket with their own
+	if err := os.Chmod(dir, 0777|os.ModeSticky); err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+	if _, err := Listen(filepath.Join(dir, "agent.sock")); err != ErrInsecureSockDir {
+		t.Fatal("unexpected: it should return ErrInsecureSockDir for a sticky directory, got", err)
+	}
+
+	private := t.TempDir()
+	if err := os.Symlink(private, filepath.Join(dir, "link")); err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+	if _, err := Listen(filepath.Join(dir, "link", "agent.sock")); err != ErrInsecureSockDir {
+		t.Fatal("unexpected: it should return ErrInsecureSockDir for a symlink, got", err)
+	}
+}
+
+func TestClientCheck(t *testing.T) {
+	c := startAgent(t)
+
+	link := filepath.Join(filepath.Dir(c.path), "link.sock")
+	if err := os.Symlink(c.path, link); err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+	if _, err := NewClient(link).List(); err != ErrInsecureSocket {
+		t.Fatal("unexpected: it should return ErrInsecureSocket for a symlink, got", err)
+	}
+
+	plain := filepath.Join(filepath.Dir(c.path), "plain")
+	if err := os.WriteFile(plain, nil, 0600); err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+	if _, err := NewClient(plain).List(); err != ErrInsecureSocket {
+		t.Fatal("unexpected: it should return ErrInsecureSocket for a regular file, got", err)
+	}
+}
+
+func TestSecret(t *testing.T) {
+	s, err := newSecret("some passphrase")
+	if err != nil {
+		t.Fatal("unexpected: newSecret failed", err)
+	}
+
+	if s.String() != "some passphrase" || !s.equal("some passphrase") || s.equal("some") {
+		t.Fatal("unexpected: secret content")
+	}
+
+	s.destroy()
+	s.destroy()
+	if s.b != nil {
+		t.Fatal("unexpected: destroy should release the memory")
+	}
+}
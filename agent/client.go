@@ -0,0 +1,127 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Client talks to an agent over its socket.
+type Client struct {
+	path string
+}
+
+func NewClient(path string) *Client {
+	return &Client{path: path}
+}
+
+// FromEnv returns a Client for the agent in SPT_AGENT_SOCK, or ErrNoAgent if it isn't set.
+func FromEnv() (*Client, error) {
+	path := os.Getenv(SocketEnv)
+	if path == "" {
+		return nil, ErrNoAgent
+	}
+
+	return NewClient(path), nil
+}
+
+func (c *Client) call(req request) (resp response, err error) {
+	var (
+		conn net.Conn
+	)
+
+	if err = checkSocket(c.path); err != nil {
+		return
+	}
+
+	if conn, err = net.DialTimeout("unix", c.path, requestTimeout); err != nil {
+		return
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	_ = conn.SetDeadline(time.Now().Add(requestTimeout))
+	if err = json.NewEncoder(conn).Encode(req); err != nil {
+		return
+	}
+
+	if err = json.NewDecoder(conn).Decode(&resp); err != nil {
+		return
+	}
+
+	if resp.Error != "" {
+		for _, e := range errs {
+			if e.Error() == resp.Error {
+				return resp, e
+			}
+		}
+		return resp, errors.New(resp.Error)
+	}
+
+	return
+}
+
+// checkSocket refuses a socket, or a socket directory, which isn't owned by the current user: whoever owns them could
+// read the passphrases sent to the agent and answer with their own.
+func checkSocket(path string) error {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if fi.Mode()&fs.ModeSocket == 0 || !ownedByUser(fi) {
+		return ErrInsecureSocket
+	}
+
+	if fi, err = os.Lstat(filepath.Dir(path)); err != nil {
+		return err
+	}
+	if !fi.IsDir() || !ownedByUser(fi) {
+		return ErrInsecureSockDir
+	}
+
+	return nil
+}
+
+// Add stores the passphrase under name, replacing an existing one. A zero ttl keeps it until it is removed.
+func (c *Client) Add(name, passphrase string, ttl time.Duration) error {
+	_, err := c.call(request{Op: opAdd, Name: name, Passphrase: passphrase, TTL: ttl})
+	return err
+}
+
+func (c *Client) Get(name string) (string, error) {
+	resp, err := c.call(request{Op: opGet, Name: name})
+	return resp.Passphrase, err
+}
+
+// List returns the entries sorted by name.
+func (c *Client) List() ([]Entry, error) {
+	resp, err := c.call(request{Op: opList})
+	return resp.Entries, err
+}
+
+func (c *Client) Remove(name string) error {
+	_, err := c.call(request{Op: opRemove, Name: name})
+	return err
+}
+
+// Clear removes all passphrases. It works on a locked agent as well.
+func (c *Client) Clear() error {
+	_, err := c.call(request{Op: opClear})
+	return err
+}
+
+// Lock withholds the passphrases until Unlock is called with the same password.
+func (c *Client) Lock(password string) error {
+	_, err := c.call(request{Op: opLock, Passphrase: password})
+	return err
+}
+
+func (c *Client) Unlock(password string) error {
+	_, err := c.call(request{Op: opUnlock, Passphrase: password})
+	return err
+}
@@ -0,0 +1,15 @@
+package agent
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// harden keeps the passphrases out of core dumps, and keeps other processes of the same user from attaching to the
+// agent with ptrace.
+func harden() error {
+	if err := unix.Setrlimit(unix.RLIMIT_CORE, &unix.Rlimit{}); err != nil {
+		return err
+	}
+
+	return unix.Prctl(unix.PR_SET_DUMPABLE, 0, 0, 0, 0)
+}
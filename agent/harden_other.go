@@ -0,0 +1,7 @@
+//go:build !linux
+
+package agent
+
+func harden() error {
+	return nil
+}
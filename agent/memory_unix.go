@@ -0,0 +1,34 @@
+//go:build linux || darwin
+
+package agent
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// allocLocked maps n bytes, rounded up to whole pages, and locks them in memory.
+func allocLocked(n int) ([]byte, error) {
+	page := os.Getpagesize()
+	size := max((n+page-1)/page, 1) * page
+
+	b, err := unix.Mmap(-1, 0, size, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_ANON|unix.MAP_PRIVATE)
+	if err != nil {
+		return nil, fmt.Errorf("allocating secret memory: %w", err)
+	}
+
+	if err = unix.Mlock(b); err != nil {
+		_ = unix.Munmap(b)
+		return nil, fmt.Errorf("locking secret memory: %w", err)
+	}
+
+	return b[:n], nil
+}
+
+func freeLocked(b []byte) {
+	b = b[:cap(b)]
+	_ = unix.Munlock(b)
+	_ = unix.Munmap(b)
+}
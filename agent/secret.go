@@ -0,0 +1,40 @@
+package agent
+
+import (
+	"crypto/subtle"
+)
+
+// secret holds a passphrase in memory mapped outside the Go heap and locked against swapping, where the platform
+// allows it, so the runtime doesn't copy it around and it can be wiped reliably.
+type secret struct {
+	b []byte
+}
+
+func newSecret(s string) (*secret, error) {
+	b, err := allocLocked(len(s))
+	if err != nil {
+		return nil, err
+	}
+	copy(b, s)
+
+	return &secret{b: b}, nil
+}
+
+func (s *secret) String() string {
+	return string(s.b)
+}
+
+func (s *secret) equal(other string) bool {
+	return subtle.ConstantTimeCompare(s.b, []byte(other)) == 1
+}
+
+// destroy wipes and releases the memory. The secret can't be used afterwards.
+func (s *secret) destroy() {
+	if s.b == nil {
+		return
+	}
+
+	clear(s.b[:cap(s.b)])
+	freeLocked(s.b)
+	s.b = nil
+}
@@ -0,0 +1,310 @@
+// Package archive exposes the content of a tar archive stored in an io.ReaderAt as a read-only fs.FS. Combined with
+// privacy.ReaderAt, an encrypted archive created by
+//
+//	tar -cf - dir | simple-privacy-tool encrypt - archive.spt
+//
+// can be browsed without extracting it: only the segments holding tar headers are decrypted while indexing, and
+// file data is decrypted on demand. The archive must not be compressed, neither by tar nor by the encryption.
+package archive
+
+import (
+	"archive/tar"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidName = errors.New("invalid entry name")
+)
+
+type node struct {
+	name     string
+	header   *tar.Header
+	offset   int64
+	size     int64
+	children map[string]*node
+	sorted   []*node
+}
+
+func (n *node) isDir() bool {
+	return n.children != nil
+}
+
+// FS is a read-only fs.FS over a tar archive. Only regular files and directories are exposed; links and special
+// files are skipped. Directories missing from the archive are synthesized. Files opened from an FS implement
+// io.ReaderAt and io.Seeker.
+type FS struct {
+	src  io.ReaderAt
+	root *node
+}
+
+// New indexes the tar archive of the given size in src.
+func New(src io.ReaderAt, size int64) (fsys *FS, err error) {
+	var (
+		hdr    *tar.Header
+		offset int64
+	)
+
+	fsys = &FS{
+		src:  src,
+		root: newDir("."),
+	}
+
+	sr := io.NewSectionReader(src, 0, size)
+	tr := tar.NewReader(sr)
+	for {
+		if hdr, err = tr.Next(); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+
+		if offset, err = sr.Seek(0, io.SeekCurrent); err != nil {
+			return nil, err
+		}
+
+		if err = fsys.add(hdr, offset); err != nil {
+			return nil, err
+		}
+	}
+
+	fsys.root.sort()
+	return fsys, nil
+}
+
+func newDir(name string) *node {
+	return &node{
+		name:     name,
+		children: make(map[string]*node),
+	}
+}
+
+func (f *FS) add(hdr *tar.Header, offset int64) error {
+	switch hdr.Typeflag {
+	case tar.TypeReg, tar.TypeDir:
+	default:
+		return nil
+	}
+
+	name := path.Clean(strings.TrimPrefix(hdr.Name, "/"))
+	if name == "." {
+		if hdr.Typeflag == tar.TypeDir {
+			f.root.header = hdr
+		}
+		return nil
+	}
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "index", Path: hdr.Name, Err: ErrInvalidName}
+	}
+
+	parent := f.root
+	elems := strings.Split(name, "/")
+	for _, elem := range elems[:len(elems)-1] {
+		child, ok := parent.children[elem]
+		if !ok || !child.isDir() {
+			child = newDir(elem)
+			parent.children[elem] = child
+		}
+		parent = child
+	}
+
+	base := elems[len(elems)-1]
+	if hdr.Typeflag == tar.TypeDir {
+		if child, ok := parent.children[base]; ok && child.isDir() {
+			child.header = hdr
+			return nil
+		}
+		child := newDir(base)
+		child.header = hdr
+		parent.children[base] = child
+		return nil
+	}
+
+	parent.children[base] = &node{
+		name:   base,
+		header: hdr,
+		offset: offset,
+		size:   hdr.Size,
+	}
+
+	return nil
+}
+
+func (n *node) sort() {
+	n.sorted = make([]*node, 0, len(n.children))
+	for _, child := range n.children {
+		n.sorted = append(n.sorted, child)
+		if child.isDir() {
+			child.sort()
+		}
+	}
+
+	sort.Slice(n.sorted, func(i, j int) bool {
+		return n.sorted[i].name < n.sorted[j].name
+	})
+}
+
+func (f *FS) lookup(op, name string) (*node, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	n := f.root
+	if name == "." {
+		return n, nil
+	}
+
+	for _, elem := range strings.Split(name, "/") {
+		child, ok := n.children[elem]
+		if !ok {
+			return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+		n = child
+	}
+
+	return n, nil
+}
+
+func (f *FS) Open(name string) (fs.File, error) {
+	n, err := f.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	if n.isDir() {
+		return &dir{node: n, path: name}, nil
+	}
+
+	return &file{
+		SectionReader: io.NewSectionReader(f.src, n.offset, n.size),
+		node:          n,
+	}, nil
+}
+
+func (f *FS) Stat(name string) (fs.FileInfo, error) {
+	n, err := f.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+
+	return fileInfo{n}, nil
+}
+
+func (f *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	n, err := f.lookup("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+
+	if !n.isDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+
+	entries := make([]fs.DirEntry, len(n.sorted))
+	for i, child := range n.sorted {
+		entries[i] = fs.FileInfoToDirEntry(fileInfo{child})
+	}
+
+	return entries, nil
+}
+
+type fileInfo struct {
+	*node
+}
+
+func (fi fileInfo) Name() string {
+	return fi.name
+}
+
+func (fi fileInfo) Size() int64 {
+	return fi.size
+}
+
+func (fi fileInfo) Mode() fs.FileMode {
+	var mode fs.FileMode = 0644
+	if fi.isDir() {
+		mode = 0755
+	}
+
+	if fi.header != nil {
+		mode = fi.header.FileInfo().Mode().Perm()
+	}
+
+	if fi.isDir() {
+		mode |= fs.ModeDir
+	}
+
+	return mode
+}
+
+func (fi fileInfo) ModTime() time.Time {
+	if fi.header != nil {
+		return fi.header.ModTime
+	}
+
+	return time.Time{}
+}
+
+func (fi fileInfo) IsDir() bool {
+	return fi.isDir()
+}
+
+func (fi fileInfo) Sys() any {
+	return fi.header
+}
+
+type file struct {
+	*io.SectionReader
+	node *node
+}
+
+func (f *file) Stat() (fs.FileInfo, error) {
+	return fileInfo{f.node}, nil
+}
+
+func (f *file) Close() error {
+	return nil
+}
+
+type dir struct {
+	node *node
+	path string
+	off  int
+}
+
+func (d *dir) Stat() (fs.FileInfo, error) {
+	return fileInfo{d.node}, nil
+}
+
+func (d *dir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.path, Err: errors.New("is a directory")}
+}
+
+func (d *dir) Close() error {
+	return nil
+}
+
+func (d *dir) ReadDir(count int) ([]fs.DirEntry, error) {
+	remaining := d.node.sorted[d.off:]
+	if count > 0 && len(remaining) == 0 {
+		return nil, io.EOF
+	}
+
+	if count > 0 && len(remaining) > count {
+		remaining = remaining[:count]
+	}
+
+	entries := make([]fs.DirEntry, len(remaining))
+	for i, child := range remaining {
+		entries[i] = fs.FileInfoToDirEntry(fileInfo{child})
+	}
+	d.off += len(remaining)
+
+	return entries, nil
+}
@@ -0,0 +1,108 @@
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"gitea.suyono.dev/suyono/simple-privacy-tool/privacy"
+)
+
+func makeTar(t *testing.T) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	mtime := time.Date(2023, 7, 22, 10, 0, 0, 0, time.UTC)
+	entries := []struct {
+		hdr  tar.Header
+		data string
+	}{
+		{hdr: tar.Header{Name: "docs/", Typeflag: tar.TypeDir, Mode: 0750}},
+		{hdr: tar.Header{Name: "docs/readme.txt", Typeflag: tar.TypeReg, Mode: 0640}, data: "read me\n"},
+		{hdr: tar.Header{Name: "./src/main.go", Typeflag: tar.TypeReg, Mode: 0644}, data: "package main\n"},
+		{hdr: tar.Header{Name: "src/empty", Typeflag: tar.TypeReg, Mode: 0600}},
+		{hdr: tar.Header{Name: "big.bin", Typeflag: tar.TypeReg, Mode: 0644}, data: string(bytes.Repeat([]byte("0123456789"), 1000))},
+		{hdr: tar.Header{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "big.bin"}},
+	}
+	for _, e := range entries {
+		e.hdr.Size = int64(len(e.data))
+		e.hdr.ModTime = mtime
+		if err := tw.WriteHeader(&e.hdr); err != nil {
+			t.Fatal("test preparation failure:", err)
+		}
+		if _, err := io.WriteString(tw, e.data); err != nil {
+			t.Fatal("test preparation failure:", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	return buf.Bytes()
+}
+
+func checkFS(t *testing.T, fsys *FS) {
+	t.Helper()
+
+	if err := fstest.TestFS(fsys, "docs/readme.txt", "src/main.go", "src/empty", "big.bin"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fsys.Open("link"); err == nil {
+		t.Fatal("unexpected: symlinks should be skipped")
+	}
+
+	b, err := fs.ReadFile(fsys, "big.bin")
+	if err != nil || !bytes.Equal(b, bytes.Repeat([]byte("0123456789"), 1000)) {
+		t.Fatal("unexpected: mismatch content", err)
+	}
+
+	fi, err := fs.Stat(fsys, "docs")
+	if err != nil || fi.Mode() != fs.ModeDir|0750 {
+		t.Fatal("unexpected: docs mode", fi.Mode(), err)
+	}
+}
+
+func TestFS(t *testing.T) {
+	b := makeTar(t)
+	fsys, err := New(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal("unexpected: New failed", err)
+	}
+
+	checkFS(t, fsys)
+}
+
+func TestFS_Encrypted(t *testing.T) {
+	keygen, err := privacy.NewArgon2WithParams(1, 4*1024, 2)
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	b, err := privacy.EncryptBytes(makeTar(t), privacy.WithPassphrase("some passphrase"), privacy.WithKeyGen(keygen),
+		privacy.WithSegmentSize(1000))
+	if err != nil {
+		t.Fatal("unexpected: EncryptBytes failed", err)
+	}
+
+	ra := privacy.NewPrivacyReaderAt(bytes.NewReader(b), int64(len(b)), keygen)
+	ra.SetSegmentSize(1000)
+	if err = ra.ReadMagic(); err != nil {
+		t.Fatal("unexpected: ReadMagic failed", err)
+	}
+	if err = ra.GenerateKey("some passphrase"); err != nil {
+		t.Fatal("unexpected: GenerateKey failed", err)
+	}
+
+	fsys, err := New(ra, ra.Size())
+	if err != nil {
+		t.Fatal("unexpected: New failed", err)
+	}
+
+	checkFS(t, fsys)
+}
@@ -0,0 +1,194 @@
+//go:build linux
+
+// Package fusefs serves a read-only fs.FS, e.g. an archive.FS, as a FUSE file system.
+package fusefs
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"path"
+	"syscall"
+	"time"
+
+	gofs "github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+type root struct {
+	dirNode
+	fsys fs.FS
+	err  error
+}
+
+var _ = (gofs.NodeOnAdder)((*root)(nil))
+
+// OnAdd builds the whole tree up front; the archive index is already in memory.
+func (r *root) OnAdd(ctx context.Context) {
+	r.err = fs.WalkDir(r.fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || name == "." {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		// WalkDir visits a directory before its content, so the parent always exists
+		parent := &r.Inode
+		for _, elem := range splitPath(path.Dir(name)) {
+			parent = parent.GetChild(elem)
+		}
+
+		if d.IsDir() {
+			child := parent.NewPersistentInode(ctx, &dirNode{info: info}, gofs.StableAttr{Mode: fuse.S_IFDIR})
+			parent.AddChild(info.Name(), child, true)
+			return nil
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		child := parent.NewPersistentInode(ctx, &fileNode{fsys: r.fsys, name: name, info: info}, gofs.StableAttr{})
+		parent.AddChild(info.Name(), child, true)
+		return nil
+	})
+}
+
+func splitPath(name string) (elems []string) {
+	for name != "." {
+		elems = append([]string{path.Base(name)}, elems...)
+		name = path.Dir(name)
+	}
+
+	return
+}
+
+type dirNode struct {
+	gofs.Inode
+	info fs.FileInfo
+}
+
+var _ = (gofs.NodeGetattrer)((*dirNode)(nil))
+
+func (d *dirNode) Getattr(ctx context.Context, f gofs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = fuse.S_IFDIR | 0755
+	if d.info != nil {
+		out.Mode = fuse.S_IFDIR | uint32(d.info.Mode().Perm())
+		setTimes(out, d.info)
+	}
+
+	return 0
+}
+
+type fileNode struct {
+	gofs.Inode
+	fsys fs.FS
+	name string
+	info fs.FileInfo
+}
+
+var _ = (gofs.NodeGetattrer)((*fileNode)(nil))
+var _ = (gofs.NodeOpener)((*fileNode)(nil))
+var _ = (gofs.NodeReader)((*fileNode)(nil))
+var _ = (gofs.NodeReleaser)((*fileNode)(nil))
+
+func (n *fileNode) Getattr(ctx context.Context, f gofs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	const bs = 512
+
+	out.Mode = fuse.S_IFREG | uint32(n.info.Mode().Perm())
+	out.Nlink = 1
+	out.Size = uint64(n.info.Size())
+	out.Blksize = bs
+	out.Blocks = (out.Size + bs - 1) / bs
+	setTimes(out, n.info)
+
+	return 0
+}
+
+func setTimes(out *fuse.AttrOut, info fs.FileInfo) {
+	out.Mtime = uint64(info.ModTime().Unix())
+	out.Atime = out.Mtime
+	out.Ctime = out.Mtime
+}
+
+type fileHandle struct {
+	f  fs.File
+	ra io.ReaderAt
+}
+
+// Open opens the file in the underlying fs.FS. Its files have to implement io.ReaderAt.
+func (n *fileNode) Open(ctx context.Context, flags uint32) (gofs.FileHandle, uint32, syscall.Errno) {
+	if flags&(syscall.O_WRONLY|syscall.O_RDWR) != 0 {
+		return nil, 0, syscall.EROFS
+	}
+
+	f, err := n.fsys.Open(n.name)
+	if err != nil {
+		return nil, 0, syscall.ENOENT
+	}
+
+	ra, ok := f.(io.ReaderAt)
+	if !ok {
+		_ = f.Close()
+		return nil, 0, syscall.ENOTSUP
+	}
+
+	// the content never changes, the kernel may cache it
+	return &fileHandle{f: f, ra: ra}, fuse.FOPEN_KEEP_CACHE, 0
+}
+
+func (n *fileNode) Read(ctx context.Context, f gofs.FileHandle, dest []byte, off int64) (fuse.ReadResult, syscall.Errno) {
+	fh, ok := f.(*fileHandle)
+	if !ok {
+		return nil, syscall.EBADF
+	}
+
+	c, err := fh.ra.ReadAt(dest, off)
+	if err != nil && err != io.EOF {
+		return nil, syscall.EIO
+	}
+
+	return fuse.ReadResultData(dest[:c]), 0
+}
+
+func (n *fileNode) Release(ctx context.Context, f gofs.FileHandle) syscall.Errno {
+	if fh, ok := f.(*fileHandle); ok {
+		_ = fh.f.Close()
+	}
+
+	return 0
+}
+
+// Mount mounts fsys read-only on dir and starts serving it. Call Unmount on the returned server to stop.
+func Mount(dir string, fsys fs.FS, name string) (*fuse.Server, error) {
+	r := &root{fsys: fsys}
+	if info, err := fs.Stat(fsys, "."); err == nil {
+		r.info = info
+	}
+
+	// the content never changes, so the kernel may cache entries and attributes for long
+	timeout := time.Hour
+	server, err := gofs.Mount(dir, r, &gofs.Options{
+		EntryTimeout: &timeout,
+		AttrTimeout:  &timeout,
+		MountOptions: fuse.MountOptions{
+			FsName:      name,
+			Name:        "spt",
+			Options:     []string{"ro"},
+			DirectMount: true,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if r.err != nil {
+		_ = server.Unmount()
+		return nil, r.err
+	}
+
+	return server, nil
+}
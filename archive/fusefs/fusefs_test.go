@@ -0,0 +1,43 @@
+//go:build linux
+
+package fusefs
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestMount(t *testing.T) {
+	src := fstest.MapFS{
+		"docs/readme.txt": &fstest.MapFile{Data: []byte("read me\n"), Mode: 0640, ModTime: time.Unix(1690000000, 0)},
+		"src/main.go":     &fstest.MapFile{Data: []byte("package main\n"), Mode: 0644},
+		"big.bin":         &fstest.MapFile{Data: bytes.Repeat([]byte("0123456789"), 100000), Mode: 0644},
+	}
+
+	dir := t.TempDir()
+	server, err := Mount(dir, src, "test")
+	if err != nil {
+		t.Skip("FUSE is not available:", err)
+	}
+	defer func() {
+		if err := server.Unmount(); err != nil {
+			t.Error("unexpected: Unmount failed", err)
+		}
+	}()
+
+	if err = fstest.TestFS(os.DirFS(dir), "docs/readme.txt", "src/main.go", "big.bin"); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(dir + "/big.bin")
+	if err != nil || !bytes.Equal(b, src["big.bin"].Data) {
+		t.Fatal("unexpected: mismatch content", err)
+	}
+
+	if err = os.WriteFile(dir+"/new", []byte("x"), 0644); err == nil {
+		t.Fatal("unexpected: the mount should be read-only")
+	}
+}
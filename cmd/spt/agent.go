@@ -0,0 +1,261 @@
+package spt
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"gitea.suyono.dev/suyono/simple-privacy-tool/agent"
+	tw "gitea.suyono.dev/suyono/terminal_wrapper"
+	"github.com/spf13/cobra"
+)
+
+const (
+	defaultAgentKey = "default"
+)
+
+var (
+	agentSocket string
+	agentTTL    time.Duration
+
+	agentCmd = &cobra.Command{
+		Use:   "agent",
+		Short: "keep passphrases in a per-user agent, consulted by encrypt and decrypt through $" + agent.SocketEnv,
+	}
+
+	agentServeCmd = &cobra.Command{
+		Use:   "serve",
+		Args:  cobra.NoArgs,
+		RunE:  agentServe,
+		Short: "run the agent until interrupted",
+	}
+
+	agentAddCmd = &cobra.Command{
+		Use:   "add [name]",
+		Args:  cobra.MaximumNArgs(1),
+		RunE:  agentAdd,
+		Short: "add a passphrase, named default if the name is omitted",
+	}
+
+	agentListCmd = &cobra.Command{
+		Use:   "list",
+		Args:  cobra.NoArgs,
+		RunE:  agentList,
+		Short: "list the names of the passphrases and when they expire",
+	}
+
+	agentRemoveCmd = &cobra.Command{
+		Use:   "rm name",
+		Args:  cobra.ExactArgs(1),
+		RunE:  agentRemove,
+		Short: "remove a passphrase",
+	}
+
+	agentClearCmd = &cobra.Command{
+		Use:   "clear",
+		Args:  cobra.NoArgs,
+		RunE:  agentClear,
+		Short: "remove all passphrases",
+	}
+
+	agentLockCmd = &cobra.Command{
+		Use:   "lock",
+		Args:  cobra.NoArgs,
+		RunE:  agentLock,
+		Short: "withhold the passphrases until the agent is unlocked with the same password",
+	}
+
+	agentUnlockCmd = &cobra.Command{
+		Use:   "unlock",
+		Args:  cobra.NoArgs,
+		RunE:  agentUnlock,
+		Short: "unlock the agent",
+	}
+)
+
+func init() {
+	agentServeCmd.Flags().StringVar(&agentSocket, "socket", "", "socket path, default is $"+agent.SocketEnv+" or a per-user path")
+	agentAddCmd.Flags().DurationVar(&agentTTL, "ttl", time.Hour, "remove the passphrase after this time, 0 keeps it until the agent stops")
+	agentCmd.AddCommand(agentServeCmd, agentAddCmd, agentListCmd, agentRemoveCmd, agentClearCmd, agentLockCmd, agentUnlockCmd)
+	rootCmd.AddCommand(agentCmd)
+}
+
+func agentServe(cmd *cobra.Command, args []string) (err error) {
+	path := agentSocket
+	if path == "" {
+		if path = os.Getenv(agent.SocketEnv); path == "" {
+			path = agent.DefaultSocket()
+		}
+	}
+
+	l, err := agent.Listen(path)
+	if err != nil {
+		return
+	}
+
+	go func() {
+		<-cmd.Context().Done()
+		_ = l.Close()
+	}()
+
+	fmt.Printf("%s=%s; export %s;\n", agent.SocketEnv, path, agent.SocketEnv)
+	return agent.New().Serve(l)
+}
+
+func agentAdd(cmd *cobra.Command, args []string) (err error) {
+	var (
+		c *agent.Client
+	)
+
+	if c, err = agent.FromEnv(); err != nil {
+		return
+	}
+
+	name := defaultAgentKey
+	if len(args) > 0 {
+		name = args[0]
+	}
+
+	return withTerminal(func(terminal *tw.Terminal) error {
+		passphrase, err := readNewPassphrase(terminal)
+		if err != nil {
+			return err
+		}
+
+		return c.Add(name, passphrase, agentTTL)
+	})
+}
+
+func agentList(cmd *cobra.Command, args []string) (err error) {
+	var (
+		c       *agent.Client
+		entries []agent.Entry
+	)
+
+	if c, err = agent.FromEnv(); err != nil {
+		return
+	}
+
+	if entries, err = c.List(); err != nil {
+		return
+	}
+
+	for _, e := range entries {
+		expires := "never"
+		if !e.Expires.IsZero() {
+			expires = e.Expires.Local().Format(time.DateTime)
+		}
+		fmt.Printf("%s\texpires %s\n", e.Name, expires)
+	}
+
+	return
+}
+
+func agentRemove(cmd *cobra.Command, args []string) (err error) {
+	var (
+		c *agent.Client
+	)
+
+	if c, err = agent.FromEnv(); err != nil {
+		return
+	}
+
+	return c.Remove(args[0])
+}
+
+func agentClear(cmd *cobra.Command, args []string) (err error) {
+	var (
+		c *agent.Client
+	)
+
+	if c, err = agent.FromEnv(); err != nil {
+		return
+	}
+
+	return c.Clear()
+}
+
+func agentLock(cmd *cobra.Command, args []string) (err error) {
+	var (
+		c *agent.Client
+	)
+
+	if c, err = agent.FromEnv(); err != nil {
+		return
+	}
+
+	return withTerminal(func(terminal *tw.Terminal) error {
+		password, err := readNewPassphrase(terminal)
+		if err != nil {
+			return err
+		}
+
+		return c.Lock(password)
+	})
+}
+
+func agentUnlock(cmd *cobra.Command, args []string) (err error) {
+	var (
+		c *agent.Client
+	)
+
+	if c, err = agent.FromEnv(); err != nil {
+		return
+	}
+
+	return withTerminal(func(terminal *tw.Terminal) error {
+		password, err := terminal.ReadPassword("input agent password: ")
+		if err != nil {
+			return err
+		}
+
+		return c.Unlock(password)
+	})
+}
+
+// agentPassphrases returns the passphrases to try before prompting: the one named by --agent-key, or with all set and
+// no --agent-key, every passphrase in the agent. Without all, for a new encryption, there are none unless --agent-key
+// is given, so a file is never encrypted with a passphrase the user didn't choose. Without an agent there are none; a
+// locked or unreachable agent is reported, and then skipped.
+func agentPassphrases(all bool) (passphrases []string) {
+	var (
+		c       *agent.Client
+		entries []agent.Entry
+		err     error
+	)
+
+	if !all && f.agentKey == "" {
+		return
+	}
+
+	if c, err = agent.FromEnv(); err != nil {
+		return
+	}
+
+	names := []string{f.AgentKey()}
+	if all && f.agentKey == "" {
+		if entries, err = c.List(); err != nil {
+			log.Println("agent:", err)
+			return
+		}
+		names = names[:0]
+		for _, e := range entries {
+			names = append(names, e.Name)
+		}
+	}
+
+	for _, name := range names {
+		passphrase, err := c.Get(name)
+		if err != nil {
+			if !errors.Is(err, agent.ErrNotFound) {
+				log.Println("agent:", err)
+			}
+			continue
+		}
+		passphrases = append(passphrases, passphrase)
+	}
+
+	return
+}
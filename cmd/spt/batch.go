@@ -0,0 +1,312 @@
+package spt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gitea.suyono.dev/suyono/simple-privacy-tool/privacy"
+	tw "gitea.suyono.dev/suyono/terminal_wrapper"
+	"github.com/spf13/cobra"
+)
+
+var (
+	ErrDestinationExists = errors.New("destination exists")
+	ErrNoSuffix          = errors.New("file name doesn't end with the suffix")
+	ErrPairLooksLikeGlob = errors.New("dstFile exists and looks like another input, add --suffix to process both files")
+)
+
+// isBatch tells whether encrypt or decrypt work on many files: with -r or --suffix, or with a number of files other
+// than the srcFile dstFile pair.
+func isBatch(cmd *cobra.Command, args []string) bool {
+	return f.Recursive() || cmd.Flags().Changed("suffix") || len(args) == 1 || len(args) > 2
+}
+
+// checkPair refuses a srcFile dstFile pair when dstFile is likely another input of a shell glob matching two files,
+// e.g. decrypt *.spt: decrypt refuses an existing dstFile which is encrypted or ends with the suffix, and encrypt an
+// existing dstFile which isn't encrypted.
+func checkPair(args []string, encrypting bool) (err error) {
+	var (
+		dst *os.File
+	)
+
+	if len(args) != 2 || args[1] == "-" {
+		return nil
+	}
+
+	if !encrypting && strings.HasSuffix(args[1], f.Suffix()) {
+		if _, err = os.Lstat(args[1]); err == nil {
+			return ErrPairLooksLikeGlob
+		}
+	}
+
+	if dst, err = os.Open(args[1]); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return
+	}
+	defer func() {
+		_ = dst.Close()
+	}()
+
+	if _, _, dErr := privacy.Detect(dst); (dErr == nil) != encrypting {
+		return ErrPairLooksLikeGlob
+	}
+
+	return nil
+}
+
+// collectFiles expands the arguments to the list of files to process. Directories are walked with -r, taking the
+// regular files accepted by match; files named explicitly are always taken.
+func collectFiles(args []string, match func(path string) bool) (files []string, err error) {
+	var (
+		fi os.FileInfo
+	)
+
+	if len(args) == 0 {
+		return nil, errors.New("no files given")
+	}
+
+	if f.Suffix() == "" {
+		return nil, errors.New("invalid suffix")
+	}
+
+	if f.Jobs() < 1 {
+		return nil, errors.New("invalid number of jobs")
+	}
+
+	for _, arg := range args {
+		if arg == "-" {
+			return nil, errors.New("STDIN can't be used with several files")
+		}
+
+		if fi, err = os.Stat(arg); err != nil {
+			return
+		}
+
+		if !fi.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+
+		if !f.Recursive() {
+			return nil, fmt.Errorf("%s is a directory, use -r", arg)
+		}
+
+		if err = filepath.WalkDir(arg, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.Type().IsRegular() && match(path) {
+				files = append(files, path)
+			}
+			return nil
+		}); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// processBatchFile writes dst from src like EncryptFile and DecryptFile, without overwriting an existing dst, and
+// copies the permissions and modification time of src.
+func processBatchFile(dst, src string, process func(dst, src string, opts ...privacy.Option) error,
+	opts []privacy.Option) (err error) {
+	var (
+		fi os.FileInfo
+	)
+
+	if _, err = os.Lstat(dst); err == nil {
+		return ErrDestinationExists
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+
+	if fi, err = os.Stat(src); err != nil {
+		return
+	}
+
+	if err = process(dst, src, opts...); err != nil {
+		return
+	}
+
+	if err = os.Chmod(dst, fi.Mode().Perm()); err != nil {
+		return
+	}
+
+	return os.Chtimes(dst, time.Time{}, fi.ModTime())
+}
+
+type batchResult struct {
+	src string
+	err error
+}
+
+// runBatch runs process on the files with --jobs workers, and prints a summary. It fails if any file failed.
+func runBatch(ctx context.Context, files []string, verb string, process func(src string) error) error {
+	var (
+		wg      sync.WaitGroup
+		results = make([]batchResult, len(files))
+		sem     = make(chan struct{}, f.Jobs())
+		failed  int
+	)
+
+	for i, src := range files {
+		if ctx.Err() != nil {
+			results[i] = batchResult{src: src, err: ctx.Err()}
+			continue
+		}
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int, src string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			results[i] = batchResult{src: src, err: process(src)}
+		}(i, src)
+	}
+	wg.Wait()
+
+	for _, r := range results {
+		if r.err != nil {
+			failed++
+			_, _ = fmt.Fprintf(os.Stderr, "%s: %v\n", r.src, r.err)
+		}
+	}
+	_, _ = fmt.Fprintf(os.Stderr, "%d files %s, %d failed\n", len(files)-failed, verb, failed)
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files failed", failed, len(files))
+	}
+
+	return nil
+}
+
+func encryptBatch(cmd *cobra.Command, args []string) (err error) {
+	var (
+		files      []string
+		passphrase string
+	)
+
+	if files, err = collectFiles(args, func(path string) bool {
+		return !strings.HasSuffix(path, f.Suffix())
+	}); err != nil {
+		return
+	}
+
+	if f.Shares() > 0 {
+		return errors.New("--shares can't be used with several files")
+	}
+
+	if f.Append() {
+		return errors.New("--append can't be used with several files")
+	}
+
+	if fromAgent := agentPassphrases(false); len(fromAgent) > 0 {
+		passphrase = fromAgent[0]
+	} else if err = withTerminal(func(terminal *tw.Terminal) (err error) {
+		passphrase, err = readNewPassphrase(terminal)
+		return
+	}); err != nil {
+		return
+	}
+
+	opts := append(f.EncryptOptions(), privacy.WithContext(cmd.Context()), privacy.WithPassphrase(passphrase))
+	return runBatch(cmd.Context(), files, "encrypted", func(src string) error {
+		return processBatchFile(src+f.Suffix(), src, privacy.EncryptFile, opts)
+	})
+}
+
+// checkPassphrase verifies passphrase against the key check value in the header of the file at path.
+func checkPassphrase(path, passphrase string) (err error) {
+	var (
+		src *os.File
+		s   io.Reader
+	)
+
+	if src, err = os.Open(path); err != nil {
+		return
+	}
+	defer func() {
+		_ = src.Close()
+	}()
+
+	s, _, err = privacy.Detect(src)
+	if err != nil {
+		return
+	}
+
+	r := privacy.NewPrivacyReaderWithKeyGen(s, f.KeyGen())
+	if err = r.ReadMagic(); err != nil {
+		return
+	}
+
+	return r.GenerateKey(passphrase)
+}
+
+func decryptBatch(cmd *cobra.Command, args []string) (err error) {
+	var (
+		files      []string
+		passphrase string
+	)
+
+	if files, err = collectFiles(args, func(path string) bool {
+		return strings.HasSuffix(path, f.Suffix())
+	}); err != nil {
+		return
+	}
+
+	if len(files) == 0 {
+		return fmt.Errorf("no files ending with %s", f.Suffix())
+	}
+
+	// the passphrase is checked against the first file; a file which can't be read at all fails on its own later
+	try := func(p string) error {
+		if err := checkPassphrase(files[0], p); errors.Is(err, privacy.ErrWrongPassphrase) {
+			return err
+		}
+		passphrase = p
+		return nil
+	}
+
+	if f.KeyStdin() {
+		if passphrase, err = readKeyStdin(); err != nil {
+			return
+		}
+	} else {
+		err = privacy.ErrWrongPassphrase
+		for _, p := range agentPassphrases(true) {
+			if err = try(p); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			if err = withTerminal(func(terminal *tw.Terminal) error {
+				return unlock(terminal, try)
+			}); err != nil {
+				return
+			}
+		}
+	}
+
+	opts := append(f.DecryptOptions(), privacy.WithContext(cmd.Context()), privacy.WithPassphrase(passphrase))
+	return runBatch(cmd.Context(), files, "decrypted", func(src string) error {
+		dst := strings.TrimSuffix(src, f.Suffix())
+		if dst == src || filepath.Base(src) == f.Suffix() {
+			return ErrNoSuffix
+		}
+		return processBatchFile(dst, src, privacy.DecryptFile, opts)
+	})
+}
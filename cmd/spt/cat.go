@@ -0,0 +1,94 @@
+package spt
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"gitea.suyono.dev/suyono/simple-privacy-tool/privacy"
+	"gitea.suyono.dev/suyono/simple-privacy-tool/privacy/slogcrypt"
+	tw "gitea.suyono.dev/suyono/terminal_wrapper"
+	"github.com/spf13/cobra"
+)
+
+var (
+	catFollow bool
+
+	catCmd = &cobra.Command{
+		Use: "cat file",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := processFlags(); err != nil {
+				return err
+			}
+			return cat(cmd, args)
+		},
+		Args:  cobra.ExactArgs(1),
+		Short: "decrypt file to STDOUT, with --follow keep printing new segments as they are written",
+	}
+)
+
+func init() {
+	catCmd.Flags().BoolVarP(&catFollow, "follow", "f", false, "wait for new segments at the end of the file, like tail -f, until interrupted")
+	catCmd.Flags().IntVar(&f.attempts, "attempts", passphraseAttempts, "number of passphrase attempts before giving up")
+	rootCmd.AddCommand(catCmd)
+}
+
+func cat(cmd *cobra.Command, args []string) (err error) {
+	var (
+		src *os.File
+		r   *privacy.Reader
+		dr  io.ReadCloser
+	)
+
+	if src, err = os.Open(args[0]); err != nil {
+		return
+	}
+	defer func() {
+		_ = src.Close()
+	}()
+
+	if !catFollow {
+		return withTerminal(func(terminal *tw.Terminal) error {
+			return privacy.Decrypt(os.Stdout, src, privacy.WithContext(cmd.Context()), privacy.WithKeyGen(f.KeyGen()),
+				privacy.WithAttempts(f.Attempts()), privacy.WithPassphraseFunc(func(attempt int) (string, error) {
+					if attempt > 1 {
+						_, _ = fmt.Fprintln(terminal, "wrong passphrase, try again")
+					}
+					return terminal.ReadPassword("input passphrase: ")
+				}))
+		})
+	}
+
+	// Decrypt can't detect the format of a growing file, it is read as a binary stream, maybe with a hint
+	br := bufio.NewReader(slogcrypt.Follow(cmd.Context(), src, slogcrypt.DefaultPollInterval))
+	if _, err = privacy.SkipHint(br); err != nil {
+		return
+	}
+
+	r = privacy.NewPrivacyReaderWithKeyGen(br, f.KeyGen())
+	if err = r.ReadMagic(); err != nil {
+		return
+	}
+
+	if err = withTerminal(func(terminal *tw.Terminal) error {
+		return unlock(terminal, r.GenerateKey)
+	}); err != nil {
+		return
+	}
+
+	if dr, err = privacy.NewDecompressReader(r); err != nil {
+		return
+	}
+	defer func() {
+		_ = dr.Close()
+	}()
+
+	if _, err = io.Copy(os.Stdout, dr); errors.Is(err, context.Canceled) {
+		return nil
+	}
+
+	return
+}
@@ -1,18 +1,24 @@
 package spt
 
 import (
-	"encoding/base64"
 	"fmt"
 	"gitea.suyono.dev/suyono/simple-privacy-tool/privacy"
-	"io"
 )
 
 type decryptApp struct {
 	cApp
-	r *privacy.Reader
 }
 
 func (d *decryptApp) GetPassphrase() (err error) {
+	if len(d.fromAgent) > 0 {
+		// tried first, see passphraseFunc
+		return
+	}
+
+	return d.readPassphrase()
+}
+
+func (d *decryptApp) readPassphrase() (err error) {
 	var (
 		passphrase string
 	)
@@ -26,34 +32,30 @@ func (d *decryptApp) GetPassphrase() (err error) {
 	return
 }
 
-func (d *decryptApp) ProcessFiles() (err error) {
-	var (
-		src io.Reader
-	)
-
-	if f.IsBase64() {
-		src = base64.NewDecoder(base64.StdEncoding, d.srcFile)
-	} else {
-		src = d.srcFile
+func (d *decryptApp) passphraseFunc(attempt int) (string, error) {
+	if attempt <= len(d.fromAgent) {
+		return d.fromAgent[attempt-1], nil
 	}
 
-	d.r = privacy.NewPrivacyReaderWithKeyGen(src, f.KeyGen())
-redo:
-	if err = d.r.ReadMagic(); err != nil {
-		if h, ok := err.(privacy.InvalidCipherMethod); ok {
-			if err = SkipHint(h, src); err != nil {
-				return fmt.Errorf("reading magic bytes: %w", err)
-			}
-			goto redo
+	if attempt > 1 {
+		if attempt > len(d.fromAgent)+1 {
+			_, _ = fmt.Fprintln(d.term, "wrong passphrase, try again")
+		}
+		if err := d.readPassphrase(); err != nil {
+			return "", err
 		}
-		return
 	}
 
-	if err = d.r.GenerateKey(d.passphrase); err != nil {
-		return
-	}
+	return d.passphrase, nil
+}
 
-	if _, err = io.Copy(d.dstFile, d.r); err != nil {
+func (d *decryptApp) ProcessFiles() (err error) {
+	opts, done := d.progressOptions()
+	opts = append(opts, f.DecryptOptions()...)
+	opts = append(opts, privacy.WithPassphraseFunc(d.passphraseFunc), privacy.WithAttempts(f.Attempts()+len(d.fromAgent)))
+	err = privacy.Decrypt(d.dstFile, d.srcFile, opts...)
+	done()
+	if err != nil {
 		return
 	}
 
@@ -0,0 +1,269 @@
+package spt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"gitea.suyono.dev/suyono/simple-privacy-tool/privacy"
+	tw "gitea.suyono.dev/suyono/terminal_wrapper"
+	"github.com/spf13/cobra"
+)
+
+const (
+	defaultEditor = "vi"
+)
+
+var (
+	editCmd = &cobra.Command{
+		Use: "edit file",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := processFlags(); err != nil {
+				return err
+			}
+			return edit(cmd, args)
+		},
+		Args:  cobra.ExactArgs(1),
+		Short: "decrypt file to a private temporary file, open it in $EDITOR, and encrypt it again on save",
+	}
+)
+
+func init() {
+	editCmd.Flags().IntVar(&f.attempts, "attempts", passphraseAttempts, "number of passphrase attempts before giving up")
+	rootCmd.AddCommand(editCmd)
+}
+
+// editFile is what edit needs to write the file back the way it was.
+type editFile struct {
+	path       string
+	mode       os.FileMode
+	passphrase string
+	opts       []privacy.Option
+	plain      []byte
+}
+
+func edit(cmd *cobra.Command, args []string) (err error) {
+	var (
+		ef      *editFile
+		dir     string
+		onTmpfs bool
+		edited  []byte
+	)
+
+	if ef, err = openEditFile(args[0]); err != nil {
+		return
+	}
+
+	if dir, onTmpfs, err = privateTempDir(); err != nil {
+		return
+	}
+	if !onTmpfs {
+		log.Println("warning: no memory backed temporary directory, the plain text is written to", dir)
+	}
+	// the name without .spt keeps the extension for the editor, e.g. for syntax highlighting
+	base := filepath.Base(args[0])
+	plainPath := filepath.Join(dir, strings.TrimSuffix(base, filepath.Ext(base)))
+	defer func() {
+		if !onTmpfs {
+			_ = privacy.Shred(plainPath)
+		}
+		_ = os.RemoveAll(dir)
+	}()
+
+	if err = os.WriteFile(plainPath, ef.plain, 0600); err != nil {
+		return
+	}
+
+	if err = runEditor(plainPath); err != nil {
+		return
+	}
+
+	if edited, err = os.ReadFile(plainPath); err != nil {
+		return
+	}
+
+	if bytes.Equal(edited, ef.plain) {
+		log.Println("no changes")
+		return nil
+	}
+
+	return ef.save(edited)
+}
+
+// openEditFile decrypts the file at path, and collects the options to encrypt it again: the cipher method, KeyGen,
+// padding, length mask, compression, hint, and encoding of the file. The KeyGen comes from the header, or from the
+// hint, before the flags.
+func openEditFile(path string) (ef *editFile, err error) {
+	var (
+		src    *os.File
+		fi     os.FileInfo
+		s      io.Reader
+		format *privacy.Format
+		dr     io.ReadCloser
+	)
+
+	if src, err = os.Open(path); err != nil {
+		return
+	}
+	defer func() {
+		_ = src.Close()
+	}()
+
+	if fi, err = src.Stat(); err != nil {
+		return
+	}
+
+	if s, format, err = privacy.Detect(src); err != nil {
+		return
+	}
+
+	r := privacy.NewPrivacyReaderWithKeyGen(s, f.KeyGen())
+	if err = r.ReadMagic(); err != nil {
+		return
+	}
+
+	keygen := f.KeyGen()
+	if k, ok := r.RecordedKeyGen(); ok {
+		keygen = k
+	} else if format.Hint != nil {
+		if k, err := privacy.ParseKeyGen(format.Hint); err == nil {
+			keygen = k
+		}
+	}
+	r.SetKeyGen(keygen)
+
+	ef = &editFile{
+		path: path,
+		mode: fi.Mode().Perm(),
+		opts: []privacy.Option{
+			privacy.WithCipherMethod(r.CipherMethod()),
+			privacy.WithKeyGen(keygen),
+			privacy.WithCompression(r.Compression(), privacy.DefaultCompressionLevel),
+			privacy.WithPadding(r.Padding()),
+		},
+	}
+	if r.MaskedLength() {
+		ef.opts = append(ef.opts, privacy.WithMaskedLength())
+	}
+	if format.Hint != nil {
+		ef.opts = append(ef.opts, privacy.WithHint())
+	}
+	if format.ECC != nil {
+		ef.opts = append(ef.opts, privacy.WithECC(format.ECC.Percent()))
+	}
+	switch format.Encoding {
+	case privacy.ArmorEncoding:
+		ef.opts = append(ef.opts, privacy.WithArmor(f.ArmorCRC()))
+	case privacy.Base64Encoding:
+		ef.opts = append(ef.opts, privacy.WithBase64())
+	}
+
+	try := func(passphrase string) error {
+		if err := r.GenerateKey(passphrase); err != nil {
+			return err
+		}
+		ef.passphrase = passphrase
+		return nil
+	}
+
+	err = privacy.ErrWrongPassphrase
+	for _, p := range agentPassphrases(true) {
+		if err = try(p); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		if err = withTerminal(func(terminal *tw.Terminal) error {
+			return unlock(terminal, try)
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	if dr, err = privacy.NewDecompressReader(r); err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = dr.Close()
+	}()
+
+	if ef.plain, err = io.ReadAll(dr); err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+// runEditor runs $VISUAL or $EDITOR on path. Signals meant for the editor, like Ctrl+C, don't stop spt, so it can
+// still remove the plain text once the editor exits.
+func runEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = defaultEditor
+	}
+
+	fields := strings.Fields(editor)
+	if len(fields) == 0 {
+		return errors.New("invalid editor")
+	}
+
+	ignore := make(chan os.Signal, 1)
+	signal.Notify(ignore, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT)
+	defer signal.Stop(ignore)
+
+	c := exec.Command(fields[0], append(fields[1:], path)...)
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("editor: %w", err)
+	}
+
+	return nil
+}
+
+// save encrypts plain with a fresh salt into a temporary file next to the original, and renames it over the
+// original once it is on disk. It ignores the command context: an interrupt meant for the editor mustn't lose the edit.
+func (ef *editFile) save(plain []byte) (err error) {
+	var (
+		tmp *os.File
+	)
+
+	if tmp, err = os.CreateTemp(filepath.Dir(ef.path), "."+filepath.Base(ef.path)+".*"); err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	opts := append(ef.opts, privacy.WithPassphrase(ef.passphrase))
+	if err = privacy.Encrypt(tmp, bytes.NewReader(plain), opts...); err != nil {
+		return
+	}
+
+	if err = tmp.Chmod(ef.mode); err != nil {
+		return
+	}
+
+	if err = tmp.Sync(); err != nil {
+		return
+	}
+
+	if err = tmp.Close(); err != nil {
+		return
+	}
+
+	return os.Rename(tmp.Name(), ef.path)
+}
@@ -0,0 +1,28 @@
+package spt
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// privateTempDir creates a directory only the user can access, for the plain text of edit. It prefers memory
+// backed file systems, so the plain text never reaches the disk and is gone after a crash and reboot at the latest.
+func privateTempDir() (dir string, onTmpfs bool, err error) {
+	var (
+		st unix.Statfs_t
+	)
+
+	for _, parent := range []string{os.Getenv("XDG_RUNTIME_DIR"), "/dev/shm"} {
+		if parent == "" || unix.Statfs(parent, &st) != nil || st.Type != unix.TMPFS_MAGIC {
+			continue
+		}
+
+		if dir, err = os.MkdirTemp(parent, "spt-edit-"); err == nil {
+			return dir, true, nil
+		}
+	}
+
+	dir, err = os.MkdirTemp("", "spt-edit-")
+	return dir, false, err
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package spt
+
+import (
+	"os"
+)
+
+// privateTempDir creates a directory only the user can access, for the plain text of edit. Only Linux has a memory
+// backed file system this can rely on, elsewhere the directory is in the temporary directory.
+func privateTempDir() (dir string, onTmpfs bool, err error) {
+	dir, err = os.MkdirTemp("", "spt-edit-")
+	return dir, false, err
+}
@@ -1,73 +1,157 @@
 package spt
 
 import (
-	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+
 	"gitea.suyono.dev/suyono/simple-privacy-tool/privacy"
-	"io"
+	tw "gitea.suyono.dev/suyono/terminal_wrapper"
 )
 
 type encryptApp struct {
 	cApp
-	wc *privacy.WriteCloser
+	appending bool
+	dstSize   int64
+	shares    []shareFile
 }
 
 func (e *encryptApp) GetPassphrase() (err error) {
+	if len(e.fromAgent) > 0 {
+		// tried first, see passphraseFunc
+		e.passphrase = e.fromAgent[0]
+		return
+	}
+
+	return e.readPassphrase()
+}
+
+func (e *encryptApp) readPassphrase() (err error) {
+	if e.appending {
+		e.passphrase, err = e.term.ReadPassword("input passphrase: ")
+		return
+	}
+
+	e.passphrase, err = readNewPassphrase(e.term)
+	return
+}
+
+func (e *encryptApp) passphraseFunc(attempt int) (string, error) {
+	if attempt <= len(e.fromAgent) {
+		return e.fromAgent[attempt-1], nil
+	}
+
+	if attempt > 1 {
+		if attempt > len(e.fromAgent)+1 {
+			_, _ = fmt.Fprintln(e.term, "wrong passphrase, try again")
+		}
+		if err := e.readPassphrase(); err != nil {
+			return "", err
+		}
+	}
+
+	return e.passphrase, nil
+}
+
+// prepareAppend checks whether --append continues an existing encrypted file, which needs its passphrase instead
+// of a new one.
+func (e *encryptApp) prepareAppend() (err error) {
 	var (
-		passphrase string
-		verify     string
+		info os.FileInfo
 	)
 
-	if passphrase, err = e.term.ReadPassword("input passphrase: "); err != nil {
+	if !f.Append() {
 		return
 	}
 
-	if verify, err = e.term.ReadPassword("verify - input passphrase: "); err != nil {
-		return
+	if e.dstFile == os.Stdout {
+		return errors.New("--append needs a destination file")
 	}
 
-	if passphrase != verify {
-		return ErrPassphraseMismatch
+	if info, err = e.dstFile.Stat(); err != nil {
+		return
 	}
+	e.appending = info.Size() > 0
+	e.dstSize = info.Size()
 
-	e.passphrase = passphrase
+	return
+}
+
+// prepareShred checks that --shred has a source file to remove and a destination file to verify.
+func (e *encryptApp) prepareShred() error {
+	if f.Shred() && (e.srcFile == os.Stdin || e.dstFile == os.Stdout) {
+		return errors.New("--shred needs a source and a destination file")
+	}
 
 	return nil
 }
 
-func (e *encryptApp) ProcessFiles() (err error) {
-	var dst io.WriteCloser
+// readNewPassphrase asks for a new passphrase twice and makes sure both match.
+func readNewPassphrase(terminal *tw.Terminal) (passphrase string, err error) {
+	var (
+		verify string
+	)
+
+	if passphrase, err = terminal.ReadPassword("input passphrase: "); err != nil {
+		return
+	}
+
+	if verify, err = terminal.ReadPassword("verify - input passphrase: "); err != nil {
+		return
+	}
+
+	if passphrase != verify {
+		return "", ErrPassphraseMismatch
+	}
+
+	return
+}
 
-	if f.IsBase64() {
-		dst = base64.NewEncoder(base64.StdEncoding, e.dstFile)
+func (e *encryptApp) ProcessFiles() (err error) {
+	opts, done := e.progressOptions()
+	opts = append(opts, f.EncryptOptions()...)
+	if e.appending {
+		opts = append(opts, privacy.WithPassphraseFunc(e.passphraseFunc), privacy.WithAttempts(f.Attempts()+len(e.fromAgent)))
+		if err = privacy.Append(e.dstFile, e.dstFile, e.dstSize, e.srcFile, opts...); err != nil {
+			// drop the incomplete session
+			_ = e.dstFile.Truncate(e.dstSize)
+		}
 	} else {
-		dst = e.dstFile
+		opts = append(opts, privacy.WithPassphrase(e.passphrase), privacy.WithKeyGen(e.keyGen()))
+		err = privacy.Encrypt(e.dstFile, e.srcFile, opts...)
+	}
+	done()
+	if err != nil {
+		return
 	}
 
-	if f.IncludeHint() {
-		if err = WriteHint(f.KeyGen(), dst); err != nil {
+	if f.Shred() {
+		if err = e.dstFile.Sync(); err != nil {
 			return
 		}
 	}
 
-	e.wc = privacy.NewPrivacyWriteCloserWithKeyGen(dst, f.CipherMethod(), f.KeyGen())
-	if err = e.wc.NewSalt(); err != nil {
+	if err = e.dstFile.Close(); err != nil {
 		return
 	}
 
-	if err = e.wc.GenerateKey(e.passphrase); err != nil {
+	if err = e.srcFile.Close(); err != nil {
 		return
 	}
 
-	if _, err = io.Copy(e.wc, e.srcFile); err != nil {
+	if err = e.writeShares(); err != nil {
 		return
 	}
 
-	if err = e.wc.Close(); err != nil {
-		return
-	}
+	if f.Shred() {
+		if err = privacy.VerifyFile(e.dstPath, e.srcPath, privacy.WithKeyGen(e.keyGen()),
+			privacy.WithPassphrase(e.passphrase)); err != nil {
+			return
+		}
 
-	if err = e.srcFile.Close(); err != nil {
-		return
+		if err = privacy.Shred(e.srcPath); err != nil {
+			return
+		}
 	}
 
 	return
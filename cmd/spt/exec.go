@@ -0,0 +1,127 @@
+package spt
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"gitea.suyono.dev/suyono/simple-privacy-tool/envfile"
+	"gitea.suyono.dev/suyono/simple-privacy-tool/privacy"
+	tw "gitea.suyono.dev/suyono/terminal_wrapper"
+	"github.com/spf13/cobra"
+)
+
+var (
+	execEnvFiles []string
+	execFormat   string
+
+	execCmd = &cobra.Command{
+		Use: "exec --env file [--env file]... -- command [arg]...",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := processFlags(); err != nil {
+				return err
+			}
+			return execWithEnv(cmd, args)
+		},
+		Args:  cobra.MinimumNArgs(1),
+		Short: "run command with the variables of encrypted env files added to its environment",
+	}
+)
+
+func init() {
+	execCmd.Flags().StringArrayVar(&execEnvFiles, "env", nil, "encrypted env file, may be repeated, later files override earlier ones")
+	execCmd.Flags().StringVar(&execFormat, "format", envfile.AutoFormat.String(), "env file format, valid values: auto, dotenv, json, and yaml. auto goes by the file name, then the content")
+	execCmd.Flags().IntVar(&f.attempts, "attempts", passphraseAttempts, "number of passphrase attempts before giving up")
+	execCmd.Flags().SetInterspersed(false)
+	_ = execCmd.MarkFlagRequired("env")
+	rootCmd.AddCommand(execCmd)
+}
+
+func execWithEnv(cmd *cobra.Command, args []string) (err error) {
+	var (
+		format envfile.Format
+		path   string
+		known  []string
+		vars   = make(map[string]string)
+	)
+
+	if format, err = envfile.ParseFormat(execFormat); err != nil {
+		return
+	}
+
+	// the passphrases that worked are tried first for the next file
+	known = agentPassphrases(true)
+	for _, envPath := range execEnvFiles {
+		var (
+			plain bytes.Buffer
+			fv    map[string]string
+		)
+
+		if known, err = decryptInMemory(&plain, envPath, known); err != nil {
+			return fmt.Errorf("%s: %w", envPath, err)
+		}
+
+		fileFormat := format
+		if fileFormat == envfile.AutoFormat {
+			fileFormat = envfile.FormatFromName(envPath)
+		}
+		if fv, err = envfile.Parse(plain.Bytes(), fileFormat); err != nil {
+			return fmt.Errorf("%s: %w", envPath, err)
+		}
+
+		for name, value := range fv {
+			vars[name] = value
+		}
+	}
+
+	if path, err = exec.LookPath(args[0]); err != nil {
+		return
+	}
+
+	return execCommand(path, args, envfile.Environ(os.Environ(), vars))
+}
+
+// decryptInMemory decrypts the file at path into plain, trying the known passphrases before asking. It returns the
+// known passphrases with the one that worked in front.
+func decryptInMemory(plain *bytes.Buffer, path string, known []string) (_ []string, err error) {
+	var (
+		src        *os.File
+		passphrase string
+	)
+
+	if src, err = os.Open(path); err != nil {
+		return known, err
+	}
+	defer func() {
+		_ = src.Close()
+	}()
+
+	err = withTerminal(func(terminal *tw.Terminal) error {
+		return privacy.Decrypt(plain, src, privacy.WithKeyGen(f.KeyGen()), privacy.WithAttempts(f.Attempts()+len(known)),
+			privacy.WithPassphraseFunc(func(attempt int) (string, error) {
+				if attempt <= len(known) {
+					passphrase = known[attempt-1]
+					return passphrase, nil
+				}
+				if attempt > len(known)+1 {
+					_, _ = fmt.Fprintln(terminal, "wrong passphrase, try again")
+				}
+				p, err := terminal.ReadPassword("input passphrase for " + path + ": ")
+				passphrase = p
+				return p, err
+			}))
+	})
+	if err != nil {
+		return known, err
+	}
+
+	result := []string{passphrase}
+	for _, p := range known {
+		if p != passphrase {
+			result = append(result, p)
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,26 @@
+//go:build !unix
+
+package spt
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+// execCommand runs the command and exits with its exit status, the platform has no exec.
+func execCommand(path string, args []string, env []string) error {
+	c := exec.Command(path, args[1:]...)
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+	c.Env = env
+
+	var exitErr *exec.ExitError
+	if err := c.Run(); errors.As(err, &exitErr) {
+		os.Exit(exitErr.ExitCode())
+	} else if err != nil {
+		return err
+	}
+
+	os.Exit(0)
+	return nil
+}
@@ -0,0 +1,12 @@
+//go:build unix
+
+package spt
+
+import (
+	"syscall"
+)
+
+// execCommand replaces spt with the command, so signals and the exit status are the command's own.
+func execCommand(path string, args []string, env []string) error {
+	return syscall.Exec(path, args, env)
+}
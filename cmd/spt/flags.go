@@ -2,7 +2,14 @@ package spt
 
 import (
 	"errors"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+
 	"gitea.suyono.dev/suyono/simple-privacy-tool/privacy"
+	"gitea.suyono.dev/suyono/simple-privacy-tool/shamir"
+	"github.com/spf13/cobra"
 )
 
 type flags struct {
@@ -15,6 +22,29 @@ type flags struct {
 	argon2idThreads int
 	keygen          privacy.KeyGen
 	hint            bool
+	attempts        int
+	compress        string
+	compressLevel   int
+	compression     privacy.CompressionType
+	pad             string
+	padding         privacy.Padding
+	hideLength      bool
+	armor           bool
+	noCRC           bool
+	progress        bool
+	deterministic   bool
+	append          bool
+	agentKey        string
+	recursive       bool
+	suffix          string
+	jobs            int
+	shred           bool
+	shares          int
+	threshold       int
+	protectShares   bool
+	keyStdin        bool
+	eccAmount       string
+	ecc             int
 }
 
 const (
@@ -28,6 +58,14 @@ const (
 	argon2idTime    = 1
 	argon2idMemory  = 64 * 1024
 	argon2idThreads = 4
+
+	passphraseAttempts = 3
+
+	defaultSuffix = ".spt"
+
+	noCompression   = "none"
+	gzipCompression = "gzip"
+	zstdCompression = "zstd"
 )
 
 var (
@@ -36,12 +74,35 @@ var (
 
 func initFlags() {
 	encryptCmd.PersistentFlags().BoolVar(&f.hint, "hint", false, "include hint in the output file")
+	decryptCmd.PersistentFlags().IntVar(&f.attempts, "attempts", passphraseAttempts, "number of passphrase attempts before giving up")
+	encryptCmd.PersistentFlags().StringVar(&f.compress, "compress", noCompression, "compress before encryption, valid values: none, gzip, and zstd")
+	encryptCmd.PersistentFlags().IntVar(&f.compressLevel, "compress-level", privacy.DefaultCompressionLevel, "compression level, 1-9 for gzip and 1-22 for zstd. Default is the algorithm's default level")
+	encryptCmd.PersistentFlags().StringVar(&f.pad, "pad", "none", "pad the plain text to hide its length, valid values: none, padme, block:SIZE, and fixed:SIZE")
+	encryptCmd.PersistentFlags().BoolVar(&f.hideLength, "hide-length", false, "encrypt the segment length prefixes")
+	encryptCmd.PersistentFlags().BoolVar(&f.armor, "armor", false, "encode the output in ASCII armor, suitable for emails and chat messages")
+	encryptCmd.PersistentFlags().BoolVar(&f.noCRC, "no-crc", false, "omit the checksum from the ASCII armor")
+	encryptCmd.PersistentFlags().BoolVar(&f.deterministic, "deterministic", false, "derive the salt and nonces from the plain text, so the same file always encrypts to the same output. It reveals which encrypted files are equal")
+	encryptCmd.PersistentFlags().BoolVar(&f.append, "append", false, "append to an existing encrypted dstFile as a new session, using its passphrase and format")
+	encryptCmd.PersistentFlags().BoolVar(&f.shred, "shred", false, "overwrite and remove srcFile once dstFile is written and verified. See the README for its limits")
+	encryptCmd.PersistentFlags().IntVar(&f.shares, "shares", 0, "encrypt with a random key, split into this many share files named dstFile.shareN")
+	encryptCmd.PersistentFlags().IntVar(&f.threshold, "threshold", 0, "number of shares needed to recover the key, with --shares")
+	encryptCmd.PersistentFlags().BoolVar(&f.protectShares, "protect-shares", false, "protect each share file with its own passphrase")
+	decryptCmd.PersistentFlags().BoolVar(&f.keyStdin, "key-stdin", false, "read the key printed by combine from STDIN instead of asking for a passphrase")
+	encryptCmd.PersistentFlags().StringVar(&f.eccAmount, "ecc", "", "add Reed-Solomon parity of this percentage of the size, 1% to 100%, to repair bit rot on archival media")
+	encryptCmd.PersistentFlags().IntVar(&f.attempts, "attempts", passphraseAttempts, "number of passphrase attempts before giving up, with --append")
 	encryptCmd.PersistentFlags().StringVar(&f.algo, "algo", defaultAlgo, "encryption algorithm, valid values: chacha and aes. Default algo is chacha")
+	for _, cmd := range []*cobra.Command{encryptCmd, decryptCmd} {
+		cmd.PersistentFlags().BoolVarP(&f.recursive, "recursive", "r", false, "process the files in the given directories and their subdirectories")
+		cmd.PersistentFlags().StringVar(&f.suffix, "suffix", defaultSuffix, "file name suffix of the encrypted files in batch mode")
+		cmd.PersistentFlags().IntVar(&f.jobs, "jobs", runtime.NumCPU(), "number of files processed concurrently in batch mode")
+	}
 	rootCmd.PersistentFlags().StringVar(&f.kdf, "kdf", defaultKdf, "Key Derivation Function, valid values: argon2")
 	rootCmd.PersistentFlags().IntVar(&f.argon2idTime, "argon2id-time", argon2idTime, "sets argon2id time cost-parameter")
 	rootCmd.PersistentFlags().IntVar(&f.argon2idMemory, "argon2id-mem", argon2idMemory, "sets argon2id memory cost-parameter (in KB)")
 	rootCmd.PersistentFlags().IntVar(&f.argon2idThreads, "argon2id-thread", argon2idThreads, "sets argon2id thread cost-parameter")
-	rootCmd.PersistentFlags().BoolVar(&f.base64Encoding, "base64", false, "the file is encoded in Base64")
+	rootCmd.PersistentFlags().StringVar(&f.agentKey, "agent-key", "", "name of the passphrase in the agent. encrypt only uses the agent when it's set, decrypt tries all of them by default")
+	rootCmd.PersistentFlags().BoolVar(&f.progress, "progress", false, "show a progress bar on stderr")
+	rootCmd.PersistentFlags().BoolVar(&f.base64Encoding, "base64", false, "encode the output in Base64, decrypt detects Base64 input automatically")
 }
 
 func processFlags() (err error) {
@@ -49,10 +110,49 @@ func processFlags() (err error) {
 		return
 	}
 
+	if f.armor && f.base64Encoding {
+		return errors.New("--armor and --base64 are mutually exclusive")
+	}
+
+	if f.attempts < 1 {
+		return errors.New("invalid number of passphrase attempts")
+	}
+
+	if f.shred && f.append {
+		return errors.New("--shred and --append are mutually exclusive")
+	}
+
+	if f.shares != 0 {
+		if f.shares < 2 || f.shares > shamir.MaxShares || f.threshold < 2 || f.threshold > f.shares {
+			return errors.New("invalid --shares or --threshold")
+		}
+		if f.append {
+			return errors.New("--shares and --append are mutually exclusive")
+		}
+	}
+
+	if err = processECCFlags(); err != nil {
+		return
+	}
+
 	if err = processKeyGenFlags(); err != nil {
 		return
 	}
 
+	if f.keyStdin {
+		// the key is random, and a wrong one can't be corrected by asking again
+		f.keygen = privacy.NewHKDF()
+		f.attempts = 1
+	}
+
+	if err = processCompressFlags(); err != nil {
+		return
+	}
+
+	if f.padding, err = privacy.ParsePadding(f.pad); err != nil {
+		return fmt.Errorf("invalid pad: %w", err)
+	}
+
 	return
 }
 
@@ -69,6 +169,38 @@ func processAlgoFlags() (err error) {
 	return
 }
 
+func processCompressFlags() (err error) {
+	switch f.compress {
+	case noCompression:
+		f.compression = privacy.NoCompression
+	case gzipCompression:
+		f.compression = privacy.GzipCompression
+	case zstdCompression:
+		f.compression = privacy.ZstdCompression
+	default:
+		return errors.New("invalid compression")
+	}
+
+	return
+}
+
+func processECCFlags() (err error) {
+	if f.eccAmount == "" {
+		f.ecc = 0
+		return
+	}
+
+	if f.ecc, err = strconv.Atoi(strings.TrimSuffix(f.eccAmount, "%")); err != nil || f.ecc < 1 || f.ecc > 100 {
+		return privacy.ErrInvalidECCAmount
+	}
+
+	if f.append {
+		return errors.New("--ecc and --append are mutually exclusive")
+	}
+
+	return
+}
+
 func processKeyGenFlags() (err error) {
 	switch f.kdf {
 	case defaultKdf:
@@ -89,6 +221,14 @@ func (f flags) IsBase64() bool {
 	return f.base64Encoding
 }
 
+func (f flags) IsArmor() bool {
+	return f.armor
+}
+
+func (f flags) ArmorCRC() bool {
+	return !f.noCRC
+}
+
 func (f flags) CipherMethod() privacy.CipherMethodType {
 	return f.cmType
 }
@@ -100,3 +240,124 @@ func (f flags) KeyGen() privacy.KeyGen {
 func (f flags) IncludeHint() bool {
 	return f.hint
 }
+
+func (f flags) Compression() privacy.CompressionType {
+	return f.compression
+}
+
+func (f flags) CompressionLevel() int {
+	return f.compressLevel
+}
+
+func (f flags) Padding() privacy.Padding {
+	return f.padding
+}
+
+func (f flags) HideLength() bool {
+	return f.hideLength
+}
+
+func (f flags) Deterministic() bool {
+	return f.deterministic
+}
+
+func (f flags) Append() bool {
+	return f.append
+}
+
+func (f flags) Shred() bool {
+	return f.shred
+}
+
+func (f flags) Shares() int {
+	return f.shares
+}
+
+func (f flags) Threshold() int {
+	return f.threshold
+}
+
+func (f flags) ProtectShares() bool {
+	return f.protectShares
+}
+
+func (f flags) ECC() int {
+	return f.ecc
+}
+
+func (f flags) KeyStdin() bool {
+	return f.keyStdin
+}
+
+func (f flags) AgentKey() string {
+	if f.agentKey == "" {
+		return defaultAgentKey
+	}
+
+	return f.agentKey
+}
+
+func (f flags) Recursive() bool {
+	return f.recursive
+}
+
+func (f flags) Suffix() string {
+	return f.suffix
+}
+
+func (f flags) Jobs() int {
+	return f.jobs
+}
+
+func (f flags) ShowProgress() bool {
+	return f.progress
+}
+
+func (f flags) Attempts() int {
+	return f.attempts
+}
+
+func (f flags) EncryptOptions() []privacy.Option {
+	opts := []privacy.Option{
+		privacy.WithCipherMethod(f.CipherMethod()),
+		privacy.WithKeyGen(f.KeyGen()),
+		privacy.WithCompression(f.Compression(), f.CompressionLevel()),
+		privacy.WithPadding(f.Padding()),
+	}
+
+	if f.IncludeHint() {
+		opts = append(opts, privacy.WithHint())
+	}
+
+	switch {
+	case f.IsArmor():
+		opts = append(opts, privacy.WithArmor(f.ArmorCRC()))
+	case f.IsBase64():
+		opts = append(opts, privacy.WithBase64())
+	}
+
+	if f.HideLength() {
+		opts = append(opts, privacy.WithMaskedLength())
+	}
+
+	if f.Deterministic() {
+		opts = append(opts, privacy.WithDeterministic())
+	}
+
+	if f.Shred() {
+		opts = append(opts, privacy.WithShred())
+	}
+
+	if f.ECC() > 0 {
+		opts = append(opts, privacy.WithECC(f.ECC()))
+	}
+
+	return opts
+}
+
+func (f flags) DecryptOptions() []privacy.Option {
+	return []privacy.Option{
+		privacy.WithKeyGen(f.KeyGen()),
+		privacy.WithAttempts(f.Attempts()),
+	}
+}
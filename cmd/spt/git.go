@@ -0,0 +1,234 @@
+package spt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"gitea.suyono.dev/suyono/simple-privacy-tool/gitfilter"
+	"github.com/spf13/cobra"
+)
+
+const (
+	gitFilterDriver = "spt"
+	gitKeyName      = "spt-key"
+)
+
+var (
+	gitKeyFile string
+
+	gitFilterCmd = &cobra.Command{
+		Use:       "git-filter clean|smudge|process [path]",
+		Args:      cobra.RangeArgs(1, 2),
+		ValidArgs: []string{"clean", "smudge", "process"},
+		RunE:      gitFilter,
+		Short:     "run as git clean/smudge filter, or as long-running filter process, configured by git-init",
+	}
+
+	gitInitCmd = &cobra.Command{
+		Use:   "git-init [pattern...]",
+		RunE:  gitInit,
+		Short: "set up the git filter in the current repository, and mark the files matching the patterns for encryption",
+	}
+)
+
+func init() {
+	gitFilterCmd.Flags().StringVar(&gitKeyFile, "key-file", "", "repository key file, default is spt-key in the git directory")
+	gitInitCmd.Flags().StringVar(&gitKeyFile, "key-file", "", "repository key file, default is spt-key in the git directory. It is created if missing")
+	rootCmd.AddCommand(gitFilterCmd, gitInitCmd)
+}
+
+func git(args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command("git", args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+func gitKeyPath() (string, error) {
+	if gitKeyFile != "" {
+		return gitKeyFile, nil
+	}
+
+	dir, err := git("rev-parse", "--absolute-git-dir")
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, gitKeyName), nil
+}
+
+func gitFilter(cmd *cobra.Command, args []string) (err error) {
+	var (
+		keyPath string
+		key     []byte
+		c       *gitfilter.Cipher
+		content []byte
+		out     []byte
+	)
+
+	if keyPath, err = gitKeyPath(); err != nil {
+		return
+	}
+
+	if key, err = gitfilter.LoadKey(keyPath); err != nil {
+		return
+	}
+
+	if c, err = gitfilter.NewCipher(key); err != nil {
+		return
+	}
+
+	if args[0] == "process" {
+		return gitfilter.Process(os.Stdin, os.Stdout, c)
+	}
+
+	if len(args) != 2 {
+		return errors.New("the path is required for clean and smudge")
+	}
+
+	if content, err = io.ReadAll(os.Stdin); err != nil {
+		return
+	}
+
+	switch args[0] {
+	case "clean":
+		out, err = c.Clean(args[1], content)
+	case "smudge":
+		out, err = c.Smudge(args[1], content)
+	default:
+		return fmt.Errorf("invalid git-filter command %q", args[0])
+	}
+	if err != nil {
+		return
+	}
+
+	_, err = os.Stdout.Write(out)
+	return
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func gitInit(cmd *cobra.Command, args []string) (err error) {
+	var (
+		keyPath  string
+		exe      string
+		toplevel string
+	)
+
+	if keyPath, err = gitKeyPath(); err != nil {
+		return
+	}
+
+	if _, err = os.Stat(keyPath); errors.Is(err, fs.ErrNotExist) {
+		if err = gitfilter.GenerateKey(keyPath); err != nil {
+			return
+		}
+		_, _ = fmt.Fprintf(os.Stderr, "generated a new repository key in %s, share it with the other team members over a secure channel\n", keyPath)
+	} else if err != nil {
+		return
+	}
+
+	if exe, err = os.Executable(); err != nil {
+		return
+	}
+
+	filter := shellQuote(exe) + " git-filter"
+	if gitKeyFile != "" {
+		filter += " --key-file " + shellQuote(gitKeyFile)
+	}
+
+	prefix := "filter." + gitFilterDriver + "."
+	for _, kv := range [][2]string{
+		{prefix + "clean", filter + " clean %f"},
+		{prefix + "smudge", filter + " smudge %f"},
+		{prefix + "process", filter + " process"},
+		{prefix + "required", "true"},
+	} {
+		if _, err = git("config", kv[0], kv[1]); err != nil {
+			return
+		}
+	}
+
+	if len(args) == 0 {
+		return
+	}
+
+	if toplevel, err = git("rev-parse", "--show-toplevel"); err != nil {
+		return
+	}
+
+	if err = addGitAttributes(filepath.Join(toplevel, ".gitattributes"), args); err != nil {
+		return
+	}
+
+	// the filter only runs when git adds a file, already staged or committed files stay plain
+	quoted := make([]string, len(args))
+	for i, pattern := range args {
+		quoted[i] = shellQuote(pattern)
+	}
+	_, _ = fmt.Fprintf(os.Stderr, "files already added stay plain until they are added again: run git add --renormalize -- %s "+
+		"and commit; their plain versions stay in the history\n", strings.Join(quoted, " "))
+
+	return
+}
+
+// addGitAttributes appends a filter line for each pattern not in the file yet.
+func addGitAttributes(path string, patterns []string) (err error) {
+	var (
+		b []byte
+		f *os.File
+	)
+
+	if b, err = os.ReadFile(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+
+	existing := strings.Split(string(b), "\n")
+	var lines strings.Builder
+	if len(b) > 0 && !bytes.HasSuffix(b, []byte("\n")) {
+		lines.WriteString("\n")
+	}
+	for _, pattern := range patterns {
+		line := pattern + " filter=" + gitFilterDriver + " -diff"
+		if !contains(existing, line) {
+			lines.WriteString(line + "\n")
+			existing = append(existing, line)
+		}
+	}
+
+	if f, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644); err != nil {
+		return
+	}
+
+	if _, err = f.WriteString(lines.String()); err != nil {
+		_ = f.Close()
+		return
+	}
+
+	return f.Close()
+}
+
+func contains(lines []string, line string) bool {
+	for _, l := range lines {
+		if strings.TrimSpace(l) == line {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,88 @@
+package spt
+
+import (
+	"fmt"
+	"os"
+
+	"gitea.suyono.dev/suyono/simple-privacy-tool/archive"
+	"gitea.suyono.dev/suyono/simple-privacy-tool/archive/fusefs"
+	"gitea.suyono.dev/suyono/simple-privacy-tool/privacy"
+	tw "gitea.suyono.dev/suyono/terminal_wrapper"
+	"github.com/spf13/cobra"
+)
+
+var (
+	mountCmd = &cobra.Command{
+		Use: "mount archive mountpoint",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := processFlags(); err != nil {
+				return err
+			}
+			return mount(cmd, args)
+		},
+		Args:  cobra.ExactArgs(2),
+		Short: "mount an encrypted tar archive read-only, until interrupted or unmounted",
+	}
+)
+
+func init() {
+	mountCmd.PersistentFlags().IntVar(&f.attempts, "attempts", passphraseAttempts, "number of passphrase attempts before giving up")
+	rootCmd.AddCommand(mountCmd)
+}
+
+// openArchive asks for the passphrase and indexes the encrypted archive.
+func openArchive(src *os.File) (ra *privacy.ReaderAt, err error) {
+	var (
+		fi os.FileInfo
+	)
+
+	if fi, err = src.Stat(); err != nil {
+		return
+	}
+
+	ra = privacy.NewPrivacyReaderAt(src, fi.Size(), f.KeyGen())
+	if err = ra.ReadMagic(); err != nil {
+		return
+	}
+
+	err = withTerminal(func(terminal *tw.Terminal) error {
+		return unlock(terminal, ra.GenerateKey)
+	})
+	return
+}
+
+func mount(cmd *cobra.Command, args []string) (err error) {
+	var (
+		src  *os.File
+		ra   *privacy.ReaderAt
+		fsys *archive.FS
+	)
+
+	if src, err = os.Open(args[0]); err != nil {
+		return
+	}
+	defer func() {
+		_ = src.Close()
+	}()
+
+	if ra, err = openArchive(src); err != nil {
+		return
+	}
+
+	if fsys, err = archive.New(ra, ra.Size()); err != nil {
+		return fmt.Errorf("reading archive index: %w", err)
+	}
+
+	server, err := fusefs.Mount(args[1], fsys, args[0])
+	if err != nil {
+		return
+	}
+
+	go func() {
+		<-cmd.Context().Done()
+		_ = server.Unmount()
+	}()
+	server.Wait()
+
+	return nil
+}
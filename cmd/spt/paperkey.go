@@ -0,0 +1,153 @@
+package spt
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gitea.suyono.dev/suyono/simple-privacy-tool/paperkey"
+	"github.com/spf13/cobra"
+)
+
+var (
+	paperkeyTitle string
+	paperkeyQR    string
+
+	paperkeyCmd = &cobra.Command{
+		Use: "paperkey [file]",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := processFlags(); err != nil {
+				return err
+			}
+			return paperkeyExport(cmd, args)
+		},
+		Args:  cobra.MaximumNArgs(1),
+		Short: "render a key or a small encrypted file as a printable page with line checksums, output to STDOUT",
+	}
+
+	paperkeyRestoreCmd = &cobra.Command{
+		Use: "restore [pageFile [dstFile]]",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := processFlags(); err != nil {
+				return err
+			}
+			return paperkeyRestore(cmd, args)
+		},
+		Args:  cobra.MaximumNArgs(2),
+		Short: "reconstruct the data from the lines of a paper key page, or the text of its QR code",
+	}
+)
+
+func init() {
+	paperkeyCmd.Flags().StringVar(&paperkeyTitle, "title", "", "title printed on the page. Default is the file name")
+	paperkeyCmd.Flags().StringVar(&paperkeyQR, "qr", "", "also write a QR code of the data to this file, .svg or .png")
+	paperkeyCmd.AddCommand(paperkeyRestoreCmd)
+	rootCmd.AddCommand(paperkeyCmd)
+}
+
+func paperkeyExport(cmd *cobra.Command, args []string) (err error) {
+	var (
+		data  []byte
+		page  []byte
+		image []byte
+		title = paperkeyTitle
+	)
+
+	if len(args) == 1 && args[0] != "-" {
+		if title == "" {
+			title = filepath.Base(args[0])
+		}
+		if data, err = readLimited(args[0], paperkey.MaxSize); err != nil {
+			return
+		}
+	} else if data, err = readLimited("", paperkey.MaxSize); err != nil {
+		return
+	}
+
+	if page, err = paperkey.Encode(data, title); err != nil {
+		return
+	}
+
+	if paperkeyQR != "" {
+		switch strings.ToLower(filepath.Ext(paperkeyQR)) {
+		case ".svg":
+			image, err = paperkey.SVG(data)
+		case ".png":
+			image, err = paperkey.PNG(data)
+		default:
+			return errors.New("--qr needs a .svg or .png file name")
+		}
+		if err != nil {
+			return
+		}
+
+		if err = os.WriteFile(paperkeyQR, image, 0600); err != nil {
+			return
+		}
+	}
+
+	_, err = os.Stdout.Write(page)
+	return
+}
+
+func paperkeyRestore(cmd *cobra.Command, args []string) (err error) {
+	var (
+		page []byte
+		data []byte
+		src  string
+	)
+
+	if len(args) > 0 && args[0] != "-" {
+		src = args[0]
+	} else if fi, sErr := os.Stdin.Stat(); sErr == nil && fi.Mode()&os.ModeCharDevice != 0 {
+		_, _ = fmt.Fprintln(os.Stderr, "type the numbered lines and the sum line, or the text of the QR code, "+
+			"then press Ctrl-D")
+	}
+
+	// a page is about twice the size of its data, with comments
+	if page, err = readLimited(src, 4*paperkey.MaxSize); err != nil {
+		return
+	}
+
+	if data, err = paperkey.Decode(page); err != nil {
+		return
+	}
+
+	if len(args) == 2 && args[1] != "-" {
+		return os.WriteFile(args[1], data, 0600)
+	}
+
+	_, err = os.Stdout.Write(data)
+	return
+}
+
+// readLimited reads the file at path, or STDIN for an empty path, failing when it's larger than limit.
+func readLimited(path string, limit int64) (b []byte, err error) {
+	var (
+		r io.Reader = os.Stdin
+	)
+
+	if path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			_ = file.Close()
+		}()
+		r = file
+	}
+
+	if b, err = io.ReadAll(io.LimitReader(r, limit+1)); err != nil {
+		return
+	}
+
+	if int64(len(b)) > limit {
+		return nil, fmt.Errorf("%w: larger than %d bytes", paperkey.ErrTooLarge, limit)
+	}
+
+	return
+}
@@ -0,0 +1,98 @@
+package spt
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	progressBarWidth = 30
+	progressInterval = 200 * time.Millisecond
+)
+
+// progressBar renders the progress of an encryption or decryption on one line. When the size of the input is known,
+// it shows a bar, the percentage, and the estimated time left, otherwise only the bytes processed and the
+// throughput.
+type progressBar struct {
+	w     io.Writer
+	total int64
+	start time.Time
+	last  time.Time
+	bytes uint64
+}
+
+func newProgressBar(w io.Writer, src *os.File) *progressBar {
+	p := &progressBar{
+		w:     w,
+		total: -1,
+		start: time.Now(),
+	}
+
+	if fi, err := src.Stat(); err == nil && fi.Mode().IsRegular() {
+		p.total = fi.Size()
+	}
+
+	return p
+}
+
+func (p *progressBar) Update(bytes, _ uint64) {
+	p.bytes = bytes
+	if now := time.Now(); now.Sub(p.last) >= progressInterval {
+		p.last = now
+		p.render(now)
+	}
+}
+
+// Finish renders the last state and ends the line.
+func (p *progressBar) Finish() {
+	p.render(time.Now())
+	_, _ = fmt.Fprint(p.w, "\n")
+}
+
+func (p *progressBar) render(now time.Time) {
+	var (
+		line strings.Builder
+		rate float64
+	)
+
+	if elapsed := now.Sub(p.start).Seconds(); elapsed > 0 {
+		rate = float64(p.bytes) / elapsed
+	}
+
+	if p.total > 0 {
+		ratio := min(float64(p.bytes)/float64(p.total), 1)
+		filled := int(ratio * progressBarWidth)
+		line.WriteString("[" + strings.Repeat("=", filled) + strings.Repeat(" ", progressBarWidth-filled) + "] ")
+		_, _ = fmt.Fprintf(&line, "%3.0f%% %s/%s", ratio*100, formatBytes(float64(p.bytes)), formatBytes(float64(p.total)))
+	} else {
+		line.WriteString(formatBytes(float64(p.bytes)))
+	}
+
+	_, _ = fmt.Fprintf(&line, " %s/s", formatBytes(rate))
+
+	if p.total > 0 && rate > 0 && int64(p.bytes) < p.total {
+		eta := time.Duration(float64(p.total-int64(p.bytes)) / rate * float64(time.Second))
+		_, _ = fmt.Fprintf(&line, " ETA %s", eta.Round(time.Second))
+	}
+
+	_, _ = fmt.Fprintf(p.w, "\r%-80s", line.String())
+}
+
+func formatBytes(b float64) string {
+	const unit = 1024
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+
+	i := 0
+	for b >= unit && i < len(units)-1 {
+		b /= unit
+		i++
+	}
+
+	if i == 0 {
+		return fmt.Sprintf("%.0f %s", b, units[i])
+	}
+	return fmt.Sprintf("%.1f %s", b, units[i])
+}
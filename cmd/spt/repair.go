@@ -0,0 +1,97 @@
+package spt
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gitea.suyono.dev/suyono/simple-privacy-tool/privacy"
+	"github.com/spf13/cobra"
+)
+
+var (
+	repairCmd = &cobra.Command{
+		Use: "repair file [dstFile]",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := processFlags(); err != nil {
+				return err
+			}
+			return repair(cmd, args)
+		},
+		Args:  cobra.RangeArgs(1, 2),
+		Short: "rebuild the damaged parts of a file encrypted with --ecc, in place or into dstFile",
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(repairCmd)
+}
+
+// repair rewrites the file through the ECC layer. In place, the file is only replaced when there was damage, by
+// renaming a repaired copy over it.
+func repair(cmd *cobra.Command, args []string) (err error) {
+	var (
+		src     *os.File
+		tmp     *os.File
+		fi      os.FileInfo
+		damaged int
+		dst     = args[0]
+	)
+
+	if len(args) == 2 {
+		dst = args[1]
+	}
+
+	if src, err = os.Open(args[0]); err != nil {
+		return
+	}
+	defer func() {
+		_ = src.Close()
+	}()
+
+	if fi, err = src.Stat(); err != nil {
+		return
+	}
+
+	if tmp, err = os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".*"); err != nil {
+		return
+	}
+	defer func() {
+		if tmp != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	if damaged, err = privacy.Repair(tmp, src); err != nil {
+		return fmt.Errorf("%s: %w", args[0], err)
+	}
+
+	if damaged == 0 {
+		_, _ = fmt.Fprintf(os.Stderr, "%s: no damage found\n", args[0])
+		if len(args) == 1 {
+			return
+		}
+	} else {
+		_, _ = fmt.Fprintf(os.Stderr, "%s: %d damaged blocks repaired\n", args[0], damaged)
+	}
+
+	if err = tmp.Chmod(fi.Mode().Perm()); err != nil {
+		return
+	}
+
+	if err = tmp.Sync(); err != nil {
+		return
+	}
+
+	if err = tmp.Close(); err != nil {
+		return
+	}
+
+	if err = os.Rename(tmp.Name(), dst); err != nil {
+		return
+	}
+	tmp = nil
+
+	return
+}
@@ -0,0 +1,79 @@
+package spt
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+
+	"gitea.suyono.dev/suyono/simple-privacy-tool/privacy"
+	"gitea.suyono.dev/suyono/simple-privacy-tool/server"
+	"github.com/spf13/cobra"
+)
+
+var (
+	serveListen  string
+	serveKeyDir  string
+	serveMaxSize string
+
+	serveCmd = &cobra.Command{
+		Use: "serve",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := processFlags(); err != nil {
+				return err
+			}
+			return serve(cmd, args)
+		},
+		Args:  cobra.NoArgs,
+		Short: "serve POST /encrypt and POST /decrypt over HTTP, until interrupted",
+	}
+)
+
+func init() {
+	serveCmd.Flags().StringVar(&serveListen, "listen", "unix:spt.sock", "listen address, unix:PATH")
+	serveCmd.Flags().StringVar(&serveKeyDir, "key-dir", "", "directory of passphrase files, selected by the X-Spt-Key header")
+	serveCmd.Flags().StringVar(&serveMaxSize, "max-size", "1GiB", "largest request body accepted, 0 for no limit")
+	// the format of /encrypt responses
+	serveCmd.Flags().StringVar(&f.compress, "compress", noCompression, "compress before encryption, valid values: none, gzip, and zstd")
+	serveCmd.Flags().IntVar(&f.compressLevel, "compress-level", privacy.DefaultCompressionLevel, "compression level, 1-9 for gzip and 1-22 for zstd")
+	serveCmd.Flags().StringVar(&f.pad, "pad", "none", "pad the plain text to hide its length, valid values: none, padme, block:SIZE, and fixed:SIZE")
+	serveCmd.Flags().BoolVar(&f.hideLength, "hide-length", false, "encrypt the segment length prefixes")
+	serveCmd.Flags().StringVar(&f.algo, "algo", defaultAlgo, "encryption algorithm, valid values: chacha and aes. Default algo is chacha")
+	rootCmd.AddCommand(serveCmd)
+}
+
+func serve(cmd *cobra.Command, args []string) (err error) {
+	var (
+		maxSize uint64
+		l       net.Listener
+	)
+
+	if maxSize, err = privacy.ParseSize(serveMaxSize); err != nil {
+		return fmt.Errorf("invalid max-size: %w", err)
+	}
+
+	s := server.New(f.KeyGen())
+	s.KeyDir = serveKeyDir
+	s.MaxSize = int64(maxSize)
+	s.EncryptOptions = f.EncryptOptions()
+
+	if l, err = server.Listen(serveListen); err != nil {
+		return
+	}
+
+	hs := &http.Server{
+		Handler: s,
+	}
+	go func() {
+		<-cmd.Context().Done()
+		_ = hs.Close()
+	}()
+
+	_, _ = fmt.Fprintln(os.Stderr, "listening on", l.Addr())
+	if err = hs.Serve(l); errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return
+}
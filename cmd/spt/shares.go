@@ -0,0 +1,200 @@
+package spt
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+
+	"gitea.suyono.dev/suyono/simple-privacy-tool/privacy"
+	"gitea.suyono.dev/suyono/simple-privacy-tool/shamir"
+	tw "gitea.suyono.dev/suyono/terminal_wrapper"
+	"github.com/spf13/cobra"
+)
+
+const (
+	dataKeyLen = 32
+)
+
+var (
+	combineCmd = &cobra.Command{
+		Use: "combine share...",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := processFlags(); err != nil {
+				return err
+			}
+			return combine(cmd, args)
+		},
+		Args:  cobra.MinimumNArgs(1),
+		Short: "combine the share files written by encrypt --shares, print the key for decrypt --key-stdin",
+	}
+)
+
+func init() {
+	combineCmd.Flags().IntVar(&f.attempts, "attempts", passphraseAttempts, "number of passphrase attempts before giving up, for protected shares")
+	rootCmd.AddCommand(combineCmd)
+}
+
+// shareFile is a share to be written next to the encrypted file.
+type shareFile struct {
+	path       string
+	share      shamir.Share
+	passphrase string
+}
+
+// prepareShares replaces the passphrase with a random data key, split into --shares shares. The share files are
+// named after dstFile, and with --protect-shares get a passphrase each.
+func (e *encryptApp) prepareShares() (err error) {
+	if e.dstFile == os.Stdout {
+		return errors.New("--shares needs a destination file")
+	}
+
+	key := make([]byte, dataKeyLen)
+	if _, err = rand.Read(key); err != nil {
+		return
+	}
+	shares, err := shamir.SplitKey(key, f.Shares(), f.Threshold())
+	if err != nil {
+		return
+	}
+	e.passphrase = string(key)
+	clear(key)
+
+	for i, share := range shares {
+		sf := shareFile{
+			path:  e.dstPath + ".share" + strconv.Itoa(i+1),
+			share: share,
+		}
+
+		if _, sErr := os.Lstat(sf.path); sErr == nil {
+			return fmt.Errorf("%s: %w", sf.path, fs.ErrExist)
+		}
+
+		if f.ProtectShares() {
+			_, _ = fmt.Fprintf(e.term, "passphrase for %s\n", sf.path)
+			if sf.passphrase, err = readNewPassphrase(e.term); err != nil {
+				return
+			}
+		}
+		e.shares = append(e.shares, sf)
+	}
+
+	return
+}
+
+// writeShares writes the share files, protected shares as ASCII armored encrypted files.
+func (e *encryptApp) writeShares() (err error) {
+	var (
+		b []byte
+	)
+
+	for _, sf := range e.shares {
+		if b, err = sf.share.MarshalText(); err != nil {
+			return
+		}
+		b = append(b, '\n')
+
+		if sf.passphrase != "" {
+			if b, err = privacy.EncryptBytes(b, privacy.WithPassphrase(sf.passphrase), privacy.WithKeyGen(f.KeyGen()),
+				privacy.WithArmor(true)); err != nil {
+				return
+			}
+		}
+
+		file, err := os.OpenFile(sf.path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		_, err = file.Write(b)
+		if cErr := file.Close(); err == nil {
+			err = cErr
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return
+}
+
+// keyGen returns the KeyGen of the encrypted file: HKDF for a random data key, which needs no stretching.
+func (e *encryptApp) keyGen() privacy.KeyGen {
+	if len(e.shares) > 0 {
+		return privacy.NewHKDF()
+	}
+
+	return f.KeyGen()
+}
+
+func combine(cmd *cobra.Command, args []string) (err error) {
+	var (
+		shares []shamir.Share
+		key    []byte
+	)
+
+	for _, path := range args {
+		var (
+			share shamir.Share
+			b     []byte
+		)
+
+		if b, err = os.ReadFile(path); err != nil {
+			return
+		}
+
+		if _, _, dErr := privacy.Detect(bytes.NewReader(b)); dErr == nil {
+			if b, err = openShare(path, b); err != nil {
+				return
+			}
+		}
+
+		if err = share.UnmarshalText(b); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		shares = append(shares, share)
+	}
+
+	if key, err = shamir.CombineKey(shares); err != nil {
+		return
+	}
+
+	_, err = fmt.Println(base64.StdEncoding.EncodeToString(key))
+	return
+}
+
+// openShare decrypts a share protected with --protect-shares.
+func openShare(path string, b []byte) (plain []byte, err error) {
+	err = withTerminal(func(terminal *tw.Terminal) error {
+		_, _ = fmt.Fprintf(terminal, "%s is protected\n", path)
+		return unlock(terminal, func(passphrase string) (err error) {
+			plain, err = privacy.DecryptBytes(b, privacy.WithPassphrase(passphrase), privacy.WithKeyGen(f.KeyGen()))
+			return
+		})
+	})
+
+	return
+}
+
+// readKeyStdin reads the data key printed by combine.
+func readKeyStdin() (key string, err error) {
+	var (
+		line string
+		b    []byte
+	)
+
+	if line, err = bufio.NewReader(os.Stdin).ReadString('\n'); err != nil && line == "" {
+		return "", fmt.Errorf("reading the key from STDIN: %w", err)
+	}
+
+	if b, err = base64.StdEncoding.DecodeString(strings.TrimSpace(line)); err != nil || len(b) == 0 {
+		return "", errors.New("invalid key on STDIN")
+	}
+
+	return string(b), nil
+}
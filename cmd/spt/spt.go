@@ -1,21 +1,27 @@
 package spt
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"os"
+	"os/signal"
 
+	"gitea.suyono.dev/suyono/simple-privacy-tool/privacy"
 	tw "gitea.suyono.dev/suyono/terminal_wrapper"
 	"github.com/spf13/cobra"
 	"log"
 )
 
 type cApp struct {
+	ctx        context.Context
 	term       *tw.Terminal
 	srcPath    string
 	dstPath    string
 	srcFile    *os.File
 	dstFile    *os.File
 	passphrase string
+	fromAgent  []string
 }
 
 var (
@@ -35,32 +41,47 @@ var (
 	}
 
 	encryptCmd = &cobra.Command{
-		Use:  "encrypt srcFile dstFile",
+		Use:  "encrypt [srcFile dstFile | -r dir... | file...]",
 		Args: validatePositionalArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if err := processFlags(); err != nil {
 				return err
 			}
+			if isBatch(cmd, args) {
+				return encryptBatch(cmd, args)
+			}
+			if err := checkPair(args, true); err != nil {
+				return err
+			}
 			return encrypt(cmd, args)
 		},
-		Short: "encrypt srcFile, output to dstFile",
+		Short: "encrypt srcFile, output to dstFile, or many files, each to file+suffix",
 	}
 
 	decryptCmd = &cobra.Command{
-		Use: "decrypt srcFile dstFile",
+		Use: "decrypt [srcFile dstFile | -r dir... | file...]",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if err := processFlags(); err != nil {
 				return err
 			}
+			if isBatch(cmd, args) {
+				return decryptBatch(cmd, args)
+			}
+			if err := checkPair(args, false); err != nil {
+				return err
+			}
 			return decrypt(cmd, args)
 		},
 		Args:  validatePositionalArgs,
-		Short: "decrypt srcFile, output to dstFile",
+		Short: "decrypt srcFile, output to dstFile, or many files, each without the suffix",
 	}
 )
 
 func Execute() error {
-	return rootCmd.Execute()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	return rootCmd.ExecuteContext(ctx)
 }
 
 func init() {
@@ -69,6 +90,13 @@ func init() {
 }
 
 func validatePositionalArgs(cmd *cobra.Command, args []string) error {
+	if isBatch(cmd, args) {
+		if len(args) == 0 {
+			return errors.New("batch mode needs files or directories")
+		}
+		return nil
+	}
+
 	if len(args) != 0 && len(args) != 2 {
 		//TODO: improve the error message
 		return errors.New("invalid arguments")
@@ -97,6 +125,7 @@ func encrypt(cmd *cobra.Command, args []string) (err error) {
 	}()
 
 	app = &cApp{
+		ctx:  cmd.Context(),
 		term: terminal,
 	}
 
@@ -108,10 +137,26 @@ func encrypt(cmd *cobra.Command, args []string) (err error) {
 		cApp: *app,
 	}
 
-	if err = eApp.GetPassphrase(); err != nil {
+	if err = eApp.prepareAppend(); err != nil {
+		return
+	}
+
+	if err = eApp.prepareShred(); err != nil {
 		return
 	}
 
+	if f.Shares() > 0 {
+		if err = eApp.prepareShares(); err != nil {
+			return
+		}
+	} else {
+		eApp.fromAgent = agentPassphrases(eApp.appending)
+
+		if err = eApp.GetPassphrase(); err != nil {
+			return
+		}
+	}
+
 	if err = eApp.ProcessFiles(); err != nil {
 		return
 	}
@@ -139,6 +184,7 @@ func decrypt(cmd *cobra.Command, args []string) (err error) {
 	}()
 
 	app = &cApp{
+		ctx:  cmd.Context(),
 		term: terminal,
 	}
 
@@ -150,8 +196,19 @@ func decrypt(cmd *cobra.Command, args []string) (err error) {
 		cApp: *app,
 	}
 
-	if err = dApp.GetPassphrase(); err != nil {
-		return
+	if f.KeyStdin() {
+		if dApp.srcFile == os.Stdin {
+			return errors.New("--key-stdin needs a source file")
+		}
+		if dApp.passphrase, err = readKeyStdin(); err != nil {
+			return
+		}
+	} else {
+		dApp.fromAgent = agentPassphrases(true)
+
+		if err = dApp.GetPassphrase(); err != nil {
+			return
+		}
 	}
 
 	if err = dApp.ProcessFiles(); err != nil {
@@ -161,6 +218,63 @@ func decrypt(cmd *cobra.Command, args []string) (err error) {
 	return nil
 }
 
+// withTerminal runs fn with the terminal prepared for passphrase prompts, and restores the terminal afterwards.
+func withTerminal(fn func(terminal *tw.Terminal) error) (err error) {
+	var (
+		terminal *tw.Terminal
+	)
+
+	if terminal, err = tw.MakeTerminal(os.Stderr); err != nil {
+		return
+	}
+	log.SetOutput(terminal)
+	defer func() {
+		existingErr := err
+		if err = terminal.Restore(); err == nil && existingErr != nil {
+			err = existingErr
+		}
+		log.SetOutput(os.Stderr)
+	}()
+
+	return fn(terminal)
+}
+
+// unlock asks for the passphrase until try accepts it, or the attempts allowed by --attempts run out.
+func unlock(terminal *tw.Terminal, try func(passphrase string) error) (err error) {
+	var (
+		passphrase string
+	)
+
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 {
+			_, _ = fmt.Fprintln(terminal, "wrong passphrase, try again")
+		}
+
+		if passphrase, err = terminal.ReadPassword("input passphrase: "); err != nil {
+			return
+		}
+
+		if err = try(passphrase); err == nil || !errors.Is(err, privacy.ErrWrongPassphrase) || attempt >= f.Attempts() {
+			return
+		}
+	}
+}
+
+// progressOptions returns the options for cancellation and, if requested, the progress bar. The returned function
+// ends the progress bar line and has to be called when processing is done.
+func (a *cApp) progressOptions() (opts []privacy.Option, done func()) {
+	opts = []privacy.Option{privacy.WithContext(a.ctx)}
+	done = func() {}
+
+	if f.ShowProgress() {
+		bar := newProgressBar(a.term, a.srcFile)
+		opts = append(opts, privacy.WithProgress(bar))
+		done = bar.Finish
+	}
+
+	return
+}
+
 func (a *cApp) ProcessArgs(args []string) (err error) {
 	if len(args) == 0 {
 		a.srcFile = os.Stdin
@@ -179,7 +293,11 @@ func (a *cApp) ProcessArgs(args []string) (err error) {
 		if a.dstPath == "-" {
 			a.dstFile = os.Stdout
 		} else {
-			if a.dstFile, err = os.OpenFile(a.dstPath, os.O_CREATE|os.O_WRONLY, 0640); err != nil { //TODO: allow user to define the destination file permission
+			flag := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+			if f.Append() {
+				flag = os.O_CREATE | os.O_RDWR | os.O_APPEND
+			}
+			if a.dstFile, err = os.OpenFile(a.dstPath, flag, 0640); err != nil { //TODO: allow user to define the destination file permission
 				return
 			}
 		}
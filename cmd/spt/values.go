@@ -0,0 +1,185 @@
+package spt
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"gitea.suyono.dev/suyono/simple-privacy-tool/privacy"
+	"gitea.suyono.dev/suyono/simple-privacy-tool/values"
+	tw "gitea.suyono.dev/suyono/terminal_wrapper"
+	"github.com/spf13/cobra"
+)
+
+const (
+	valuesAutoFormat = "auto"
+	valuesYAMLFormat = "yaml"
+	valuesJSONFormat = "json"
+)
+
+var (
+	valuesFormat  string
+	valuesInPlace bool
+
+	encryptValuesCmd = &cobra.Command{
+		Use: "encrypt-values file",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := processFlags(); err != nil {
+				return err
+			}
+			return encryptValues(cmd, args)
+		},
+		Args:  cobra.ExactArgs(1),
+		Short: "encrypt the values of a YAML or JSON file, keeping the keys readable, output to STDOUT",
+	}
+
+	decryptValuesCmd = &cobra.Command{
+		Use: "decrypt-values file",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := processFlags(); err != nil {
+				return err
+			}
+			return decryptValues(cmd, args)
+		},
+		Args:  cobra.ExactArgs(1),
+		Short: "decrypt a file written by encrypt-values, output to STDOUT",
+	}
+)
+
+func init() {
+	for _, cmd := range []*cobra.Command{encryptValuesCmd, decryptValuesCmd} {
+		cmd.Flags().StringVar(&valuesFormat, "format", valuesAutoFormat, "file format, valid values: auto, yaml, and json. auto takes json for .json files, yaml otherwise")
+		cmd.Flags().BoolVarP(&valuesInPlace, "in-place", "i", false, "replace the file instead of writing to STDOUT")
+	}
+	encryptValuesCmd.Flags().StringVar(&f.algo, "algo", defaultAlgo, "encryption algorithm, valid values: chacha and aes. Default algo is chacha")
+	decryptValuesCmd.Flags().IntVar(&f.attempts, "attempts", passphraseAttempts, "number of passphrase attempts before giving up")
+	rootCmd.AddCommand(encryptValuesCmd, decryptValuesCmd)
+}
+
+func valuesFileFormat(path string) (values.Format, error) {
+	switch valuesFormat {
+	case valuesAutoFormat:
+		return values.FormatFromName(path), nil
+	case valuesYAMLFormat:
+		return values.YAMLFormat, nil
+	case valuesJSONFormat:
+		return values.JSONFormat, nil
+	default:
+		return values.YAMLFormat, errors.New("invalid format")
+	}
+}
+
+func encryptValues(cmd *cobra.Command, args []string) (err error) {
+	var (
+		format     values.Format
+		doc        []byte
+		out        []byte
+		passphrase string
+	)
+
+	if format, err = valuesFileFormat(args[0]); err != nil {
+		return
+	}
+
+	if doc, err = os.ReadFile(args[0]); err != nil {
+		return
+	}
+
+	if fromAgent := agentPassphrases(false); len(fromAgent) > 0 {
+		passphrase = fromAgent[0]
+	} else if err = withTerminal(func(terminal *tw.Terminal) (err error) {
+		passphrase, err = readNewPassphrase(terminal)
+		return
+	}); err != nil {
+		return
+	}
+
+	if out, err = values.Encrypt(doc, format, passphrase, f.CipherMethod(), f.KeyGen()); err != nil {
+		return
+	}
+
+	return writeValues(args[0], out)
+}
+
+func decryptValues(cmd *cobra.Command, args []string) (err error) {
+	var (
+		format values.Format
+		doc    []byte
+		out    []byte
+	)
+
+	if format, err = valuesFileFormat(args[0]); err != nil {
+		return
+	}
+
+	if doc, err = os.ReadFile(args[0]); err != nil {
+		return
+	}
+
+	try := func(passphrase string) (err error) {
+		out, err = values.Decrypt(doc, format, passphrase)
+		return
+	}
+
+	err = privacy.ErrWrongPassphrase
+	for _, p := range agentPassphrases(true) {
+		if err = try(p); !errors.Is(err, privacy.ErrWrongPassphrase) {
+			break
+		}
+	}
+	if errors.Is(err, privacy.ErrWrongPassphrase) {
+		err = withTerminal(func(terminal *tw.Terminal) error {
+			return unlock(terminal, try)
+		})
+	}
+	if err != nil {
+		return
+	}
+
+	return writeValues(args[0], out)
+}
+
+// writeValues writes out to STDOUT, or with --in-place replaces the file at path, keeping its permissions.
+func writeValues(path string, out []byte) (err error) {
+	var (
+		fi  os.FileInfo
+		tmp *os.File
+	)
+
+	if !valuesInPlace {
+		_, err = os.Stdout.Write(out)
+		return
+	}
+
+	if fi, err = os.Stat(path); err != nil {
+		return
+	}
+
+	if tmp, err = os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*"); err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err = tmp.Write(out); err != nil {
+		return
+	}
+
+	if err = tmp.Chmod(fi.Mode().Perm()); err != nil {
+		return
+	}
+
+	if err = tmp.Sync(); err != nil {
+		return
+	}
+
+	if err = tmp.Close(); err != nil {
+		return
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
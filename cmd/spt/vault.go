@@ -0,0 +1,175 @@
+package spt
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"gitea.suyono.dev/suyono/simple-privacy-tool/vault"
+	tw "gitea.suyono.dev/suyono/terminal_wrapper"
+	"github.com/spf13/cobra"
+)
+
+var (
+	vaultCmd = &cobra.Command{
+		Use:   "vault",
+		Short: "keep files in an encrypted vault directory, one encrypted file per file, with encrypted names",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return processFlags()
+		},
+	}
+
+	vaultInitCmd = &cobra.Command{
+		Use:   "init dir",
+		Args:  cobra.ExactArgs(1),
+		RunE:  vaultInit,
+		Short: "create a new vault in dir",
+	}
+
+	vaultAddCmd = &cobra.Command{
+		Use:   "add dir file [name]",
+		Args:  cobra.RangeArgs(2, 3),
+		RunE:  vaultAdd,
+		Short: "encrypt file into the vault as name, the base name of file by default. Use - to read STDIN",
+	}
+
+	vaultGetCmd = &cobra.Command{
+		Use:   "get dir name [dstFile]",
+		Args:  cobra.RangeArgs(2, 3),
+		RunE:  vaultGet,
+		Short: "decrypt name from the vault to dstFile, or STDOUT",
+	}
+
+	vaultRmCmd = &cobra.Command{
+		Use:   "rm dir name",
+		Args:  cobra.ExactArgs(2),
+		RunE:  vaultRm,
+		Short: "remove name from the vault",
+	}
+
+	vaultLsCmd = &cobra.Command{
+		Use:   "ls dir",
+		Args:  cobra.ExactArgs(1),
+		RunE:  vaultLs,
+		Short: "list the files in the vault",
+	}
+)
+
+func init() {
+	vaultCmd.PersistentFlags().IntVar(&f.attempts, "attempts", passphraseAttempts, "number of passphrase attempts before giving up")
+	vaultCmd.AddCommand(vaultInitCmd, vaultAddCmd, vaultGetCmd, vaultRmCmd, vaultLsCmd)
+	rootCmd.AddCommand(vaultCmd)
+}
+
+func openVault(dir string) (v *vault.Vault, err error) {
+	err = withTerminal(func(terminal *tw.Terminal) error {
+		return unlock(terminal, func(passphrase string) (err error) {
+			v, err = vault.Open(dir, passphrase)
+			return
+		})
+	})
+
+	return
+}
+
+func vaultInit(cmd *cobra.Command, args []string) error {
+	return withTerminal(func(terminal *tw.Terminal) error {
+		passphrase, err := readNewPassphrase(terminal)
+		if err != nil {
+			return err
+		}
+
+		return vault.Init(args[0], passphrase, f.KeyGen())
+	})
+}
+
+func vaultAdd(cmd *cobra.Command, args []string) (err error) {
+	var (
+		v    *vault.Vault
+		src  *os.File
+		name string
+	)
+
+	if len(args) == 3 {
+		name = args[2]
+	} else if args[1] == "-" {
+		return fmt.Errorf("a name is required when reading STDIN")
+	} else {
+		name = filepath.Base(args[1])
+	}
+
+	if args[1] == "-" {
+		src = os.Stdin
+	} else {
+		if src, err = os.Open(args[1]); err != nil {
+			return
+		}
+		defer func() {
+			_ = src.Close()
+		}()
+	}
+
+	if v, err = openVault(args[0]); err != nil {
+		return
+	}
+
+	return v.Add(name, src)
+}
+
+func vaultGet(cmd *cobra.Command, args []string) (err error) {
+	var (
+		v   *vault.Vault
+		dst io.WriteCloser = os.Stdout
+	)
+
+	if v, err = openVault(args[0]); err != nil {
+		return
+	}
+
+	if len(args) == 3 && args[2] != "-" {
+		if dst, err = os.OpenFile(args[2], os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600); err != nil {
+			return
+		}
+		defer func() {
+			if cErr := dst.Close(); err == nil {
+				err = cErr
+			}
+		}()
+	}
+
+	return v.Get(args[1], dst)
+}
+
+func vaultRm(cmd *cobra.Command, args []string) (err error) {
+	var (
+		v *vault.Vault
+	)
+
+	if v, err = openVault(args[0]); err != nil {
+		return
+	}
+
+	return v.Remove(args[1])
+}
+
+func vaultLs(cmd *cobra.Command, args []string) (err error) {
+	var (
+		v     *vault.Vault
+		names []string
+	)
+
+	if v, err = openVault(args[0]); err != nil {
+		return
+	}
+
+	if names, err = v.List(); err != nil {
+		return
+	}
+
+	for _, name := range names {
+		fmt.Println(name)
+	}
+
+	return
+}
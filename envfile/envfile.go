@@ -0,0 +1,311 @@
+// Package envfile parses the key-value files holding environment variables: dotenv, a flat JSON object, or a flat
+// YAML mapping. Values are taken literally, there is no variable expansion or command substitution.
+package envfile
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Format byte
+
+const (
+	AutoFormat Format = iota
+	DotenvFormat
+	JSONFormat
+	YAMLFormat
+)
+
+var (
+	ErrInvalidFormat = errors.New("invalid env file format")
+	ErrInvalidName   = errors.New("invalid variable name")
+	ErrNotFlat       = errors.New("env file values must be scalars")
+)
+
+func (f Format) String() string {
+	switch f {
+	case AutoFormat:
+		return "auto"
+	case DotenvFormat:
+		return "dotenv"
+	case JSONFormat:
+		return "json"
+	case YAMLFormat:
+		return "yaml"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseFormat parses the names returned by Format.String.
+func ParseFormat(s string) (Format, error) {
+	for _, f := range []Format{AutoFormat, DotenvFormat, JSONFormat, YAMLFormat} {
+		if s == f.String() {
+			return f, nil
+		}
+	}
+
+	return AutoFormat, ErrInvalidFormat
+}
+
+// FormatFromName guesses the format from the extension of name, ignoring a trailing .spt: .json and .yaml or .yml,
+// AutoFormat otherwise.
+func FormatFromName(name string) Format {
+	name = strings.TrimSuffix(name, ".spt")
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json":
+		return JSONFormat
+	case ".yaml", ".yml":
+		return YAMLFormat
+	default:
+		return AutoFormat
+	}
+}
+
+// Parse returns the variables in b. AutoFormat takes a JSON object for JSON, and anything else for dotenv.
+func Parse(b []byte, format Format) (vars map[string]string, err error) {
+	if format == AutoFormat {
+		format = DotenvFormat
+		if t := bytes.TrimSpace(b); len(t) > 0 && t[0] == '{' {
+			format = JSONFormat
+		}
+	}
+
+	switch format {
+	case DotenvFormat:
+		vars, err = parseDotenv(b)
+	case JSONFormat:
+		vars, err = parseJSON(b)
+	case YAMLFormat:
+		vars, err = parseYAML(b)
+	default:
+		return nil, ErrInvalidFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for name := range vars {
+		if !validName(name) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidName, name)
+		}
+	}
+
+	return vars, nil
+}
+
+// Environ returns base, in the form of os.Environ, with vars added. The variables in vars replace those of the same
+// name in base.
+func Environ(base []string, vars map[string]string) []string {
+	env := make([]string, 0, len(base)+len(vars))
+	for _, kv := range base {
+		name, _, _ := strings.Cut(kv, "=")
+		if _, ok := vars[name]; !ok {
+			env = append(env, kv)
+		}
+	}
+
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		env = append(env, name+"="+vars[name])
+	}
+
+	return env
+}
+
+func validName(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	for i, c := range name {
+		switch {
+		case c == '_', c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// parseDotenv reads lines of NAME=value, optionally prefixed with export. Blank lines and lines starting with # are
+// skipped. Values may be single quoted, taken literally, or double quoted, where \n, \t, \", and \\ are escapes and
+// the value may span lines. Unquoted values end at a # preceded by a space, and are trimmed.
+func parseDotenv(b []byte) (vars map[string]string, err error) {
+	var (
+		lineNo int
+	)
+
+	vars = make(map[string]string)
+	s := bufio.NewScanner(bytes.NewReader(b))
+	s.Buffer(nil, len(b)+1)
+	for s.Scan() {
+		lineNo++
+		line := strings.TrimSpace(s.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%w: line %d: missing =", ErrInvalidFormat, lineNo)
+		}
+		name = strings.TrimSpace(name)
+		value = strings.TrimSpace(value)
+
+		switch {
+		case strings.HasPrefix(value, "'"):
+			end := strings.IndexByte(value[1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("%w: line %d: unterminated quote", ErrInvalidFormat, lineNo)
+			}
+			value = value[1 : end+1]
+		case strings.HasPrefix(value, `"`):
+			// a double quoted value continues on the next lines until the closing quote
+			quoted := value[1:]
+			for {
+				if v, ok := unquote(quoted); ok {
+					value = v
+					break
+				}
+				if !s.Scan() {
+					return nil, fmt.Errorf("%w: line %d: unterminated quote", ErrInvalidFormat, lineNo)
+				}
+				lineNo++
+				quoted += "\n" + s.Text()
+			}
+		default:
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = strings.TrimSpace(value[:i])
+			}
+		}
+
+		vars[name] = value
+	}
+
+	if err = s.Err(); err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+// unquote returns the content of s up to the first unescaped double quote, with the escapes replaced.
+func unquote(s string) (string, bool) {
+	var (
+		sb strings.Builder
+	)
+
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"':
+			return sb.String(), true
+		case '\\':
+			if i+1 == len(s) {
+				sb.WriteByte(c)
+				continue
+			}
+			i++
+			switch s[i] {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			case 'r':
+				sb.WriteByte('\r')
+			case '"', '\\':
+				sb.WriteByte(s[i])
+			default:
+				sb.WriteByte('\\')
+				sb.WriteByte(s[i])
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+
+	return "", false
+}
+
+func parseJSON(b []byte) (vars map[string]string, err error) {
+	var (
+		m map[string]any
+	)
+
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err = d.Decode(&m); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidFormat, err)
+	}
+
+	vars = make(map[string]string, len(m))
+	for name, v := range m {
+		switch v := v.(type) {
+		case string:
+			vars[name] = v
+		case json.Number:
+			vars[name] = v.String()
+		case bool:
+			vars[name] = fmt.Sprint(v)
+		case nil:
+			vars[name] = ""
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrNotFlat, name)
+		}
+	}
+
+	return
+}
+
+// parseYAML keeps the scalars as written, e.g. 010 stays 010 instead of becoming 8.
+func parseYAML(b []byte) (vars map[string]string, err error) {
+	var (
+		doc yaml.Node
+	)
+
+	if err = yaml.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidFormat, err)
+	}
+
+	vars = make(map[string]string)
+	if len(doc.Content) == 0 {
+		return
+	}
+
+	m := doc.Content[0]
+	if m.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%w: not a mapping", ErrInvalidFormat)
+	}
+
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		name, v := m.Content[i].Value, m.Content[i+1]
+		if v.Kind == yaml.AliasNode {
+			v = v.Alias
+		}
+		if v.Kind != yaml.ScalarNode {
+			return nil, fmt.Errorf("%w: %s", ErrNotFlat, name)
+		}
+		if v.Tag == "!!null" {
+			vars[name] = ""
+			continue
+		}
+		vars[name] = v.Value
+	}
+
+	return
+}
@@ -0,0 +1,104 @@
+package envfile
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	want := map[string]string{
+		"DB_URL":  "postgres://u:p@host/db?x=1",
+		"TOKEN":   "a#b",
+		"EMPTY":   "",
+		"PORT":    "010",
+		"DEBUG":   "true",
+		"MULTI":   "line 1\nline 2",
+		"LITERAL": `$HOME\n`,
+	}
+
+	for _, tc := range []struct {
+		name   string
+		format Format
+		input  string
+	}{
+		{"dotenv", DotenvFormat, `
+# comment
+DB_URL=postgres://u:p@host/db?x=1
+export TOKEN=a#b # the token
+EMPTY=
+PORT = 010
+DEBUG="true"
+MULTI="line 1
+line 2"
+LITERAL='$HOME\n'
+`},
+		{"json", AutoFormat, `{"DB_URL": "postgres://u:p@host/db?x=1", "TOKEN": "a#b", "EMPTY": null, "PORT": "010",
+"DEBUG": true, "MULTI": "line 1\nline 2", "LITERAL": "$HOME\\n"}`},
+		{"yaml", YAMLFormat, `
+DB_URL: postgres://u:p@host/db?x=1
+TOKEN: "a#b"
+EMPTY:
+PORT: 010
+DEBUG: true
+MULTI: |-
+  line 1
+  line 2
+LITERAL: '$HOME\n'
+`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			vars, err := Parse([]byte(tc.input), tc.format)
+			if err != nil {
+				t.Fatal("unexpected: Parse failed", err)
+			}
+			if !reflect.DeepEqual(vars, want) {
+				t.Fatal("unexpected: Parse returned", vars)
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		format Format
+		input  string
+		err    error
+	}{
+		{"no equal sign", DotenvFormat, "NAME\n", ErrInvalidFormat},
+		{"unterminated", DotenvFormat, "NAME=\"value\n", ErrInvalidFormat},
+		{"bad name", DotenvFormat, "1NAME=x\n", ErrInvalidName},
+		{"nested json", JSONFormat, `{"A": {"B": "c"}}`, ErrNotFlat},
+		{"json array", JSONFormat, `["A"]`, ErrInvalidFormat},
+		{"nested yaml", YAMLFormat, "A:\n  B: c\n", ErrNotFlat},
+		{"yaml list", YAMLFormat, "- A\n", ErrInvalidFormat},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := Parse([]byte(tc.input), tc.format); !errors.Is(err, tc.err) {
+				t.Fatal("unexpected: Parse returned", err)
+			}
+		})
+	}
+}
+
+func TestFormatFromName(t *testing.T) {
+	for name, want := range map[string]Format{
+		"secrets.env.spt":  AutoFormat,
+		"secrets.json.spt": JSONFormat,
+		"secrets.YML":      YAMLFormat,
+		"secrets.yaml.spt": YAMLFormat,
+	} {
+		if got := FormatFromName(name); got != want {
+			t.Fatal("unexpected: FormatFromName", name, got)
+		}
+	}
+}
+
+func TestEnviron(t *testing.T) {
+	env := Environ([]string{"PATH=/bin", "TOKEN=old", "HOME=/root"}, map[string]string{"TOKEN": "new", "A": "b=c"})
+	want := []string{"PATH=/bin", "HOME=/root", "A=b=c", "TOKEN=new"}
+	if !reflect.DeepEqual(env, want) {
+		t.Fatal("unexpected: Environ returned", env)
+	}
+}
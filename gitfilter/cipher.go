@@ -0,0 +1,112 @@
+// Package gitfilter encrypts files in a git repository transparently, as a clean/smudge filter. Files are encrypted
+// with a random repository key, kept outside the repository, using the HKDF KeyGen: the key is already random, and
+// a slow password hash for every file would make git unusable.
+//
+// Encryption is deterministic, see privacy.WithDeterministic, so the same content always gives the same blob and
+// git doesn't see spurious changes, in any clone. It reveals which versions of a file are equal.
+package gitfilter
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"os"
+	"strings"
+
+	"gitea.suyono.dev/suyono/simple-privacy-tool/privacy"
+)
+
+const (
+	KeyLen = 32
+)
+
+var (
+	ErrInvalidKey = errors.New("invalid git filter key")
+)
+
+// Cipher is the Filter encrypting with the repository key.
+type Cipher struct {
+	key string
+}
+
+func NewCipher(key []byte) (*Cipher, error) {
+	if len(key) != KeyLen {
+		return nil, ErrInvalidKey
+	}
+
+	return &Cipher{
+		key: string(key),
+	}, nil
+}
+
+// GenerateKey writes a new random key to path, which must not exist.
+func GenerateKey(path string) (err error) {
+	var (
+		f *os.File
+	)
+
+	key := make([]byte, KeyLen)
+	if _, err = rand.Read(key); err != nil {
+		return
+	}
+
+	if f, err = os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600); err != nil {
+		return
+	}
+
+	if _, err = f.WriteString(base64.StdEncoding.EncodeToString(key) + "\n"); err != nil {
+		_ = f.Close()
+		return
+	}
+
+	return f.Close()
+}
+
+// LoadKey reads a key written by GenerateKey.
+func LoadKey(path string) (key []byte, err error) {
+	var (
+		b []byte
+	)
+
+	if b, err = os.ReadFile(path); err != nil {
+		return
+	}
+
+	if key, err = base64.StdEncoding.DecodeString(strings.TrimSpace(string(b))); err != nil || len(key) != KeyLen {
+		return nil, ErrInvalidKey
+	}
+
+	return
+}
+
+func (c *Cipher) options() []privacy.Option {
+	return []privacy.Option{
+		privacy.WithPassphrase(c.key),
+		privacy.WithKeyGen(privacy.NewHKDF()),
+		privacy.WithDeterministic(),
+	}
+}
+
+func (c *Cipher) decrypt(content []byte) ([]byte, error) {
+	return privacy.DecryptBytes(content, c.options()...)
+}
+
+// Clean encrypts content. Content which is already encrypted with the repository key is passed through.
+func (c *Cipher) Clean(path string, content []byte) (out []byte, err error) {
+	if _, err = c.decrypt(content); err == nil {
+		return content, nil
+	}
+
+	return privacy.EncryptBytes(content, c.options()...)
+}
+
+// Smudge decrypts content. Content which isn't encrypted, e.g. committed before the filter was set up, is passed
+// through.
+func (c *Cipher) Smudge(path string, content []byte) (out []byte, err error) {
+	if _, _, err = privacy.Detect(bytes.NewReader(content)); err != nil {
+		return content, nil
+	}
+
+	return c.decrypt(content)
+}
@@ -0,0 +1,162 @@
+package gitfilter
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPktLine(t *testing.T) {
+	var buf bytes.Buffer
+
+	pw := &pktWriter{w: &buf}
+	if err := pw.writeList("git-filter-client", "version=2"); err != nil {
+		t.Fatal("unexpected: writeList failed", err)
+	}
+	if buf.String() != "0016git-filter-client\n000eversion=2\n0000" {
+		t.Fatal("unexpected: encoding", buf.String())
+	}
+
+	content := bytes.Repeat([]byte{0xA5}, maxPktPayload+10)
+	if err := pw.writeContent(content); err != nil {
+		t.Fatal("unexpected: writeContent failed", err)
+	}
+
+	pr := &pktReader{r: &buf}
+	list, err := pr.readList()
+	if err != nil || !reflect.DeepEqual(list, []string{"git-filter-client", "version=2"}) {
+		t.Fatal("unexpected: readList", list, err)
+	}
+	got, err := pr.readContent()
+	if err != nil || !bytes.Equal(got, content) {
+		t.Fatal("unexpected: readContent", err)
+	}
+
+	for _, b := range []string{"zzzz", "0003", "0010short"} {
+		pr = &pktReader{r: strings.NewReader(b)}
+		if _, err = pr.readPacket(); err == nil {
+			t.Fatal("unexpected: it should fail for", b)
+		}
+	}
+}
+
+type upperFilter struct{}
+
+func (upperFilter) Clean(path string, content []byte) ([]byte, error) {
+	if path == "fail" {
+		return nil, errors.New("failed")
+	}
+	return bytes.ToUpper(content), nil
+}
+
+func (upperFilter) Smudge(path string, content []byte) ([]byte, error) {
+	return bytes.ToLower(content), nil
+}
+
+func TestProcess(t *testing.T) {
+	var in, out bytes.Buffer
+
+	git := &pktWriter{w: &in}
+	_ = git.writeList("git-filter-client", "version=2")
+	_ = git.writeList("capability=clean", "capability=smudge", "capability=delay")
+	_ = git.writeList("command=clean", "pathname=a.txt")
+	_ = git.writeContent([]byte("hello"))
+	_ = git.writeList("command=clean", "pathname=fail")
+	_ = git.writeContent([]byte("hello"))
+	_ = git.writeList("command=smudge", "pathname=a.txt")
+	_ = git.writeContent([]byte("HELLO"))
+
+	if err := Process(&in, &out, upperFilter{}); err != nil {
+		t.Fatal("unexpected: Process failed", err)
+	}
+
+	filter := &pktReader{r: &out}
+	expect := func(want ...string) {
+		t.Helper()
+		list, err := filter.readList()
+		if err != nil || !reflect.DeepEqual(list, want) {
+			t.Fatal("unexpected: response", list, err)
+		}
+	}
+	expectContent := func(want string) {
+		t.Helper()
+		content, err := filter.readContent()
+		if err != nil || string(content) != want {
+			t.Fatal("unexpected: content", string(content), err)
+		}
+	}
+
+	expect("git-filter-server", "version=2")
+	expect("capability=clean", "capability=smudge")
+	expect("status=success")
+	expectContent("HELLO")
+	expect()
+	expect("status=error")
+	expect("status=success")
+	expectContent("hello")
+	expect()
+	if _, err := filter.readPacket(); err != io.EOF {
+		t.Fatal("unexpected: trailing response", err)
+	}
+
+	in.Reset()
+	_ = git.writeList("git-filter-client", "version=1")
+	if err := Process(&in, &out, upperFilter{}); err != ErrHandshake {
+		t.Fatal("unexpected: it should return ErrHandshake, got", err)
+	}
+}
+
+func TestCipher(t *testing.T) {
+	c, err := NewCipher(bytes.Repeat([]byte{1}, KeyLen))
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	plain := []byte("DB_PASSWORD=secret\n")
+	enc, err := c.Clean("a.env", plain)
+	if err != nil || bytes.Contains(enc, []byte("secret")) {
+		t.Fatal("unexpected: Clean", err)
+	}
+
+	other, err := c.Clean("a.env", plain)
+	if err != nil || !bytes.Equal(other, enc) {
+		t.Fatal("unexpected: the same content should encrypt the same", err)
+	}
+
+	if again, err := c.Clean("a.env", enc); err != nil || !bytes.Equal(again, enc) {
+		t.Fatal("unexpected: Clean should pass encrypted content through", err)
+	}
+	if changed, err := c.Clean("a.env", []byte("changed")); err != nil || bytes.Equal(changed, enc) {
+		t.Fatal("unexpected: Clean should encrypt changed content", err)
+	}
+
+	if out, err := c.Smudge("a.env", enc); err != nil || !bytes.Equal(out, plain) {
+		t.Fatal("unexpected: Smudge", string(out), err)
+	}
+	if out, err := c.Smudge("a.env", []byte("not encrypted\n")); err != nil || string(out) != "not encrypted\n" {
+		t.Fatal("unexpected: Smudge should pass plain content through", err)
+	}
+
+	wrong, _ := NewCipher(bytes.Repeat([]byte{2}, KeyLen))
+	if _, err = wrong.Smudge("a.env", enc); err == nil {
+		t.Fatal("unexpected: Smudge with the wrong key should fail")
+	}
+}
+
+func TestKeyFile(t *testing.T) {
+	path := t.TempDir() + "/key"
+	if err := GenerateKey(path); err != nil {
+		t.Fatal("unexpected: GenerateKey failed", err)
+	}
+	if err := GenerateKey(path); err == nil {
+		t.Fatal("unexpected: GenerateKey should not overwrite a key")
+	}
+
+	key, err := LoadKey(path)
+	if err != nil || len(key) != KeyLen {
+		t.Fatal("unexpected: LoadKey", err)
+	}
+}
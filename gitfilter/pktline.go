@@ -0,0 +1,148 @@
+package gitfilter
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// A pkt-line is a 4-digit hex length, counting the length itself, followed by the payload. "0000" is a flush
+// packet, which ends a list or a content stream.
+const (
+	pktLenBytes   = 4
+	maxPktPayload = 65516
+)
+
+var (
+	ErrInvalidPacket = errors.New("invalid pkt-line")
+	errFlush         = errors.New("flush packet")
+)
+
+type pktReader struct {
+	r   io.Reader
+	hdr [pktLenBytes]byte
+}
+
+// readPacket returns the payload of the next packet, or errFlush for a flush packet.
+func (p *pktReader) readPacket() (b []byte, err error) {
+	var (
+		l uint64
+	)
+
+	if _, err = io.ReadFull(p.r, p.hdr[:]); err != nil {
+		return
+	}
+
+	if l, err = strconv.ParseUint(string(p.hdr[:]), 16, 16); err != nil {
+		return nil, ErrInvalidPacket
+	}
+
+	switch {
+	case l == 0:
+		return nil, errFlush
+	case l <= pktLenBytes:
+		return nil, ErrInvalidPacket
+	}
+
+	b = make([]byte, l-pktLenBytes)
+	if _, err = io.ReadFull(p.r, b); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	return
+}
+
+// readText reads a text packet and strips the trailing LF.
+func (p *pktReader) readText() (string, error) {
+	b, err := p.readPacket()
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(string(b), "\n"), nil
+}
+
+// readList reads text packets up to the next flush packet.
+func (p *pktReader) readList() (list []string, err error) {
+	var (
+		line string
+	)
+
+	for {
+		if line, err = p.readText(); err != nil {
+			if err == errFlush {
+				return list, nil
+			}
+			return
+		}
+		list = append(list, line)
+	}
+}
+
+// readContent reads binary packets up to the next flush packet.
+func (p *pktReader) readContent() (content []byte, err error) {
+	var (
+		b []byte
+	)
+
+	for {
+		if b, err = p.readPacket(); err != nil {
+			if err == errFlush {
+				return content, nil
+			}
+			return
+		}
+		content = append(content, b...)
+	}
+}
+
+type pktWriter struct {
+	w io.Writer
+}
+
+func (p *pktWriter) writePacket(b []byte) (err error) {
+	if len(b) > maxPktPayload {
+		return ErrInvalidPacket
+	}
+
+	if _, err = fmt.Fprintf(p.w, "%04x", len(b)+pktLenBytes); err != nil {
+		return
+	}
+
+	_, err = p.w.Write(b)
+	return
+}
+
+func (p *pktWriter) flush() error {
+	_, err := io.WriteString(p.w, "0000")
+	return err
+}
+
+// writeList writes each line as a text packet, followed by a flush packet.
+func (p *pktWriter) writeList(lines ...string) (err error) {
+	for _, line := range lines {
+		if err = p.writePacket([]byte(line + "\n")); err != nil {
+			return
+		}
+	}
+
+	return p.flush()
+}
+
+// writeContent splits content into packets, followed by a flush packet.
+func (p *pktWriter) writeContent(content []byte) (err error) {
+	for len(content) > 0 {
+		n := min(len(content), maxPktPayload)
+		if err = p.writePacket(content[:n]); err != nil {
+			return
+		}
+		content = content[n:]
+	}
+
+	return p.flush()
+}
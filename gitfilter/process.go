@@ -0,0 +1,109 @@
+package gitfilter
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"strings"
+)
+
+// Filter converts the content of one file. path is relative to the repository root.
+type Filter interface {
+	Clean(path string, content []byte) ([]byte, error)
+	Smudge(path string, content []byte) ([]byte, error)
+}
+
+var (
+	ErrHandshake      = errors.New("git filter handshake failed")
+	ErrUnknownCommand = errors.New("unknown git filter command")
+)
+
+// Process speaks the long-running filter protocol of git, configured as filter.<driver>.process, on r and w until
+// git closes r. A failure of the filter is reported to git as an error status for that file only; protocol errors
+// end the process.
+func Process(r io.Reader, w io.Writer, f Filter) (err error) {
+	var (
+		pr      = &pktReader{r: r}
+		pw      = &pktWriter{w: w}
+		list    []string
+		content []byte
+		out     []byte
+	)
+
+	if list, err = pr.readList(); err != nil {
+		return
+	}
+	if !slices.Equal(list, []string{"git-filter-client", "version=2"}) {
+		return ErrHandshake
+	}
+	if err = pw.writeList("git-filter-server", "version=2"); err != nil {
+		return
+	}
+
+	if list, err = pr.readList(); err != nil {
+		return
+	}
+	var capabilities []string
+	for _, c := range []string{"capability=clean", "capability=smudge"} {
+		if slices.Contains(list, c) {
+			capabilities = append(capabilities, c)
+		}
+	}
+	if err = pw.writeList(capabilities...); err != nil {
+		return
+	}
+
+	for {
+		if list, err = pr.readList(); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return
+		}
+
+		command, path := "", ""
+		for _, kv := range list {
+			key, value, _ := strings.Cut(kv, "=")
+			switch key {
+			case "command":
+				command = value
+			case "pathname":
+				path = value
+			}
+		}
+
+		if content, err = pr.readContent(); err != nil {
+			return
+		}
+
+		switch command {
+		case "clean":
+			out, err = f.Clean(path, content)
+		case "smudge":
+			out, err = f.Smudge(path, content)
+		default:
+			return fmt.Errorf("%w: %q", ErrUnknownCommand, command)
+		}
+
+		if err != nil {
+			log.Printf("%s %s: %v", command, path, err)
+			if err = pw.writeList("status=error"); err != nil {
+				return
+			}
+			continue
+		}
+
+		if err = pw.writeList("status=success"); err != nil {
+			return
+		}
+		if err = pw.writeContent(out); err != nil {
+			return
+		}
+		// an empty list keeps the status
+		if err = pw.flush(); err != nil {
+			return
+		}
+	}
+}
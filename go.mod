@@ -1,16 +1,21 @@
 module gitea.suyono.dev/suyono/simple-privacy-tool
 
-go 1.20
+go 1.22
 
 require (
 	gitea.suyono.dev/suyono/terminal_wrapper v0.0.0-20230722101024-a3e50949f40f
+	github.com/hanwen/go-fuse/v2 v2.9.0
+	github.com/klauspost/compress v1.18.0
+	github.com/klauspost/reedsolomon v1.10.0
 	github.com/spf13/cobra v1.7.0
 	golang.org/x/crypto v0.11.0
+	golang.org/x/sys v0.30.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
+	github.com/klauspost/cpuid/v2 v2.2.11 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
-	golang.org/x/sys v0.10.0 // indirect
 	golang.org/x/term v0.10.0 // indirect
 )
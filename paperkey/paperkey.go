@@ -0,0 +1,284 @@
+// Package paperkey renders a key or a small file as a printable page, to be typed in again when the digital copies
+// are gone. The data is written in Base32, which has no easily confused characters, in numbered lines of
+// lineBytes bytes:
+//
+//	# simple-privacy-tool paper key
+//	# title: recovery key
+//	# 42 bytes in 2 lines, restore with: simple-privacy-tool paperkey restore
+//	  1: MFRGG ZDFMZ TWQ2L KNNWG 23TPO BYXE4 3UOV3 HO6DZ  ISWP
+//	  2: PIYDC MRTGQ 2TMNZ YHFAU EQ2EI VDA                M3H4
+//	sum: 42 WRA62IBSMHFX26NQ
+//
+// The last group of a line is its checksum, the first checksumLen characters of the Base32 CRC-32 of the line
+// number and the line data, so a typing error is reported with its line, and a line typed in twice or in the wrong
+// place doesn't pass. The sum line holds the length and the start of the SHA-256 of the data. Lines starting with #
+// are comments.
+//
+// The same data fits in a QR code, see QRText.
+package paperkey
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// MaxSize is the largest data accepted, about 160 lines.
+	MaxSize = 4096
+
+	lineBytes   = 25
+	lineChars   = lineBytes * 8 / 5
+	groupLen    = 5
+	checksumLen = 4
+	sumLen      = 16
+	sumLabel    = "sum"
+	alphabet    = "ABCDEFGHIJKLMNOPQRSTUVWXYZ234567"
+)
+
+var (
+	ErrTooLarge       = errors.New("too large for a paper key")
+	ErrEmpty          = errors.New("no data")
+	ErrInvalidLine    = errors.New("invalid line")
+	ErrLineChecksum   = errors.New("checksum mismatch")
+	ErrDuplicateLine  = errors.New("line typed in twice")
+	ErrMissingLine    = errors.New("missing line")
+	ErrMissingSum     = errors.New("missing sum line")
+	ErrSumMismatch    = errors.New("the lines don't match the sum line")
+	ErrLengthMismatch = errors.New("the lines don't match the length in the sum line")
+
+	encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+)
+
+// Encode returns the page for data, with title in the header when it isn't empty.
+func Encode(data []byte, title string) (page []byte, err error) {
+	var (
+		buf bytes.Buffer
+	)
+
+	if len(data) == 0 {
+		return nil, ErrEmpty
+	}
+	if len(data) > MaxSize {
+		return nil, fmt.Errorf("%w: %d bytes, at most %d", ErrTooLarge, len(data), MaxSize)
+	}
+
+	lines := (len(data) + lineBytes - 1) / lineBytes
+	width := len(strconv.Itoa(lines))
+	if width < len(sumLabel) {
+		width = len(sumLabel)
+	}
+
+	buf.WriteString("# simple-privacy-tool paper key\n")
+	if title != "" {
+		buf.WriteString("# title: " + strings.Join(strings.Fields(title), " ") + "\n")
+	}
+	_, _ = fmt.Fprintf(&buf, "# %d bytes in %d lines, restore with: simple-privacy-tool paperkey restore\n",
+		len(data), lines)
+
+	for n := 1; n <= lines; n++ {
+		chunk := data[(n-1)*lineBytes : min(n*lineBytes, len(data))]
+		_, _ = fmt.Fprintf(&buf, "%*d: %-*s  %s\n", width, n, lineChars/groupLen*(groupLen+1)-1,
+			groups(encoding.EncodeToString(chunk)), lineChecksum(n, chunk))
+	}
+
+	_, _ = fmt.Fprintf(&buf, "%*s: %d %s\n", width, sumLabel, len(data), dataSum(data))
+
+	return buf.Bytes(), nil
+}
+
+// Decode reconstructs the data from a page typed in again. Case and spacing within the lines don't matter, and
+// 0, 1, and 8 are read as O, I, and B. Every line with an error is reported, joined into one error. The text of a
+// scanned QR code is accepted as well.
+func Decode(page []byte) (data []byte, err error) {
+	var (
+		errs   []error
+		lines  = make(map[int][]byte)
+		length = -1
+		sum    string
+	)
+
+	if text := strings.TrimSpace(string(page)); strings.HasPrefix(strings.ToUpper(text), QRPrefix) {
+		return decodeQRText(text)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(page))
+	for row := 1; scanner.Scan(); row++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		label, rest, ok := strings.Cut(text, ":")
+		label = strings.ToLower(strings.TrimSpace(label))
+		if !ok {
+			errs = append(errs, fmt.Errorf("%w: row %d, no line number", ErrInvalidLine, row))
+			continue
+		}
+
+		if label == sumLabel {
+			fields := strings.Fields(rest)
+			if len(fields) != 2 {
+				errs = append(errs, fmt.Errorf("%w: sum line", ErrInvalidLine))
+				continue
+			}
+			if length, err = strconv.Atoi(fields[0]); err != nil || length < 1 || length > MaxSize {
+				errs = append(errs, fmt.Errorf("%w: sum line, invalid length", ErrInvalidLine))
+				length = -1
+				continue
+			}
+			sum = normalize(fields[1])
+			continue
+		}
+
+		n, err := strconv.Atoi(label)
+		if err != nil || n < 1 {
+			errs = append(errs, fmt.Errorf("%w: row %d, invalid line number %q", ErrInvalidLine, row, label))
+			continue
+		}
+		if _, ok = lines[n]; ok {
+			errs = append(errs, fmt.Errorf("%w: line %d", ErrDuplicateLine, n))
+			continue
+		}
+
+		chunk, err := decodeLine(n, rest)
+		if err != nil {
+			errs = append(errs, err)
+			// keep the number, so the line isn't reported as missing too
+			chunk = nil
+		}
+		lines[n] = chunk
+	}
+	if err = scanner.Err(); err != nil {
+		return
+	}
+
+	if length < 0 && len(errs) == 0 {
+		errs = append(errs, ErrMissingSum)
+	}
+	if length > 0 {
+		for n := 1; n <= (length+lineBytes-1)/lineBytes; n++ {
+			if _, ok := lines[n]; !ok {
+				errs = append(errs, fmt.Errorf("%w: line %d", ErrMissingLine, n))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	numbers := make([]int, 0, len(lines))
+	for n := range lines {
+		numbers = append(numbers, n)
+	}
+	sort.Ints(numbers)
+	for _, n := range numbers {
+		data = append(data, lines[n]...)
+	}
+
+	if len(data) != length {
+		return nil, fmt.Errorf("%w: %d bytes, expected %d", ErrLengthMismatch, len(data), length)
+	}
+	if dataSum(data) != sum {
+		return nil, ErrSumMismatch
+	}
+
+	return data, nil
+}
+
+// decodeLine decodes the text after the line number. On a checksum mismatch it looks for a single mistyped
+// character, and reports it when exactly one correction matches.
+func decodeLine(n int, text string) (chunk []byte, err error) {
+	var (
+		encoded, checksum string
+	)
+
+	// without spaces, the checksum is the last checksumLen characters
+	if fields := strings.Fields(text); len(fields) > 1 {
+		encoded, checksum = normalize(strings.Join(fields[:len(fields)-1], "")), normalize(fields[len(fields)-1])
+	} else if s := normalize(text); len(s) > checksumLen {
+		encoded, checksum = s[:len(s)-checksumLen], s[len(s)-checksumLen:]
+	} else {
+		return nil, fmt.Errorf("%w: line %d, no checksum", ErrInvalidLine, n)
+	}
+
+	if chunk, err = encoding.DecodeString(encoded); err != nil || len(chunk) == 0 || len(chunk) > lineBytes {
+		return nil, fmt.Errorf("%w: line %d, not Base32 or a wrong number of characters", ErrInvalidLine, n)
+	}
+
+	if lineChecksum(n, chunk) == checksum {
+		return chunk, nil
+	}
+
+	var (
+		found []string
+		typed = []byte(encoded)
+	)
+	for i := range typed {
+		original := typed[i]
+		for _, c := range []byte(alphabet) {
+			if c == original {
+				continue
+			}
+			typed[i] = c
+			if candidate, dErr := encoding.DecodeString(string(typed)); dErr == nil &&
+				lineChecksum(n, candidate) == checksum {
+				found = append(found, fmt.Sprintf("group %d character %d should be %c", i/groupLen+1, i%groupLen+1, c))
+			}
+		}
+		typed[i] = original
+	}
+
+	if len(found) == 1 {
+		return nil, fmt.Errorf("%w: line %d, %s", ErrLineChecksum, n, found[0])
+	}
+
+	return nil, fmt.Errorf("%w: line %d", ErrLineChecksum, n)
+}
+
+// normalize removes the spaces, upper cases, and replaces the digits which aren't in the Base32 alphabet with the
+// letters they are mistaken for.
+func normalize(s string) string {
+	return strings.NewReplacer(" ", "", "\t", "", "0", "O", "1", "I", "8", "B").Replace(strings.ToUpper(s))
+}
+
+func groups(s string) string {
+	var (
+		b strings.Builder
+	)
+
+	for i := 0; i < len(s); i += groupLen {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(s[i:min(i+groupLen, len(s))])
+	}
+
+	return b.String()
+}
+
+func lineChecksum(n int, chunk []byte) string {
+	var (
+		b [4]byte
+	)
+
+	h := crc32.NewIEEE()
+	_, _ = h.Write(binary.BigEndian.AppendUint32(nil, uint32(n)))
+	_, _ = h.Write(chunk)
+	binary.BigEndian.PutUint32(b[:], h.Sum32())
+
+	return encoding.EncodeToString(b[:])[:checksumLen]
+}
+
+func dataSum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return encoding.EncodeToString(sum[:])[:sumLen]
+}
@@ -0,0 +1,98 @@
+package paperkey
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	for _, size := range []int{1, lineBytes, lineBytes + 1, 1000, MaxSize} {
+		data := make([]byte, size)
+		if _, err := rand.Read(data); err != nil {
+			t.Fatal("test preparation failure:", err)
+		}
+
+		page, err := Encode(data, "recovery\nkey")
+		if err != nil {
+			t.Fatal("unexpected: Encode failed", err)
+		}
+		if !bytes.Contains(page, []byte("# title: recovery key\n")) {
+			t.Fatal("unexpected: no title in", string(page))
+		}
+
+		got, err := Decode(page)
+		if err != nil || !bytes.Equal(got, data) {
+			t.Fatal("unexpected: Decode", size, err)
+		}
+	}
+
+	if _, err := Encode(make([]byte, MaxSize+1), ""); !errors.Is(err, ErrTooLarge) {
+		t.Fatal("unexpected: it should return ErrTooLarge, got", err)
+	}
+}
+
+func TestDecodeTyped(t *testing.T) {
+	data := []byte("abcdefghijklmnopqrstuvwxyz0123456789ABCDEF")
+	page, err := Encode(data, "")
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(page)), "\n")[2:]
+
+	// lower case, no spaces, lines in another order, O typed as 0
+	typed := strings.ToLower(strings.ReplaceAll(lines[1], " ", "")+"\n"+lines[2]+"\n"+lines[0]) + "\n"
+	typed = strings.Replace(typed, "o", "0", 1)
+	if got, err := Decode([]byte(typed)); err != nil || !bytes.Equal(got, data) {
+		t.Fatal("unexpected: Decode of the typed lines", err)
+	}
+
+	// line 1 is MFRGG ZDFMZ ..., with a typo in the third group
+	mistyped := strings.Replace(lines[0], "TWQ2L", "TWQ3L", 1) + "\n" + lines[1] + "\n" + lines[2]
+	_, err = Decode([]byte(mistyped))
+	if !errors.Is(err, ErrLineChecksum) || !strings.Contains(err.Error(), "line 1, group 3 character 4 should be 2") {
+		t.Fatal("unexpected: it should locate the typo, got", err)
+	}
+
+	_, err = Decode([]byte(lines[0] + "\n" + lines[2]))
+	if !errors.Is(err, ErrMissingLine) || !strings.Contains(err.Error(), "line 2") {
+		t.Fatal("unexpected: it should return ErrMissingLine, got", err)
+	}
+
+	if _, err = Decode([]byte(lines[0] + "\n" + lines[1])); !errors.Is(err, ErrMissingSum) {
+		t.Fatal("unexpected: it should return ErrMissingSum, got", err)
+	}
+
+	// line 1 typed in as line 2
+	swapped := lines[0] + "\n" + strings.Replace(lines[0], "1:", "2:", 1) + "\n" + lines[2]
+	if _, err = Decode([]byte(swapped)); !errors.Is(err, ErrLineChecksum) {
+		t.Fatal("unexpected: it should return ErrLineChecksum, got", err)
+	}
+}
+
+func TestQR(t *testing.T) {
+	data := make([]byte, 64)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	if got, err := Decode([]byte(strings.ToLower(QRText(data)) + "\n")); err != nil || !bytes.Equal(got, data) {
+		t.Fatal("unexpected: Decode of the QR text", err)
+	}
+
+	png, err := PNG(data)
+	if err != nil || !bytes.HasPrefix(png, []byte("\x89PNG")) {
+		t.Fatal("unexpected: PNG", err)
+	}
+
+	svg, err := SVG(data)
+	if err != nil || !bytes.HasPrefix(svg, []byte("<svg")) || !bytes.Contains(svg, []byte("h1v1h-1z")) {
+		t.Fatal("unexpected: SVG", err)
+	}
+
+	if _, err = SVG(make([]byte, MaxSize)); !errors.Is(err, ErrQRTooLarge) {
+		t.Fatal("unexpected: it should return ErrQRTooLarge, got", err)
+	}
+}
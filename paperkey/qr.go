@@ -0,0 +1,91 @@
+package paperkey
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gitea.suyono.dev/suyono/simple-privacy-tool/paperkey/internal/qr"
+)
+
+const (
+	// QRPrefix starts the text of the QR codes, and lets Decode take a scanned code in place of the typed lines.
+	QRPrefix = "SPTPK1:"
+
+	// quietZone is the white margin around a QR code, in modules, as required by the standard.
+	quietZone = 4
+	qrScale   = 8
+)
+
+var (
+	ErrQRTooLarge = errors.New("too large for a QR code, use the text page")
+)
+
+// QRText returns the text encoded in the QR code for data: QRPrefix followed by the Base32 data. Upper case letters,
+// digits, and the colon fit the compact alphanumeric mode of QR codes.
+func QRText(data []byte) string {
+	return QRPrefix + encoding.EncodeToString(data)
+}
+
+// PNG returns a QR code for data as a PNG image.
+func PNG(data []byte) ([]byte, error) {
+	code, err := encodeQR(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return code.PNG(), nil
+}
+
+// SVG returns a QR code for data as an SVG image, one path of unit squares.
+func SVG(data []byte) ([]byte, error) {
+	var (
+		buf bytes.Buffer
+	)
+
+	code, err := encodeQR(data)
+	if err != nil {
+		return nil, err
+	}
+
+	size := code.Size + 2*quietZone
+	_, _ = fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" `+
+		`shape-rendering="crispEdges">`+"\n", size*qrScale, size*qrScale, size, size)
+	_, _ = fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="#fff"/>`+"\n", size, size)
+	buf.WriteString(`<path fill="#000" d="`)
+	for y := 0; y < code.Size; y++ {
+		for x := 0; x < code.Size; x++ {
+			if code.Black(x, y) {
+				_, _ = fmt.Fprintf(&buf, "M%d %dh1v1h-1z", x+quietZone, y+quietZone)
+			}
+		}
+	}
+	buf.WriteString(`"/>` + "\n</svg>\n")
+
+	return buf.Bytes(), nil
+}
+
+func encodeQR(data []byte) (*qr.Code, error) {
+	if len(data) == 0 {
+		return nil, ErrEmpty
+	}
+
+	code, err := qr.Encode(QRText(data), qr.M)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %d bytes", ErrQRTooLarge, len(data))
+	}
+	code.Scale = qrScale
+
+	return code, nil
+}
+
+// decodeQRText decodes the text of a scanned QR code.
+func decodeQRText(text string) ([]byte, error) {
+	data, err := encoding.DecodeString(strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(text)), QRPrefix))
+	if err != nil || len(data) == 0 {
+		return nil, fmt.Errorf("%w: QR code text", ErrInvalidLine)
+	}
+
+	return data, nil
+}
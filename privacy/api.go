@@ -0,0 +1,529 @@
+package privacy
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"os"
+)
+
+type options struct {
+	passphraseFunc   func(attempt int) (string, error)
+	attempts         int
+	cipherMethod     CipherMethodType
+	keygen           KeyGen
+	hint             bool
+	encoding         Encoding
+	armorCRC         bool
+	compression      CompressionType
+	compressionLevel int
+	padding          Padding
+	maskLength       bool
+	segmentSize      uint32
+	ctx              context.Context
+	progress         Progress
+	deterministic    bool
+	shred            bool
+	ecc              int
+}
+
+// Option configures Encrypt, Decrypt, and the other high-level helpers. Options that only affect the output
+// format, e.g. WithCompression or WithArmor, are ignored by the decrypting helpers, which detect the format.
+type Option func(*options)
+
+var (
+	ErrNoPassphrase = errors.New("no passphrase given")
+)
+
+func WithPassphrase(passphrase string) Option {
+	return func(o *options) {
+		o.passphraseFunc = func(int) (string, error) {
+			return passphrase, nil
+		}
+	}
+}
+
+// WithPassphraseFunc asks f for the passphrase. When decrypting with WithAttempts, f is called again with an
+// incremented attempt number after each wrong passphrase.
+func WithPassphraseFunc(f func(attempt int) (string, error)) Option {
+	return func(o *options) {
+		o.passphraseFunc = f
+	}
+}
+
+// WithAttempts sets how many passphrases the decrypting helpers try before giving up with ErrWrongPassphrase.
+func WithAttempts(n int) Option {
+	return func(o *options) {
+		o.attempts = n
+	}
+}
+
+func WithCipherMethod(cmType CipherMethodType) Option {
+	return func(o *options) {
+		o.cipherMethod = cmType
+	}
+}
+
+func WithKeyGen(keygen KeyGen) Option {
+	return func(o *options) {
+		o.keygen = keygen
+	}
+}
+
+// WithHint embeds the KeyGen parameters in front of the encrypted stream as a hint.
+func WithHint() Option {
+	return func(o *options) {
+		o.hint = true
+	}
+}
+
+func WithBase64() Option {
+	return func(o *options) {
+		o.encoding = Base64Encoding
+	}
+}
+
+func WithArmor(withCRC bool) Option {
+	return func(o *options) {
+		o.encoding = ArmorEncoding
+		o.armorCRC = withCRC
+	}
+}
+
+// WithECC wraps the output in the ECC layer, with Reed-Solomon parity of percent of the data, so the decrypting side
+// repairs small damage, see ECCWriter.
+func WithECC(percent int) Option {
+	return func(o *options) {
+		o.ecc = percent
+	}
+}
+
+func WithCompression(c CompressionType, level int) Option {
+	return func(o *options) {
+		o.compression = c
+		o.compressionLevel = level
+	}
+}
+
+func WithPadding(p Padding) Option {
+	return func(o *options) {
+		o.padding = p
+	}
+}
+
+func WithMaskedLength() Option {
+	return func(o *options) {
+		o.maskLength = true
+	}
+}
+
+// WithSegmentSize overrides the segment size. The decrypting side has to use a segment size at least as large as
+// the one used for encryption.
+func WithSegmentSize(size uint32) Option {
+	return func(o *options) {
+		o.segmentSize = size
+	}
+}
+
+// WithDeterministic makes Encrypt derive the salt and the nonces from the plain text, so the same plain text and
+// options always give the same output. It reveals which encrypted streams hold the same plain text, see
+// NewDeterministicSalt. A src which can't seek is buffered in memory, because the plain text is read twice.
+func WithDeterministic() Option {
+	return func(o *options) {
+		o.deterministic = true
+	}
+}
+
+func newOptions(opts []Option) (o *options, err error) {
+	o = &options{
+		attempts:     1,
+		cipherMethod: DefaultCipherMethod,
+		keygen:       NewArgon2(),
+		armorCRC:     true,
+		ctx:          context.Background(),
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if o.passphraseFunc == nil {
+		return nil, ErrNoPassphrase
+	}
+
+	return
+}
+
+// Encrypt reads src until EOF and writes the encrypted stream to dst. It doesn't close dst.
+func Encrypt(dst io.Writer, src io.Reader, opts ...Option) (err error) {
+	var (
+		o          *options
+		passphrase string
+		enc        io.Writer
+		encCloser  io.Closer
+		eccWriter  *ECCWriter
+		w          io.WriteCloser
+		t          *tracker
+	)
+
+	if o, err = newOptions(opts); err != nil {
+		return
+	}
+
+	if err = o.ctx.Err(); err != nil {
+		return
+	}
+
+	if passphrase, err = o.passphraseFunc(1); err != nil {
+		return
+	}
+
+	if o.ecc != 0 {
+		if eccWriter, err = NewECCWriter(dst, o.ecc); err != nil {
+			return
+		}
+		dst = eccWriter
+	}
+
+	switch o.encoding {
+	case ArmorEncoding:
+		a := NewArmorWriter(dst, o.armorCRC)
+		enc, encCloser = a, a
+	case Base64Encoding:
+		b := base64.NewEncoder(base64.StdEncoding, dst)
+		enc, encCloser = b, b
+	default:
+		enc = dst
+	}
+
+	if o.hint {
+		if err = WriteHint(o.keygen, enc); err != nil {
+			return
+		}
+	}
+
+	wc := NewPrivacyWriter(enc, o.cipherMethod, o.keygen)
+	if o.segmentSize > 0 {
+		wc.SetSegmentSize(o.segmentSize)
+	}
+
+	if o.deterministic {
+		if src, err = deterministicSource(src, func(plain io.Reader) error {
+			return wc.NewDeterministicSalt(passphrase, plain)
+		}); err != nil {
+			return
+		}
+	} else if err = wc.NewSalt(); err != nil {
+		return
+	}
+	t = newTracker(o, src)
+	wc.SetSegmentHook(t.segment)
+
+	if err = wc.RecordKeyGen(); err != nil {
+		return
+	}
+
+	if err = wc.GenerateKey(passphrase); err != nil {
+		return
+	}
+
+	if err = wc.SetPadding(o.padding); err != nil {
+		return
+	}
+
+	if o.maskLength {
+		if err = wc.MaskSegmentLength(); err != nil {
+			return
+		}
+	}
+
+	if w, err = NewCompressWriteCloser(wc, o.compression, o.compressionLevel); err != nil {
+		return
+	}
+
+	if _, err = io.Copy(w, t); err != nil {
+		return
+	}
+
+	if err = w.Close(); err != nil {
+		return
+	}
+
+	if encCloser != nil {
+		if err = encCloser.Close(); err != nil {
+			return
+		}
+	}
+
+	if eccWriter != nil {
+		if err = eccWriter.Close(); err != nil {
+			return
+		}
+	}
+	t.report()
+
+	return
+}
+
+// Decrypt detects the format of src, decrypts it, and writes the plain text to dst.
+func Decrypt(dst io.Writer, src io.Reader, opts ...Option) (err error) {
+	var (
+		o          *options
+		passphrase string
+		s          io.Reader
+		dr         io.ReadCloser
+		t          *tracker
+	)
+
+	if o, err = newOptions(opts); err != nil {
+		return
+	}
+
+	if err = o.ctx.Err(); err != nil {
+		return
+	}
+	t = newTracker(o, src)
+
+	if s, _, err = Detect(t); err != nil {
+		return
+	}
+
+	r := NewPrivacyReaderWithKeyGen(s, o.keygen)
+	r.SetSegmentHook(t.segment)
+	if o.segmentSize > 0 {
+		r.SetSegmentSize(o.segmentSize)
+	}
+
+	if err = r.ReadMagic(); err != nil {
+		return
+	}
+
+	for attempt := 1; ; attempt++ {
+		if passphrase, err = o.passphraseFunc(attempt); err != nil {
+			return
+		}
+
+		if err = r.GenerateKey(passphrase); err == nil {
+			break
+		}
+
+		if !errors.Is(err, ErrWrongPassphrase) || attempt >= o.attempts {
+			return
+		}
+	}
+
+	if dr, err = NewDecompressReader(r); err != nil {
+		return
+	}
+
+	if _, err = io.Copy(dst, dr); err != nil {
+		_ = dr.Close()
+		return
+	}
+
+	if err = dr.Close(); err != nil {
+		return
+	}
+	t.report()
+
+	return
+}
+
+// Append adds src as a new session to the encrypted stream of size bytes in existing, writing the new segments to
+// dst, see Appender. The passphrase, KeyGen, and segment size have to match the existing stream; options affecting
+// the output format are ignored, except the compression level.
+func Append(dst io.Writer, existing io.ReaderAt, size int64, src io.Reader, opts ...Option) (err error) {
+	var (
+		o          *options
+		passphrase string
+		w          io.WriteCloser
+		t          *tracker
+	)
+
+	if o, err = newOptions(opts); err != nil {
+		return
+	}
+
+	if err = o.ctx.Err(); err != nil {
+		return
+	}
+	t = newTracker(o, src)
+
+	a := NewPrivacyAppender(dst, existing, size, o.keygen)
+	a.SetSegmentHook(t.segment)
+	if o.segmentSize > 0 {
+		a.SetSegmentSize(o.segmentSize)
+	}
+
+	if err = a.ReadMagic(); err != nil {
+		return
+	}
+
+	for attempt := 1; ; attempt++ {
+		if passphrase, err = o.passphraseFunc(attempt); err != nil {
+			return
+		}
+
+		if err = a.GenerateKey(passphrase); err == nil {
+			break
+		}
+
+		if !errors.Is(err, ErrWrongPassphrase) || attempt >= o.attempts {
+			return
+		}
+	}
+
+	if w, err = NewCompressWriteCloser(a.WriteCloser, a.Compression(), o.compressionLevel); err != nil {
+		return
+	}
+
+	if _, err = io.Copy(w, t); err != nil {
+		return
+	}
+
+	if err = w.Close(); err != nil {
+		return
+	}
+	t.report()
+
+	return
+}
+
+func EncryptBytes(plain []byte, opts ...Option) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := Encrypt(&buf, bytes.NewReader(plain), opts...); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func DecryptBytes(b []byte, opts ...Option) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := Decrypt(&buf, bytes.NewReader(b), opts...); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// EncryptFile encrypts srcPath into dstPath. The destination is created with mode 0600, or truncated if it
+// exists, and removed again if encryption fails. With WithShred, srcPath is shredded after a successful encryption.
+func EncryptFile(dstPath, srcPath string, opts ...Option) (err error) {
+	var (
+		o          *options
+		fi         os.FileInfo
+		passphrase string
+	)
+
+	if o, err = newOptions(opts); err != nil {
+		return
+	}
+
+	if !o.shred {
+		return processFile(dstPath, srcPath, Encrypt, opts)
+	}
+
+	if fi, err = os.Lstat(srcPath); err != nil {
+		return
+	}
+	if !fi.Mode().IsRegular() {
+		return ErrNotRegularFile
+	}
+
+	// the passphrase is asked once, for the encryption and the verification
+	if passphrase, err = o.passphraseFunc(1); err != nil {
+		return
+	}
+	opts = append(opts[:len(opts):len(opts)], WithPassphrase(passphrase))
+
+	if err = processFile(dstPath, srcPath, Encrypt, opts); err != nil {
+		return
+	}
+
+	if err = syncFile(dstPath); err != nil {
+		return
+	}
+
+	if err = VerifyFile(dstPath, srcPath, append(opts, WithProgress(nil))...); err != nil {
+		return
+	}
+
+	return Shred(srcPath)
+}
+
+// DecryptFile decrypts srcPath into dstPath. The destination is created with mode 0600, or truncated if it
+// exists, and removed again if decryption fails.
+func DecryptFile(dstPath, srcPath string, opts ...Option) error {
+	return processFile(dstPath, srcPath, Decrypt, opts)
+}
+
+// AppendFile appends srcPath to the encrypted file dstPath as a new session, see Append. If dstPath doesn't exist
+// or is empty, it is encrypted like EncryptFile. On failure dstPath is truncated back to its previous size.
+func AppendFile(dstPath, srcPath string, opts ...Option) (err error) {
+	var (
+		src  *os.File
+		dst  *os.File
+		info os.FileInfo
+	)
+
+	if src, err = os.Open(srcPath); err != nil {
+		return
+	}
+	defer func() {
+		_ = src.Close()
+	}()
+
+	if dst, err = os.OpenFile(dstPath, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600); err != nil {
+		return
+	}
+
+	if info, err = dst.Stat(); err != nil {
+		_ = dst.Close()
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = dst.Truncate(info.Size())
+		}
+		if cErr := dst.Close(); err == nil {
+			err = cErr
+		}
+	}()
+
+	if info.Size() == 0 {
+		return Encrypt(dst, src, opts...)
+	}
+
+	return Append(dst, dst, info.Size(), src, opts...)
+}
+
+func processFile(dstPath, srcPath string, process func(io.Writer, io.Reader, ...Option) error, opts []Option) (err error) {
+	var (
+		src *os.File
+		dst *os.File
+	)
+
+	if src, err = os.Open(srcPath); err != nil {
+		return
+	}
+	defer func() {
+		_ = src.Close()
+	}()
+
+	if dst, err = os.OpenFile(dstPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600); err != nil {
+		return
+	}
+	defer func() {
+		if cErr := dst.Close(); err == nil {
+			err = cErr
+		}
+		if err != nil {
+			_ = os.Remove(dstPath)
+		}
+	}()
+
+	return process(dst, src, opts...)
+}
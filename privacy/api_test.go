@@ -0,0 +1,134 @@
+package privacy
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEncryptDecryptBytes(t *testing.T) {
+	keygen, err := NewArgon2WithParams(1, 4*1024, 2)
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	plain := bytes.Repeat([]byte("some plain text\n"), 100)
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "default"},
+		{name: "aes", opts: []Option{WithCipherMethod(AES256GCMSimple)}},
+		{name: "hint and base64", opts: []Option{WithHint(), WithBase64()}},
+		{name: "armor", opts: []Option{WithArmor(true)}},
+		{name: "compression and padding", opts: []Option{WithCompression(ZstdCompression, DefaultCompressionLevel), WithPadding(Padding{Scheme: PadmePadding})}},
+		{name: "masked length and small segment", opts: []Option{WithMaskedLength(), WithSegmentSize(100)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := append([]Option{WithPassphrase("some passphrase"), WithKeyGen(keygen)}, tt.opts...)
+
+			b, err := EncryptBytes(plain, opts...)
+			if err != nil {
+				t.Fatal("unexpected: EncryptBytes failed", err)
+			}
+
+			out, err := DecryptBytes(b, WithPassphrase("some passphrase"), WithKeyGen(keygen))
+			if err != nil {
+				t.Fatal("unexpected: DecryptBytes failed", err)
+			}
+
+			if !bytes.Equal(out, plain) {
+				t.Fatal("unexpected: mismatch plain text")
+			}
+		})
+	}
+}
+
+func TestDecrypt_Attempts(t *testing.T) {
+	keygen, err := NewArgon2WithParams(1, 4*1024, 2)
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	b, err := EncryptBytes([]byte("some plain text"), WithPassphrase("some passphrase"), WithKeyGen(keygen))
+	if err != nil {
+		t.Fatal("unexpected: EncryptBytes failed", err)
+	}
+
+	if _, err = DecryptBytes(b, WithPassphrase("wrong passphrase"), WithKeyGen(keygen)); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatal("unexpected: it should return ErrWrongPassphrase, got", err)
+	}
+
+	var asked []int
+	passphrases := []string{"wrong", "still wrong", "some passphrase"}
+	askFunc := func(attempt int) (string, error) {
+		asked = append(asked, attempt)
+		return passphrases[attempt-1], nil
+	}
+
+	if _, err = DecryptBytes(b, WithPassphraseFunc(askFunc), WithAttempts(2), WithKeyGen(keygen)); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatal("unexpected: it should return ErrWrongPassphrase, got", err)
+	}
+
+	asked = nil
+	out, err := DecryptBytes(b, WithPassphraseFunc(askFunc), WithAttempts(3), WithKeyGen(keygen))
+	if err != nil {
+		t.Fatal("unexpected: DecryptBytes failed", err)
+	}
+	if string(out) != "some plain text" || len(asked) != 3 {
+		t.Fatal("unexpected: result", string(out), asked)
+	}
+
+	if _, err = EncryptBytes([]byte("some plain text")); err != ErrNoPassphrase {
+		t.Fatal("unexpected: it should return ErrNoPassphrase, got", err)
+	}
+}
+
+func TestEncryptDecryptFile(t *testing.T) {
+	keygen, err := NewArgon2WithParams(1, 4*1024, 2)
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	dir := t.TempDir()
+	plainPath := filepath.Join(dir, "plain.txt")
+	encPath := filepath.Join(dir, "plain.txt.spt")
+	outPath := filepath.Join(dir, "out.txt")
+
+	if err = os.WriteFile(plainPath, []byte("some plain text"), 0600); err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	// a longer existing destination must be truncated
+	if err = os.WriteFile(outPath, []byte(strings.Repeat("x", 1024)), 0600); err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	if err = EncryptFile(encPath, plainPath, WithPassphrase("some passphrase"), WithKeyGen(keygen), WithArmor(true)); err != nil {
+		t.Fatal("unexpected: EncryptFile failed", err)
+	}
+
+	if err = DecryptFile(outPath, encPath, WithPassphrase("some passphrase"), WithKeyGen(keygen)); err != nil {
+		t.Fatal("unexpected: DecryptFile failed", err)
+	}
+
+	b, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatal("unexpected: ReadFile failed", err)
+	}
+	if string(b) != "some plain text" {
+		t.Fatal("unexpected: mismatch plain text", string(b))
+	}
+
+	failedPath := filepath.Join(dir, "failed.txt")
+	if err = DecryptFile(failedPath, encPath, WithPassphrase("wrong passphrase"), WithKeyGen(keygen)); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatal("unexpected: it should return ErrWrongPassphrase, got", err)
+	}
+	if _, err = os.Stat(failedPath); !os.IsNotExist(err) {
+		t.Fatal("unexpected: failed output is not removed", err)
+	}
+}
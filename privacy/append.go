@@ -0,0 +1,147 @@
+package privacy
+
+import (
+	"errors"
+	"io"
+)
+
+var (
+	ErrNotAppendable = errors.New("only binary streams with an extended header can be appended to")
+)
+
+// Appender continues an existing encrypted stream, e.g. a log written over days, without re-encrypting it. Each
+// Appender writes a session: segments with counters following the existing ones, ending with another final segment.
+// Readers treat a final segment followed by more segments as a session boundary, and return the plain text of all
+// sessions as one stream.
+//
+// The flow mirrors Reader: ReadMagic, GenerateKey, then Write and Close. GenerateKey scans the segment prefixes of
+// the existing stream and authenticates its last segment, so a truncated or tampered tail is detected before
+// anything is written. The padding of the header applies to every session; compression restarts in each session, see
+// Compression. Since only the last segment is authenticated, dropping whole sessions from the end can't be detected.
+type Appender struct {
+	*WriteCloser
+	ra *ReaderAt
+}
+
+// NewPrivacyAppender reads the existing stream of size bytes from src, and writes the new segments to dst, which
+// has to write at the end of the stream, e.g. a file opened with os.O_APPEND.
+func NewPrivacyAppender(dst io.Writer, src io.ReaderAt, size int64, keygen KeyGen) *Appender {
+	ra := NewPrivacyReaderAt(src, size, keygen)
+	return &Appender{
+		WriteCloser: &WriteCloser{
+			Privacy:      ra.r.Privacy,
+			writer:       dst,
+			magicWritten: true,
+		},
+		ra: ra,
+	}
+}
+
+// ReadMagic reads the header of the existing stream, skipping a hint if there is one.
+func (a *Appender) ReadMagic() (err error) {
+	if err = a.ra.readMagic(); err != nil {
+		return
+	}
+
+	if !a.isExtended() {
+		return ErrNotAppendable
+	}
+	a.padding = a.ra.r.Padding()
+
+	return
+}
+
+// GenerateKey derives the key, verifies it against the key check value, and prepares the Appender to continue after
+// the last segment of the existing stream.
+func (a *Appender) GenerateKey(passphrase string) (err error) {
+	if err = a.ra.GenerateKey(passphrase); err != nil {
+		a.aead = nil
+		return
+	}
+
+	return a.authenticateTail()
+}
+
+// authenticateTail decrypts the final segment the index ends with, which authenticates the tail, and continues after
+// it.
+func (a *Appender) authenticateTail() (err error) {
+	var cs *cachedSegment
+	if cs, err = a.ra.loadSegment(len(a.ra.segments) - 1); err != nil {
+		a.aead = nil
+		return
+	}
+	putBuf(cs.buf)
+	a.counter = uint64(len(a.ra.segments))
+	// drop the segments cached while indexing
+	a.ra.cache = newSegmentCache(0)
+
+	return
+}
+
+// Resume is GenerateKey for a stream whose last session was cut off, e.g. by a crash of the program writing it. It
+// keeps the segments up to the last one which decrypts, and calls truncate with the size of the stream up to it, so
+// the caller can drop the torn tail; the torn session is then closed with an empty final segment, and the Appender
+// writes a new session after it. Only a tail shorter than a segment is dropped, which is all a crash leaves behind;
+// anything longer is damage, and its error is returned. A stream which isn't cut off is continued like with
+// GenerateKey.
+func (a *Appender) Resume(passphrase string, truncate func(size int64) error) (err error) {
+	var (
+		cs  *cachedSegment
+		end int64
+	)
+
+	defer func() {
+		if err != nil {
+			a.aead = nil
+		}
+	}()
+
+	if err = a.ra.r.GenerateKey(passphrase); err != nil {
+		return
+	}
+
+	iErr := a.ra.index()
+	if iErr == nil {
+		return a.authenticateTail()
+	}
+
+	overhead := int64(a.aead.NonceSize() + a.aead.Overhead())
+	maxTail := int64(segmentSizeBytesLen) + int64(a.segmentSize) + overhead
+	for {
+		end = a.ra.start
+		if n := len(a.ra.segments); n > 0 {
+			last := a.ra.segments[n-1]
+			end = last.offset + int64(segmentSizeBytesLen) + int64(last.length) + overhead
+		}
+		if a.ra.size-end >= maxTail {
+			return iErr
+		}
+
+		if len(a.ra.segments) == 0 {
+			break
+		}
+		if cs, err = a.ra.loadSegment(len(a.ra.segments) - 1); err == nil {
+			putBuf(cs.buf)
+			break
+		}
+		a.ra.segments = a.ra.segments[:len(a.ra.segments)-1]
+	}
+	a.ra.cache = newSegmentCache(0)
+
+	if err = truncate(end); err != nil {
+		return
+	}
+
+	a.counter = uint64(len(a.ra.segments))
+	if n := len(a.ra.segments); n > 0 && a.ra.segments[n-1].flags&segmentFinal == 0 {
+		_, err = a.writeSegment(segmentFinal)
+	}
+
+	return
+}
+
+// Compression returns the compression type of the existing stream. The new session has to be compressed the same
+// way: pass it to NewCompressWriteCloser.
+func (a *Appender) Compression() CompressionType {
+	return a.ra.r.Compression()
+}
@@ -0,0 +1,255 @@
+package privacy
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func appendForTest(existing []byte, plain []byte, opts ...Option) ([]byte, error) {
+	buf := bytes.NewBuffer(bytes.Clone(existing))
+	if err := Append(buf, bytes.NewReader(existing), int64(len(existing)), bytes.NewReader(plain), opts...); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func TestAppend(t *testing.T) {
+	keygen, err := NewArgon2WithParams(1, 4*1024, 2)
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	sessions := [][]byte{
+		bytes.Repeat([]byte("first day\n"), 50),
+		[]byte("second day\n"),
+		bytes.Repeat([]byte("third day\n"), 30),
+	}
+	plain := bytes.Join(sessions, nil)
+
+	for _, tc := range []struct {
+		name string
+		opts []Option
+	}{
+		{"plain", nil},
+		{"padded", []Option{WithPadding(Padding{Scheme: BlockPadding, Size: 64}), WithMaskedLength()}},
+		{"gzip", []Option{WithCompression(GzipCompression, DefaultCompressionLevel)}},
+		{"zstd", []Option{WithCompression(ZstdCompression, DefaultCompressionLevel)}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := append([]Option{WithPassphrase("some passphrase"), WithKeyGen(keygen), WithSegmentSize(128),
+				WithHint()}, tc.opts...)
+
+			b, err := EncryptBytes(sessions[0], opts...)
+			if err != nil {
+				t.Fatal("unexpected: EncryptBytes failed", err)
+			}
+			for _, s := range sessions[1:] {
+				if b, err = appendForTest(b, s, opts...); err != nil {
+					t.Fatal("unexpected: Append failed", err)
+				}
+			}
+
+			out, err := DecryptBytes(b, opts...)
+			if err != nil || !bytes.Equal(out, plain) {
+				t.Fatal("unexpected: DecryptBytes", err)
+			}
+
+			if len(tc.opts) > 0 && tc.name != "padded" {
+				return
+			}
+			ra := NewPrivacyReaderAt(bytes.NewReader(b), int64(len(b)), keygen)
+			ra.SetSegmentSize(128)
+			if err = ra.ReadMagic(); err != nil {
+				t.Fatal("unexpected: ReadMagic failed", err)
+			}
+			if err = ra.GenerateKey("some passphrase"); err != nil {
+				t.Fatal("unexpected: GenerateKey failed", err)
+			}
+			out = make([]byte, ra.Size())
+			if _, err = ra.ReadAt(out, 0); err != nil || !bytes.Equal(out, plain) {
+				t.Fatal("unexpected: ReadAt", err)
+			}
+		})
+	}
+}
+
+func TestAppendErrors(t *testing.T) {
+	keygen, err := NewArgon2WithParams(1, 4*1024, 2)
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	opts := []Option{WithPassphrase("some passphrase"), WithKeyGen(keygen), WithSegmentSize(128)}
+	first, err := EncryptBytes(bytes.Repeat([]byte{0xA5}, 300), opts...)
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+	b, err := appendForTest(first, bytes.Repeat([]byte{0x5A}, 300), opts...)
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	if _, err = appendForTest(b, []byte("x"), WithPassphrase("wrong"), WithKeyGen(keygen),
+		WithSegmentSize(128)); err != ErrWrongPassphrase {
+		t.Fatal("unexpected: it should return ErrWrongPassphrase, got", err)
+	}
+
+	if _, err = appendForTest(b[:len(b)-10], []byte("x"), opts...); err == nil {
+		t.Fatal("unexpected: appending to a truncated stream should fail")
+	}
+
+	tampered := bytes.Clone(b)
+	tampered[len(tampered)-1] ^= 1
+	if _, err = appendForTest(tampered, []byte("x"), opts...); err == nil {
+		t.Fatal("unexpected: appending to a tampered stream should fail")
+	}
+
+	armored, err := EncryptBytes([]byte("x"), append(opts, WithArmor(true))...)
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+	if _, err = appendForTest(armored, []byte("x"), opts...); err == nil {
+		t.Fatal("unexpected: appending to an armored stream should fail")
+	}
+
+	// a stream cut in the middle of a session is truncated, one cut at a session boundary isn't detected
+	if _, err = DecryptBytes(b[:len(first)+segmentSizeBytesLen+24+128+16], opts...); err != ErrTruncated {
+		t.Fatal("unexpected: it should return ErrTruncated, got", err)
+	}
+	if out, err := DecryptBytes(b[:len(first)], opts...); err != nil || len(out) != 300 {
+		t.Fatal("unexpected: DecryptBytes of the first session", err)
+	}
+
+	// a replayed session fails, the counters don't match
+	if _, err = DecryptBytes(append(bytes.Clone(b), b[len(first):]...), opts...); err != ErrTrailingData {
+		t.Fatal("unexpected: it should return ErrTrailingData, got", err)
+	}
+}
+
+func TestAppendFile(t *testing.T) {
+	keygen, err := NewArgon2WithParams(1, 4*1024, 2)
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	dir := t.TempDir()
+	dst := filepath.Join(dir, "log.spt")
+	opts := []Option{WithPassphrase("some passphrase"), WithKeyGen(keygen)}
+	for i, line := range []string{"one\n", "two\n", "three\n"} {
+		src := filepath.Join(dir, "line")
+		if err = os.WriteFile(src, []byte(line), 0600); err != nil {
+			t.Fatal("test preparation failure:", err)
+		}
+		if err = AppendFile(dst, src, opts...); err != nil {
+			t.Fatal("unexpected: AppendFile failed", i, err)
+		}
+	}
+
+	before, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatal("unexpected: ReadFile failed", err)
+	}
+	if err = AppendFile(dst, filepath.Join(dir, "line"), WithPassphrase("wrong"), WithKeyGen(keygen)); err == nil {
+		t.Fatal("unexpected: AppendFile with the wrong passphrase should fail")
+	}
+
+	f, err := os.Open(dst)
+	if err != nil {
+		t.Fatal("unexpected: Open failed", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var out bytes.Buffer
+	if err = Decrypt(&out, f, opts...); err != nil || out.String() != "one\ntwo\nthree\n" {
+		t.Fatal("unexpected: Decrypt", out.String(), err)
+	}
+	if after, _ := io.ReadAll(io.NewSectionReader(f, 0, 1<<20)); !bytes.Equal(after, before) {
+		t.Fatal("unexpected: a failed AppendFile should leave the file unchanged")
+	}
+}
+
+func TestAppendResume(t *testing.T) {
+	var (
+		buf  bytes.Buffer
+		size int64
+	)
+
+	keygen, err := NewArgon2WithParams(1, 4*1024, 2)
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	wc := NewPrivacyWriter(&buf, DefaultCipherMethod, keygen)
+	wc.SetSegmentSize(128)
+	if err = wc.NewSalt(); err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+	if err = wc.GenerateKey("some passphrase"); err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+	for _, record := range []string{"first record\n", "second record\n", "lost record\n"} {
+		if _, err = wc.Write([]byte(record)); err != nil {
+			t.Fatal("test preparation failure:", err)
+		}
+		if err = wc.Flush(); err != nil {
+			t.Fatal("test preparation failure:", err)
+		}
+	}
+
+	// the writer died while writing the last segment
+	crashed := buf.Bytes()[:buf.Len()-5]
+	resume := func(b []byte, passphrase string) (*Appender, *bytes.Buffer, error) {
+		size = -1
+		a := NewPrivacyAppender(nil, bytes.NewReader(b), int64(len(b)), keygen)
+		a.SetSegmentSize(128)
+		if err := a.ReadMagic(); err != nil {
+			return nil, nil, err
+		}
+
+		dst := new(bytes.Buffer)
+		a.writer = dst
+		err := a.Resume(passphrase, func(n int64) error {
+			size = n
+			dst.Write(b[:n])
+			return nil
+		})
+		return a, dst, err
+	}
+
+	if _, _, err = resume(crashed, "wrong"); err != ErrWrongPassphrase {
+		t.Fatal("unexpected: it should return ErrWrongPassphrase, got", err)
+	}
+
+	a, dst, err := resume(crashed, "some passphrase")
+	if err != nil {
+		t.Fatal("unexpected: Resume failed", err)
+	}
+	if _, err = a.Write([]byte("after the crash\n")); err != nil {
+		t.Fatal("unexpected: Write failed", err)
+	}
+	if err = a.Close(); err != nil {
+		t.Fatal("unexpected: Close failed", err)
+	}
+
+	out, err := DecryptBytes(dst.Bytes(), WithPassphrase("some passphrase"), WithKeyGen(keygen), WithSegmentSize(128))
+	if err != nil || string(out) != "first record\nsecond record\nafter the crash\n" {
+		t.Fatal("unexpected: DecryptBytes", string(out), err)
+	}
+
+	// a complete stream is continued without truncation
+	if _, _, err = resume(dst.Bytes(), "some passphrase"); err != nil || size != -1 {
+		t.Fatal("unexpected: Resume of a complete stream", size, err)
+	}
+
+	// more than a segment of damage isn't a crash
+	damaged := append(bytes.Clone(crashed), bytes.Repeat([]byte{0xFF}, 400)...)
+	if _, _, err = resume(damaged, "some passphrase"); err == nil || size != -1 {
+		t.Fatal("unexpected: Resume should refuse a long damaged tail", size)
+	}
+}
@@ -15,7 +15,14 @@ type argon2Params struct {
 
 var ErrInvalidParameter = errors.New("invalid parameter")
 
-const argon2KeyGenName = "argon2"
+const (
+	argon2KeyGenName = "argon2"
+
+	// The parameters also come from the unauthenticated header and hint, so they are bounded: a forged file mustn't
+	// make the key derivation allocate terabytes or run for days.
+	argon2MaxTime   = 64
+	argon2MaxMemory = 4 * 1024 * 1024 // 4 GiB, in KiB
+)
 
 func (a argon2Params) GenerateKey(password, salt []byte) []byte {
 	return argon2.IDKey(password, salt, a.Time, a.Memory, a.Threads, 32)
@@ -30,8 +37,10 @@ func NewArgon2() KeyGen {
 	}
 }
 
+// NewArgon2WithParams returns the Argon2id KeyGen with the given parameters, memory in KiB. It returns
+// ErrInvalidParameter for a zero parameter, and for a time above 64 or a memory above 4 GiB.
 func NewArgon2WithParams(time, memory uint32, threads uint8) (k KeyGen, err error) {
-	if time == 0 || memory == 0 || threads == 0 {
+	if time == 0 || memory == 0 || threads == 0 || time > argon2MaxTime || memory > argon2MaxMemory {
 		return nil, ErrInvalidParameter
 	}
 
@@ -0,0 +1,303 @@
+package privacy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strings"
+)
+
+// The armor is a PEM-like text encoding of the encrypted stream
+//
+//	-----BEGIN SPT ENCRYPTED FILE-----
+//	base64 lines, 64 columns each
+//	=CRC24 (optional, as in OpenPGP armor)
+//	-----END SPT ENCRYPTED FILE-----
+//
+// It is safe to paste into emails and chat messages. The reader tolerates re-wrapped lines, surrounding text, and
+// PEM-style "Key: Value" header lines after the begin marker.
+const (
+	ArmorBegin = "-----BEGIN SPT ENCRYPTED FILE-----"
+	ArmorEnd   = "-----END SPT ENCRYPTED FILE-----"
+
+	armorLineLen = 64
+	armorPeekLen = 4096
+
+	crc24Init uint32 = 0xB704CE
+	crc24Poly uint32 = 0x1864CFB
+	crc24Mask uint32 = 0xFFFFFF
+)
+
+var (
+	ErrArmorChecksum  = errors.New("armor checksum mismatch")
+	ErrInvalidArmor   = errors.New("invalid armor")
+	ErrArmorNotClosed = errors.New("armor end marker not found")
+)
+
+func crc24(crc uint32, b []byte) uint32 {
+	for _, c := range b {
+		crc ^= uint32(c) << 16
+		for i := 0; i < 8; i++ {
+			crc <<= 1
+			if crc&0x1000000 != 0 {
+				crc ^= crc24Poly
+			}
+		}
+	}
+
+	return crc & crc24Mask
+}
+
+type lineWriter struct {
+	w   io.Writer
+	col int
+}
+
+func (l *lineWriter) Write(b []byte) (n int, err error) {
+	var (
+		c int
+	)
+
+	for len(b) > 0 {
+		c = min(armorLineLen-l.col, len(b))
+		if _, err = l.w.Write(b[:c]); err != nil {
+			return
+		}
+		n += c
+		l.col += c
+		b = b[c:]
+
+		if l.col == armorLineLen {
+			if _, err = l.w.Write([]byte{'\n'}); err != nil {
+				return
+			}
+			l.col = 0
+		}
+	}
+
+	return
+}
+
+type ArmorWriter struct {
+	w       io.Writer
+	lines   *lineWriter
+	encoder io.WriteCloser
+	crc     uint32
+	withCRC bool
+	begun   bool
+	closed  bool
+}
+
+// NewArmorWriter returns a writer that armors everything written to it. Close writes the checksum, if withCRC is
+// set, and the end marker; it doesn't close w.
+func NewArmorWriter(w io.Writer, withCRC bool) *ArmorWriter {
+	lines := &lineWriter{w: w}
+	return &ArmorWriter{
+		w:       w,
+		lines:   lines,
+		encoder: base64.NewEncoder(base64.StdEncoding, lines),
+		crc:     crc24Init,
+		withCRC: withCRC,
+	}
+}
+
+func (a *ArmorWriter) begin() (err error) {
+	if !a.begun {
+		if _, err = io.WriteString(a.w, ArmorBegin+"\n"); err != nil {
+			return
+		}
+		a.begun = true
+	}
+
+	return
+}
+
+func (a *ArmorWriter) Write(b []byte) (n int, err error) {
+	if err = a.begin(); err != nil {
+		return
+	}
+
+	n, err = a.encoder.Write(b)
+	a.crc = crc24(a.crc, b[:n])
+	return
+}
+
+func (a *ArmorWriter) Close() (err error) {
+	if a.closed {
+		return
+	}
+
+	if err = a.begin(); err != nil {
+		return
+	}
+
+	if err = a.encoder.Close(); err != nil {
+		return
+	}
+
+	var tail strings.Builder
+	if a.lines.col > 0 {
+		tail.WriteByte('\n')
+	}
+	if a.withCRC {
+		tail.WriteByte('=')
+		tail.WriteString(base64.StdEncoding.EncodeToString([]byte{byte(a.crc >> 16), byte(a.crc >> 8), byte(a.crc)}))
+		tail.WriteByte('\n')
+	}
+	tail.WriteString(ArmorEnd + "\n")
+
+	if _, err = io.WriteString(a.w, tail.String()); err != nil {
+		return
+	}
+	a.closed = true
+
+	return
+}
+
+type ArmorReader struct {
+	r       *bufio.Reader
+	begun   bool
+	inBody  bool
+	done    bool
+	pending []byte
+	decoded []byte
+	crc     uint32
+	sum     []byte
+}
+
+// NewArmorReader returns a reader that decodes armored data from r. Any text before the begin marker is skipped.
+// The checksum, if present, is verified when the end marker is reached.
+func NewArmorReader(r io.Reader) *ArmorReader {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+
+	return &ArmorReader{
+		r:   br,
+		crc: crc24Init,
+	}
+}
+
+// IsArmored reports whether a line of the first armorPeekLen bytes of br is an armor begin marker, without consuming
+// anything. Like NewArmorReader, it tolerates text before the marker, e.g. of an email.
+func IsArmored(br *bufio.Reader) bool {
+	b, _ := br.Peek(min(br.Size(), armorPeekLen))
+	for _, line := range bytes.Split(b, []byte("\n")) {
+		if string(bytes.TrimSpace(line)) == ArmorBegin {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (a *ArmorReader) readLine() (line string, err error) {
+	line, err = a.r.ReadString('\n')
+	if err == io.EOF && len(line) > 0 {
+		err = nil
+	}
+
+	return strings.TrimSpace(line), err
+}
+
+func (a *ArmorReader) skipToBegin() (err error) {
+	var (
+		line string
+	)
+
+	for !a.begun {
+		if line, err = a.readLine(); err != nil {
+			if err == io.EOF {
+				return ErrInvalidArmor
+			}
+			return
+		}
+		a.begun = line == ArmorBegin
+	}
+
+	return
+}
+
+func (a *ArmorReader) fill() (err error) {
+	var (
+		line string
+		n    int
+	)
+
+	if line, err = a.readLine(); err != nil {
+		if err == io.EOF {
+			return ErrArmorNotClosed
+		}
+		return
+	}
+
+	switch {
+	case line == ArmorEnd:
+		if len(a.pending) > 0 {
+			return ErrInvalidArmor
+		}
+		if a.sum != nil && (len(a.sum) != 3 || uint32(a.sum[0])<<16|uint32(a.sum[1])<<8|uint32(a.sum[2]) != a.crc) {
+			return ErrArmorChecksum
+		}
+		a.done = true
+		return io.EOF
+	case len(line) == 5 && line[0] == '=':
+		if a.sum, err = base64.StdEncoding.DecodeString(line[1:]); err != nil {
+			return ErrInvalidArmor
+		}
+		return nil
+	case line == "":
+		return nil
+	case strings.Contains(line, ":"):
+		if a.inBody {
+			return ErrInvalidArmor
+		}
+		return nil
+	}
+
+	if a.sum != nil {
+		return ErrInvalidArmor
+	}
+
+	a.inBody = true
+	a.pending = append(a.pending, line...)
+	l := len(a.pending) / 4 * 4
+	if l == 0 {
+		return nil
+	}
+
+	decoded := make([]byte, base64.StdEncoding.DecodedLen(l))
+	if n, err = base64.StdEncoding.Decode(decoded, a.pending[:l]); err != nil {
+		return ErrInvalidArmor
+	}
+	a.pending = append(a.pending[:0], a.pending[l:]...)
+	a.decoded = decoded[:n]
+	a.crc = crc24(a.crc, a.decoded)
+
+	return nil
+}
+
+func (a *ArmorReader) Read(b []byte) (n int, err error) {
+	if !a.begun {
+		if err = a.skipToBegin(); err != nil {
+			return
+		}
+	}
+
+	for len(a.decoded) == 0 {
+		if a.done {
+			return 0, io.EOF
+		}
+		if err = a.fill(); err != nil {
+			return
+		}
+	}
+
+	n = copy(b, a.decoded)
+	a.decoded = a.decoded[n:]
+
+	return n, nil
+}
@@ -0,0 +1,139 @@
+package privacy
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	mr "math/rand"
+	"strings"
+	"testing"
+)
+
+func armorForTest(t *testing.T, plain []byte, withCRC bool) string {
+	t.Helper()
+
+	var out bytes.Buffer
+	a := NewArmorWriter(&out, withCRC)
+	if _, err := a.Write(plain); err != nil {
+		t.Fatal("unexpected: Write failed", err)
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal("unexpected: Close failed", err)
+	}
+
+	return out.String()
+}
+
+func TestArmorRoundTrip(t *testing.T) {
+	ur := mr.New(mr.NewSource(1))
+
+	for _, l := range []int{0, 1, 47, 48, 49, 1000} {
+		for _, withCRC := range []bool{false, true} {
+			plain := make([]byte, l)
+			ur.Read(plain)
+
+			armored := armorForTest(t, plain, withCRC)
+			lines := strings.Split(strings.TrimSuffix(armored, "\n"), "\n")
+			if lines[0] != ArmorBegin || lines[len(lines)-1] != ArmorEnd {
+				t.Fatal("unexpected: missing armor markers")
+			}
+			for _, line := range lines {
+				if len(line) > armorLineLen && line != ArmorBegin && line != ArmorEnd {
+					t.Fatal("unexpected: line is too long", len(line))
+				}
+			}
+			if withCRC != strings.HasPrefix(lines[len(lines)-2], "=") {
+				t.Fatal("unexpected: checksum line", lines[len(lines)-2])
+			}
+
+			b, err := io.ReadAll(NewArmorReader(strings.NewReader(armored)))
+			if err != nil {
+				t.Fatalf("unexpected: Read failed, length %d, crc %v: %v", l, withCRC, err)
+			}
+			if !bytes.Equal(b, plain) {
+				t.Fatalf("unexpected: mismatch plain text, length %d, crc %v", l, withCRC)
+			}
+		}
+	}
+}
+
+func TestArmorReader_Tolerant(t *testing.T) {
+	plain := bytes.Repeat([]byte("some plain text "), 20)
+	armored := armorForTest(t, plain, true)
+
+	b, err := io.ReadAll(NewArmorReader(strings.NewReader(strings.ReplaceAll(armored, "\n", "\r\n"))))
+	if err != nil || !bytes.Equal(b, plain) {
+		t.Fatal("unexpected: CRLF armor failed", err)
+	}
+
+	lines := strings.Split(armored, "\n")
+	var wrapped strings.Builder
+	wrapped.WriteString("Hi, here is the file\n\n" + ArmorBegin + "\nComment: pasted\n\n")
+	joined := strings.Join(lines[1:len(lines)-3], "")
+	for i := 0; i < len(joined); i += 50 {
+		wrapped.WriteString(joined[i:min(i+50, len(joined))] + "\n")
+	}
+	wrapped.WriteString(strings.Join(lines[len(lines)-3:], "\n"))
+
+	b, err = io.ReadAll(NewArmorReader(strings.NewReader(wrapped.String())))
+	if err != nil {
+		t.Fatal("unexpected: Read failed", err)
+	}
+	if !bytes.Equal(b, plain) {
+		t.Fatal("unexpected: mismatch plain text")
+	}
+}
+
+func TestArmorReader_Errors(t *testing.T) {
+	armored := armorForTest(t, []byte("some plain text"), true)
+	lines := strings.Split(armored, "\n")
+
+	tests := []struct {
+		name  string
+		input string
+		want  error
+	}{
+		{
+			name:  "checksum mismatch",
+			input: strings.Replace(armored, lines[1], strings.Replace(lines[1], lines[1][:4], "AAAA", 1), 1),
+			want:  ErrArmorChecksum,
+		},
+		{
+			name:  "no end marker",
+			input: strings.Join(lines[:len(lines)-2], "\n"),
+			want:  ErrArmorNotClosed,
+		},
+		{
+			name:  "no begin marker",
+			input: "some random text\n",
+			want:  ErrInvalidArmor,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := io.ReadAll(NewArmorReader(strings.NewReader(tt.input))); err != tt.want {
+				t.Errorf("Read() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsArmored(t *testing.T) {
+	armored := armorForTest(t, []byte("some plain text"), false)
+
+	if !IsArmored(bufio.NewReader(strings.NewReader("\n  " + armored))) {
+		t.Fatal("unexpected: armored input not detected")
+	}
+
+	if !IsArmored(bufio.NewReader(strings.NewReader("Hi,\n\nhere is the file:\n\n" + armored + "\nBye\n"))) {
+		t.Fatal("unexpected: armored input after some text not detected")
+	}
+
+	if IsArmored(bufio.NewReader(strings.NewReader("quoted: " + armored))) {
+		t.Fatal("unexpected: begin marker within a line detected as armored")
+	}
+
+	if IsArmored(bufio.NewReader(bytes.NewReader([]byte{0x81, 0x02, 0x03}))) {
+		t.Fatal("unexpected: binary input detected as armored")
+	}
+}
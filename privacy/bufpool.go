@@ -0,0 +1,59 @@
+package privacy
+
+import (
+	"math/bits"
+	"sync"
+)
+
+// Segment buffers are pooled in power-of-two size classes of plain text capacity, from 4 KiB up to 1 GiB. Every
+// buffer has bufHeadroom extra bytes for the nonce in front and the AEAD tag behind the plain text. Small payloads
+// only take a small buffer, and large ones grow by doubling until they reach the segment size.
+const (
+	minBufClass = 12
+	maxBufClass = 30
+	minBufSize  = 1 << minBufClass
+	bufHeadroom = 64
+)
+
+var (
+	bufPools [maxBufClass + 1]sync.Pool
+)
+
+func bufClass(n int) int {
+	if n <= minBufSize {
+		return minBufClass
+	}
+
+	return bits.Len(uint(n - 1))
+}
+
+// getBuf returns a buffer that holds at least n bytes of plain text, plus the nonce and the tag.
+func getBuf(n int) []byte {
+	c := bufClass(n)
+	if c > maxBufClass {
+		return make([]byte, n+bufHeadroom)
+	}
+
+	if b, ok := bufPools[c].Get().(*[]byte); ok {
+		return *b
+	}
+
+	return make([]byte, 1<<c+bufHeadroom)
+}
+
+// putBuf wipes b, it may hold plain text, and returns it to its pool.
+func putBuf(b []byte) {
+	n := cap(b) - bufHeadroom
+	if n < minBufSize || n&(n-1) != 0 {
+		return
+	}
+
+	c := bits.Len(uint(n)) - 1
+	if c > maxBufClass {
+		return
+	}
+
+	b = b[:cap(b)]
+	clear(b)
+	bufPools[c].Put(&b)
+}
@@ -0,0 +1,164 @@
+package privacy
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	mr "math/rand"
+	"sync"
+	"testing"
+)
+
+func TestGetBuf(t *testing.T) {
+	tests := []struct {
+		name string
+		arg  int
+		want int
+	}{
+		{name: "zero", arg: 0, want: minBufSize + bufHeadroom},
+		{name: "small", arg: 200, want: minBufSize + bufHeadroom},
+		{name: "exact class", arg: 8192, want: 8192 + bufHeadroom},
+		{name: "round up", arg: 8193, want: 16384 + bufHeadroom},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := getBuf(tt.arg)
+			if len(b) != tt.want {
+				t.Errorf("getBuf() len = %d, want %d", len(b), tt.want)
+			}
+			b[0] = 0xA5
+			putBuf(b)
+		})
+	}
+
+	putBuf(make([]byte, 100))
+}
+
+func TestSmallPayloadBuffer(t *testing.T) {
+	var (
+		sink bytes.Buffer
+	)
+
+	keygen, err := NewArgon2WithParams(1, 4*1024, 2)
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	w := NewPrivacyWriter(&sink, DefaultCipherMethod, keygen)
+	if err = w.NewSalt(); err != nil {
+		t.Fatal("unexpected: NewSalt failed", err)
+	}
+	if err = w.GenerateKey("some passphrase"); err != nil {
+		t.Fatal("unexpected: GenerateKey failed", err)
+	}
+	if _, err = w.Write(make([]byte, 200)); err != nil {
+		t.Fatal("unexpected: Write failed", err)
+	}
+	if len(w.buf) != minBufSize+bufHeadroom {
+		t.Fatal("unexpected: writer buffer size", len(w.buf))
+	}
+	if err = w.Finish(); err != nil {
+		t.Fatal("unexpected: Finish failed", err)
+	}
+	if w.buf != nil {
+		t.Fatal("unexpected: writer buffer is not released")
+	}
+
+	r := NewPrivacyReaderWithKeyGen(bytes.NewReader(sink.Bytes()), keygen)
+	if err = r.ReadMagic(); err != nil {
+		t.Fatal("unexpected: ReadMagic failed", err)
+	}
+	if err = r.GenerateKey("some passphrase"); err != nil {
+		t.Fatal("unexpected: GenerateKey failed", err)
+	}
+	b := make([]byte, 100)
+	if _, err = io.ReadFull(r, b); err != nil {
+		t.Fatal("unexpected: Read failed", err)
+	}
+	if len(r.buf) != minBufSize+bufHeadroom {
+		t.Fatal("unexpected: reader buffer size", len(r.buf))
+	}
+	if _, err = io.ReadAll(r); err != nil {
+		t.Fatal("unexpected: Read failed", err)
+	}
+	if r.buf != nil {
+		t.Fatal("unexpected: reader buffer is not released")
+	}
+}
+
+// TestConcurrentReadWrite runs many writers and readers at once. It is meant to be run with -race.
+func TestConcurrentReadWrite(t *testing.T) {
+	var (
+		wg sync.WaitGroup
+	)
+
+	keygen, err := NewArgon2WithParams(1, 4*1024, 2)
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	errs := make(chan error, 32)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func(i int) {
+			var (
+				sink bytes.Buffer
+				out  bytes.Buffer
+			)
+
+			defer wg.Done()
+
+			plain := make([]byte, mr.New(mr.NewSource(int64(i))).Intn(20000))
+			mr.New(mr.NewSource(int64(i))).Read(plain)
+
+			w := NewPrivacyWriter(&sink, CipherMethodType(i%2+1), keygen)
+			w.SetSegmentSize(uint32(1000 + i*500))
+			if err := w.NewSalt(); err != nil {
+				errs <- err
+				return
+			}
+			if err := w.GenerateKey("some passphrase"); err != nil {
+				errs <- err
+				return
+			}
+			if i%3 == 0 {
+				if err := w.MaskSegmentLength(); err != nil {
+					errs <- err
+					return
+				}
+			}
+			if _, err := io.Copy(w, bytes.NewReader(plain)); err != nil {
+				errs <- err
+				return
+			}
+			if err := w.Close(); err != nil {
+				errs <- err
+				return
+			}
+
+			r := NewPrivacyReaderWithKeyGen(&sink, keygen)
+			r.SetSegmentSize(uint32(1000 + i*500))
+			if err := r.ReadMagic(); err != nil {
+				errs <- err
+				return
+			}
+			if err := r.GenerateKey("some passphrase"); err != nil {
+				errs <- err
+				return
+			}
+			if _, err := io.Copy(&out, r); err != nil {
+				errs <- err
+				return
+			}
+			if !bytes.Equal(out.Bytes(), plain) {
+				errs <- errors.New("mismatch plain text")
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err = range errs {
+		t.Error("unexpected: concurrent round trip failed", err)
+	}
+}
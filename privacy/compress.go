@@ -0,0 +1,108 @@
+package privacy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"github.com/klauspost/compress/zstd"
+	"io"
+)
+
+type CompressionType byte
+
+const (
+	NoCompression   CompressionType = 0x00
+	GzipCompression CompressionType = 0x01
+	ZstdCompression CompressionType = 0x02
+
+	// DefaultCompressionLevel selects the default level of the chosen algorithm. Other levels follow the algorithm's
+	// own scale: 1-9 for gzip and 1-22 for zstd.
+	DefaultCompressionLevel = 0
+)
+
+var (
+	ErrInvalidCompression = errors.New("invalid compression type")
+)
+
+type compressWriteCloser struct {
+	io.WriteCloser
+	wc *WriteCloser
+}
+
+func (c *compressWriteCloser) Close() (err error) {
+	if err = c.WriteCloser.Close(); err != nil {
+		return
+	}
+
+	return c.wc.Close()
+}
+
+// NewCompressWriteCloser records the compression type in the header of wc and returns a writer that compresses
+// the data before it is encrypted by wc. Closing the returned writer closes wc as well. It has to be called before
+// the first Write to wc.
+func NewCompressWriteCloser(wc *WriteCloser, c CompressionType, level int) (w io.WriteCloser, err error) {
+	switch c {
+	case NoCompression:
+		return wc, nil
+	case GzipCompression, ZstdCompression:
+	default:
+		return nil, ErrInvalidCompression
+	}
+
+	// an Appender has written the header already, with the same compression type
+	if b, ok := wc.field(fieldCompression); !ok || !bytes.Equal(b, []byte{byte(c)}) {
+		if err = wc.setField(fieldCompression, []byte{byte(c)}); err != nil {
+			return
+		}
+	}
+
+	if c == GzipCompression {
+		if level == DefaultCompressionLevel {
+			level = gzip.DefaultCompression
+		}
+		if w, err = gzip.NewWriterLevel(wc, level); err != nil {
+			return nil, err
+		}
+	} else {
+		opts := []zstd.EOption{zstd.WithEncoderConcurrency(1)}
+		if level != DefaultCompressionLevel {
+			opts = append(opts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+		}
+		if w, err = zstd.NewWriter(wc, opts...); err != nil {
+			return nil, err
+		}
+	}
+
+	return &compressWriteCloser{
+		WriteCloser: w,
+		wc:          wc,
+	}, nil
+}
+
+// Compression returns the compression type recorded in the header. It is only meaningful after ReadMagic.
+func (r *Reader) Compression() CompressionType {
+	if b, ok := r.field(fieldCompression); ok && len(b) == 1 {
+		return CompressionType(b[0])
+	}
+
+	return NoCompression
+}
+
+// NewDecompressReader returns a reader that transparently decompresses the plain text of r according to the
+// compression type in its header. It has to be called after GenerateKey.
+func NewDecompressReader(r *Reader) (io.ReadCloser, error) {
+	switch r.Compression() {
+	case NoCompression:
+		return io.NopCloser(r), nil
+	case GzipCompression:
+		return gzip.NewReader(r)
+	case ZstdCompression:
+		d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	default:
+		return nil, ErrInvalidCompression
+	}
+}
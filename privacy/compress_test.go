@@ -0,0 +1,129 @@
+package privacy
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestCompressRoundTrip(t *testing.T) {
+	keygen, err := NewArgon2WithParams(1, 4*1024, 2)
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	plain := bytes.Repeat([]byte("some highly compressible log line\n"), 1024)
+	passphrase := "some passphrase"
+
+	tests := []struct {
+		name        string
+		compression CompressionType
+		level       int
+		smaller     bool
+	}{
+		{
+			name:        "none",
+			compression: NoCompression,
+		},
+		{
+			name:        "gzip",
+			compression: GzipCompression,
+			smaller:     true,
+		},
+		{
+			name:        "gzip best",
+			compression: GzipCompression,
+			level:       9,
+			smaller:     true,
+		},
+		{
+			name:        "zstd",
+			compression: ZstdCompression,
+			smaller:     true,
+		},
+		{
+			name:        "zstd level 19",
+			compression: ZstdCompression,
+			level:       19,
+			smaller:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tb := newTBuf(2 * len(plain))
+			wc := NewPrivacyWriteCloserWithKeyGen(tb, DefaultCipherMethod, keygen)
+			wc.SetSegmentSize(uint32(4 * 1024))
+			if err := wc.NewSalt(); err != nil {
+				t.Fatal("unexpected: NewSalt failed", err)
+			}
+			if err := wc.GenerateKey(passphrase); err != nil {
+				t.Fatal("unexpected: GenerateKey failed", err)
+			}
+
+			w, err := NewCompressWriteCloser(wc, tt.compression, tt.level)
+			if err != nil {
+				t.Fatal("unexpected: NewCompressWriteCloser failed", err)
+			}
+			if _, err = w.Write(plain); err != nil {
+				t.Fatal("unexpected: Write failed", err)
+			}
+			if err = w.Close(); err != nil {
+				t.Fatal("unexpected: Close failed", err)
+			}
+
+			if tt.smaller && len(tb.buf) >= len(plain) {
+				t.Fatal("unexpected: output is not compressed")
+			}
+
+			r := NewPrivacyReaderWithKeyGen(bytes.NewReader(tb.buf), keygen)
+			r.SetSegmentSize(uint32(4 * 1024))
+			if err = r.ReadMagic(); err != nil {
+				t.Fatal("unexpected: ReadMagic failed", err)
+			}
+			if r.Compression() != tt.compression {
+				t.Fatal("unexpected: compression type", r.Compression())
+			}
+			if err = r.GenerateKey(passphrase); err != nil {
+				t.Fatal("unexpected: GenerateKey failed", err)
+			}
+
+			dr, err := NewDecompressReader(r)
+			if err != nil {
+				t.Fatal("unexpected: NewDecompressReader failed", err)
+			}
+			defer func() {
+				_ = dr.Close()
+			}()
+
+			b, err := io.ReadAll(dr)
+			if err != nil {
+				t.Fatal("unexpected: Read failed", err)
+			}
+			if !bytes.Equal(b, plain) {
+				t.Fatal("unexpected: mismatch plain text")
+			}
+		})
+	}
+}
+
+func TestNewCompressWriteCloser_AfterWrite(t *testing.T) {
+	keygen, err := NewArgon2WithParams(1, 4*1024, 2)
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	wc := NewPrivacyWriteCloserWithKeyGen(newTBuf(4*1024), DefaultCipherMethod, keygen)
+	if err = wc.NewSalt(); err != nil {
+		t.Fatal("unexpected: NewSalt failed", err)
+	}
+	if err = wc.GenerateKey("some passphrase"); err != nil {
+		t.Fatal("unexpected: GenerateKey failed", err)
+	}
+	if _, err = wc.Write([]byte("some plain text")); err != nil {
+		t.Fatal("unexpected: Write failed", err)
+	}
+
+	if _, err = NewCompressWriteCloser(wc, GzipCompression, DefaultCompressionLevel); err != ErrHeaderWritten {
+		t.Fatal("unexpected: it should return ErrHeaderWritten, got", err)
+	}
+}
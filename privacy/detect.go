@@ -0,0 +1,148 @@
+package privacy
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+)
+
+type Encoding byte
+
+const (
+	BinaryEncoding Encoding = iota
+	Base64Encoding
+	ArmorEncoding
+)
+
+func (e Encoding) String() string {
+	switch e {
+	case BinaryEncoding:
+		return "binary"
+	case Base64Encoding:
+		return "base64"
+	case ArmorEncoding:
+		return "armor"
+	default:
+		return "unknown"
+	}
+}
+
+// HeaderVersion identifies the layout of the magic bytes. LegacyHeader is the plain 16-byte salt, ExtendedHeader
+// carries header fields and a key check value.
+type HeaderVersion byte
+
+const (
+	LegacyHeader HeaderVersion = iota + 1
+	ExtendedHeader
+)
+
+// Format describes what Detect found in front of the encrypted stream.
+type Format struct {
+	Encoding     Encoding
+	ECC          *ECCReader
+	Hint         []byte
+	Header       HeaderVersion
+	CipherMethod CipherMethodType
+}
+
+const (
+	detectPeekLen = 64
+)
+
+var (
+	ErrUnknownFormat = errors.New("unknown input format")
+)
+
+// UnsupportedHeader is returned by Detect when the input looks like an encrypted stream with a header version this
+// build doesn't know, e.g. one written by a newer release.
+type UnsupportedHeader byte
+
+func (u UnsupportedHeader) Error() string {
+	return fmt.Sprintf("unsupported header version or cipher method 0x%02x", byte(u))
+}
+
+// Detect peeks at r and peels off every layer the tool may have put in front of the encrypted stream: the ECC
+// layer, ASCII armor, raw Base64, and the hint. With the ECC layer, format.ECC tells how much damage was repaired
+// once the stream is read. The returned reader is positioned at the magic bytes, ready for
+// NewPrivacyReaderWithKeyGen and ReadMagic.
+func Detect(r io.Reader) (src io.Reader, format *Format, err error) {
+	var (
+		b []byte
+	)
+
+	format = &Format{}
+	br := bufio.NewReader(r)
+	if IsECC(br) {
+		if format.ECC, err = NewECCReader(br); err != nil {
+			return nil, nil, err
+		}
+		br = bufio.NewReader(format.ECC)
+	}
+
+	switch {
+	case IsArmored(br):
+		format.Encoding = ArmorEncoding
+		br = bufio.NewReader(NewArmorReader(br))
+	case isBase64(br):
+		format.Encoding = Base64Encoding
+		br = bufio.NewReader(base64.NewDecoder(base64.StdEncoding, br))
+	}
+
+	if b, err = br.Peek(1); err != nil {
+		return nil, nil, err
+	}
+
+	if b[0] == hintMarker {
+		if format.Hint, err = ReadHint(br); err != nil {
+			return nil, nil, fmt.Errorf("reading hint: %w", err)
+		}
+		if b, err = br.Peek(1); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	format.CipherMethod = CipherMethodType(b[0] &^ headerExtended)
+	switch format.CipherMethod {
+	case XChaCha20Simple, AES256GCMSimple:
+	default:
+		if format.Encoding == BinaryEncoding && format.Hint == nil && !isLikelyHeader(b[0]) {
+			return nil, nil, ErrUnknownFormat
+		}
+		return nil, nil, UnsupportedHeader(b[0])
+	}
+
+	if b[0]&headerExtended != 0 {
+		format.Header = ExtendedHeader
+	} else {
+		format.Header = LegacyHeader
+	}
+
+	return br, format, nil
+}
+
+// isLikelyHeader tells apart an unknown header byte, which has the same shape as the ones we write, from arbitrary
+// data. Future header versions are expected to keep the low cipher method bits small.
+func isLikelyHeader(b byte) bool {
+	return b&^headerExtended < 0x10
+}
+
+func isBase64(br *bufio.Reader) bool {
+	var (
+		n int
+	)
+
+	b, _ := br.Peek(min(br.Size(), detectPeekLen))
+	for _, c := range b {
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9', c == '+', c == '/', c == '=':
+			n++
+		case c == '\r', c == '\n':
+		default:
+			return false
+		}
+	}
+
+	return n >= 4
+}
@@ -0,0 +1,111 @@
+package privacy
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	keygen, err := NewArgon2WithParams(1, 4*1024, 2)
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	plain := []byte("some plain text")
+	tests := []struct {
+		name     string
+		encoding Encoding
+		hint     bool
+	}{
+		{name: "binary", encoding: BinaryEncoding},
+		{name: "binary with hint", encoding: BinaryEncoding, hint: true},
+		{name: "base64", encoding: Base64Encoding},
+		{name: "base64 with hint", encoding: Base64Encoding, hint: true},
+		{name: "armor", encoding: ArmorEncoding},
+		{name: "armor with hint", encoding: ArmorEncoding, hint: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				out bytes.Buffer
+				dst io.WriteCloser
+			)
+
+			switch tt.encoding {
+			case Base64Encoding:
+				dst = base64.NewEncoder(base64.StdEncoding, &out)
+			case ArmorEncoding:
+				dst = NewArmorWriter(&out, true)
+			default:
+				dst = &tBuffer{buf: make([]byte, 0, 4*1024)}
+			}
+
+			if tt.hint {
+				if err := WriteHint(keygen, dst); err != nil {
+					t.Fatal("unexpected: WriteHint failed", err)
+				}
+			}
+
+			wc := NewPrivacyWriteCloserWithKeyGen(dst, AES256GCMSimple, keygen)
+			if err := wc.NewSalt(); err != nil {
+				t.Fatal("unexpected: NewSalt failed", err)
+			}
+			encryptForTest(t, wc, "some passphrase", plain)
+
+			input := out.Bytes()
+			if tb, ok := dst.(*tBuffer); ok {
+				input = tb.buf
+			}
+
+			src, format, err := Detect(bytes.NewReader(input))
+			if err != nil {
+				t.Fatal("unexpected: Detect failed", err)
+			}
+			if format.Encoding != tt.encoding {
+				t.Fatal("unexpected: encoding", format.Encoding)
+			}
+			if (format.Hint != nil) != tt.hint {
+				t.Fatal("unexpected: hint", string(format.Hint))
+			}
+			if format.Header != ExtendedHeader || format.CipherMethod != AES256GCMSimple {
+				t.Fatal("unexpected: header", format.Header, format.CipherMethod)
+			}
+
+			r := NewPrivacyReaderWithKeyGen(src, keygen)
+			if err = r.ReadMagic(); err != nil {
+				t.Fatal("unexpected: ReadMagic failed", err)
+			}
+			if err = r.GenerateKey("some passphrase"); err != nil {
+				t.Fatal("unexpected: GenerateKey failed", err)
+			}
+			b, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal("unexpected: Read failed", err)
+			}
+			if !bytes.Equal(b, plain) {
+				t.Fatal("unexpected: mismatch plain text")
+			}
+		})
+	}
+}
+
+func TestDetect_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input []byte
+		want  error
+	}{
+		{name: "plain text", input: []byte("just some plain text\n"), want: ErrUnknownFormat},
+		{name: "future header", input: append([]byte{0x83}, make([]byte, 31)...), want: UnsupportedHeader(0x83)},
+		{name: "empty", input: []byte{}, want: io.EOF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := Detect(bytes.NewReader(tt.input)); err != tt.want {
+				t.Errorf("Detect() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
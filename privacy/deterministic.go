@@ -0,0 +1,95 @@
+package privacy
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"golang.org/x/crypto/hkdf"
+	"io"
+)
+
+// In deterministic mode the same plain text, passphrase, and options always produce the same encrypted stream,
+// which lets a content-addressed store deduplicate encrypted files. It follows the SIV construction:
+//
+//	sivKey   = KeyGen(passphrase, sivSalt), a fixed salt
+//	salt     = HMAC-SHA256(sivKey, cipher method || plain text), truncated to 15 bytes after the method byte
+//	nonceKey = HKDF(key, salt, nonceKeyInfo)
+//	nonce    = HMAC-SHA256(nonceKey, length prefix || counter || segment plain text), truncated to the nonce size
+//
+// Since the salt depends on the whole plain text, every distinct plain text still gets its own key. The stream
+// format doesn't change, any Reader decrypts it.
+//
+// The trade-off: anyone who sees two encrypted streams learns whether their plain texts are equal, and someone who
+// can guess the whole plain text and the passphrase can confirm the guess. sivKey is derived with a fixed salt, so
+// it is the same for everyone using the same passphrase and KeyGen parameters. Only use this mode when
+// deduplication is worth that leakage, and with a strong passphrase.
+const (
+	sivSalt      = "spt deterministic"
+	nonceKeyInfo = "simple-privacy-tool deterministic nonce"
+)
+
+// NewDeterministicSalt derives the salt from the whole plain text read from plain, and switches wc to deterministic
+// nonces. It replaces NewSalt; the same plain text has to be written afterwards.
+func (wc *WriteCloser) NewDeterministicSalt(passphrase string, plain io.Reader) (err error) {
+	if wc.cmType == Uninitialised {
+		return ErrUninitialisedMethod
+	}
+
+	salt := make([]byte, sha256.Size)
+	copy(salt, sivSalt)
+	mac := hmac.New(sha256.New, wc.keygen.GenerateKey([]byte(passphrase), salt[:16]))
+	mac.Write([]byte{byte(wc.cmType)})
+	if _, err = io.Copy(mac, plain); err != nil {
+		return
+	}
+
+	salt = mac.Sum(salt[:0])
+	salt[0] = byte(wc.cmType) | headerExtended
+	if err = wc.SetSalt(salt[:16]); err != nil {
+		return
+	}
+	wc.deterministic = true
+
+	return
+}
+
+func (p *Privacy) deriveNonceKey(key []byte) (err error) {
+	p.nonceKey = make([]byte, sha256.Size)
+	_, err = io.ReadFull(hkdf.New(sha256.New, key, p.salt, []byte(nonceKeyInfo)), p.nonceKey)
+	return
+}
+
+// deterministicNonce fills nonce for the segment with the given associated data and plain text.
+func (p *Privacy) deterministicNonce(nonce, ad, plaintext []byte) {
+	mac := hmac.New(sha256.New, p.nonceKey)
+	mac.Write(ad)
+	mac.Write(plaintext)
+	copy(nonce, mac.Sum(nil))
+}
+
+// deterministicSource returns a reader with the same content as src for the second pass of deterministic mode, and
+// passes the content to hash. A seekable src is rewound, anything else is buffered in memory.
+func deterministicSource(src io.Reader, hash func(io.Reader) error) (io.Reader, error) {
+	if rs, ok := src.(io.ReadSeeker); ok {
+		if start, err := rs.Seek(0, io.SeekCurrent); err == nil {
+			if err = hash(rs); err != nil {
+				return nil, err
+			}
+			if _, err = rs.Seek(start, io.SeekStart); err != nil {
+				return nil, err
+			}
+			return rs, nil
+		}
+	}
+
+	b, err := io.ReadAll(src)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = hash(bytes.NewReader(b)); err != nil {
+		return nil, err
+	}
+
+	return bytes.NewReader(b), nil
+}
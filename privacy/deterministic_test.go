@@ -0,0 +1,64 @@
+package privacy
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestDeterministic(t *testing.T) {
+	keygen, err := NewArgon2WithParams(1, 4*1024, 2)
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	plain := bytes.Repeat([]byte("some plain text "), 100)
+	opts := []Option{WithPassphrase("some passphrase"), WithKeyGen(keygen), WithSegmentSize(300),
+		WithPadding(Padding{Scheme: BlockPadding, Size: 128}), WithMaskedLength(), WithDeterministic()}
+
+	first, err := EncryptBytes(plain, opts...)
+	if err != nil {
+		t.Fatal("unexpected: EncryptBytes failed", err)
+	}
+
+	var buf bytes.Buffer
+	// not seekable, it is buffered
+	if err = Encrypt(&buf, io.MultiReader(bytes.NewReader(plain)), opts...); err != nil {
+		t.Fatal("unexpected: Encrypt failed", err)
+	}
+	if !bytes.Equal(first, buf.Bytes()) {
+		t.Fatal("unexpected: the same plain text should give the same output")
+	}
+
+	changed := bytes.Clone(plain)
+	changed[len(changed)-1] ^= 1
+	other, err := EncryptBytes(changed, opts...)
+	if err != nil {
+		t.Fatal("unexpected: EncryptBytes failed", err)
+	}
+	if bytes.Equal(first[:20], other[:20]) {
+		t.Fatal("unexpected: a different plain text should get a different salt")
+	}
+
+	other, err = EncryptBytes(plain, append(opts[:len(opts)-1:len(opts)-1], WithPassphrase("other passphrase"),
+		WithDeterministic())...)
+	if err != nil {
+		t.Fatal("unexpected: EncryptBytes failed", err)
+	}
+	if bytes.Equal(first, other) {
+		t.Fatal("unexpected: a different passphrase should give a different output")
+	}
+
+	out, err := DecryptBytes(first, WithPassphrase("some passphrase"), WithKeyGen(keygen), WithSegmentSize(300))
+	if err != nil || !bytes.Equal(out, plain) {
+		t.Fatal("unexpected: DecryptBytes", err)
+	}
+
+	random, err := EncryptBytes(plain, opts[:len(opts)-1]...)
+	if err != nil {
+		t.Fatal("unexpected: EncryptBytes failed", err)
+	}
+	if again, _ := EncryptBytes(plain, opts[:len(opts)-1]...); bytes.Equal(random, again) {
+		t.Fatal("unexpected: the default mode should be randomized")
+	}
+}
@@ -0,0 +1,417 @@
+package privacy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+
+	"github.com/klauspost/reedsolomon"
+)
+
+// The ECC layer protects a stream against bit rot with Reed-Solomon parity. It starts with eccHeaderCopies copies of
+// the header
+//
+//	magic         eccMagic
+//	data shards   2 bytes
+//	parity shards 2 bytes
+//	shard size    4 bytes
+//	CRC-32C       4 bytes, of the above
+//
+// followed by groups of data shards and their parity shards. Each shard is written with a CRC-32C of the group number,
+// the shard number, and the shard, so a damaged shard is found and rebuilt from the others, as long as no more than
+// the parity shards of a group are damaged. The last group holds the rest of the stream, padded with 0x80 and zeros
+// to a multiple of the data shards; its shards are smaller, and it's found by the end of the stream.
+//
+// Every group of eccDataShards shards of eccShardSize bytes covers 512 KiB, so a burst of damage up to the parity
+// shards times 4 KiB, e.g. a scratch, is repaired as well.
+const (
+	eccMagic        = "\x89SPTECC\n"
+	eccHeaderLen    = len(eccMagic) + 12
+	eccHeaderCopies = 3
+	eccCRCLen       = 4
+	eccPadding      = 0x80
+
+	eccDataShards   = 128
+	eccShardSize    = 4096
+	eccMaxShardSize = 1 << 20
+)
+
+var (
+	ErrInvalidECC       = errors.New("invalid ECC layer")
+	ErrECCUnrepairable  = errors.New("too much damage to repair")
+	ErrECCTruncated     = errors.New("ECC layer is truncated")
+	ErrInvalidECCAmount = errors.New("invalid ECC amount, valid values are 1% to 100%")
+	ErrECCRandomAccess  = errors.New("random access and appending aren't supported with the ECC layer")
+
+	eccCRCTable = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// ECCWriter adds the ECC layer to the stream written to it. Close writes the last group; it doesn't close the
+// underlying writer.
+type ECCWriter struct {
+	w             io.Writer
+	enc           reedsolomon.Encoder
+	dataShards    int
+	parityShards  int
+	shardSize     int
+	buf           []byte
+	n             int
+	group         uint64
+	headerWritten bool
+	closed        bool
+}
+
+// NewECCWriter returns an ECCWriter with parity of percent of the data, from 1 to 100.
+func NewECCWriter(w io.Writer, percent int) (*ECCWriter, error) {
+	if percent < 1 || percent > 100 {
+		return nil, ErrInvalidECCAmount
+	}
+
+	return NewECCWriterWithParams(w, eccDataShards, (eccDataShards*percent+99)/100, eccShardSize)
+}
+
+// NewECCWriterWithParams returns an ECCWriter with the given layout, e.g. the one of an ECCReader to rewrite a
+// repaired stream exactly.
+func NewECCWriterWithParams(w io.Writer, dataShards, parityShards, shardSize int) (*ECCWriter, error) {
+	if dataShards < 1 || parityShards < 1 || shardSize < 1 || shardSize > eccMaxShardSize {
+		return nil, ErrInvalidECC
+	}
+
+	enc, err := reedsolomon.New(dataShards, parityShards)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidECC, err)
+	}
+
+	return &ECCWriter{
+		w:            w,
+		enc:          enc,
+		dataShards:   dataShards,
+		parityShards: parityShards,
+		shardSize:    shardSize,
+		buf:          make([]byte, dataShards*shardSize),
+	}, nil
+}
+
+func (e *ECCWriter) Write(b []byte) (n int, err error) {
+	var (
+		c int
+	)
+
+	if e.closed {
+		return 0, ErrFinished
+	}
+
+	for len(b) > 0 {
+		c = copy(e.buf[e.n:], b)
+		e.n += c
+		n += c
+		b = b[c:]
+
+		// a full buffer is written right away, so the last group in Close is never larger than the others
+		if e.n == len(e.buf) {
+			if err = e.writeGroup(e.buf, e.shardSize); err != nil {
+				return
+			}
+			e.n = 0
+		}
+	}
+
+	return
+}
+
+// Close pads and writes the last group.
+func (e *ECCWriter) Close() error {
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+
+	size := (e.n + 1 + e.dataShards - 1) / e.dataShards
+	data := append(e.buf[:e.n], eccPadding)
+	data = append(data, make([]byte, size*e.dataShards-len(data))...)
+
+	return e.writeGroup(data, size)
+}
+
+func (e *ECCWriter) writeHeader() (err error) {
+	var (
+		header [eccHeaderLen]byte
+	)
+
+	copy(header[:], eccMagic)
+	binary.BigEndian.PutUint16(header[len(eccMagic):], uint16(e.dataShards))
+	binary.BigEndian.PutUint16(header[len(eccMagic)+2:], uint16(e.parityShards))
+	binary.BigEndian.PutUint32(header[len(eccMagic)+4:], uint32(e.shardSize))
+	binary.BigEndian.PutUint32(header[len(eccMagic)+8:], crc32.Checksum(header[:len(eccMagic)+8], eccCRCTable))
+
+	for i := 0; i < eccHeaderCopies; i++ {
+		if _, err = e.w.Write(header[:]); err != nil {
+			return
+		}
+	}
+	e.headerWritten = true
+
+	return
+}
+
+// writeGroup writes data, dataShards shards of size bytes, with its parity.
+func (e *ECCWriter) writeGroup(data []byte, size int) (err error) {
+	var (
+		crc [eccCRCLen]byte
+	)
+
+	if !e.headerWritten {
+		if err = e.writeHeader(); err != nil {
+			return
+		}
+	}
+
+	shards := make([][]byte, e.dataShards+e.parityShards)
+	for i := range shards {
+		if i < e.dataShards {
+			shards[i] = data[i*size : (i+1)*size]
+		} else {
+			shards[i] = make([]byte, size)
+		}
+	}
+
+	if err = e.enc.Encode(shards); err != nil {
+		return
+	}
+
+	for i, shard := range shards {
+		binary.BigEndian.PutUint32(crc[:], shardChecksum(e.group, i, shard))
+		if _, err = e.w.Write(shard); err != nil {
+			return
+		}
+		if _, err = e.w.Write(crc[:]); err != nil {
+			return
+		}
+	}
+	e.group++
+
+	return
+}
+
+// ECCReader removes the ECC layer, repairing the damaged shards it finds.
+type ECCReader struct {
+	r            *bufio.Reader
+	enc          reedsolomon.Encoder
+	dataShards   int
+	parityShards int
+	shardSize    int
+	raw          []byte
+	out          []byte
+	group        uint64
+	final        bool
+	damaged      int
+}
+
+// IsECC tells whether br starts with the ECC layer, with at least one intact header copy.
+func IsECC(br *bufio.Reader) bool {
+	b, _ := br.Peek(eccHeaderLen * eccHeaderCopies)
+	_, ok := parseECCHeader(b)
+	return ok
+}
+
+func parseECCHeader(b []byte) (header []byte, ok bool) {
+	for i := 0; i < eccHeaderCopies && len(b) >= (i+1)*eccHeaderLen; i++ {
+		header = b[i*eccHeaderLen : (i+1)*eccHeaderLen]
+		if string(header[:len(eccMagic)]) == eccMagic &&
+			crc32.Checksum(header[:len(eccMagic)+8], eccCRCTable) == binary.BigEndian.Uint32(header[len(eccMagic)+8:]) {
+			return header, true
+		}
+	}
+
+	return nil, false
+}
+
+// NewECCReader reads the header of the ECC layer from r, using the first intact copy. The other copies which differ
+// from it are counted as damaged.
+func NewECCReader(r io.Reader) (e *ECCReader, err error) {
+	var (
+		b [eccHeaderLen * eccHeaderCopies]byte
+	)
+
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+
+	if _, err = io.ReadFull(br, b[:]); err != nil {
+		return nil, ErrInvalidECC
+	}
+
+	header, ok := parseECCHeader(b[:])
+	if !ok {
+		return nil, ErrInvalidECC
+	}
+
+	damaged := 0
+	for i := 0; i < eccHeaderCopies; i++ {
+		if !bytes.Equal(b[i*eccHeaderLen:(i+1)*eccHeaderLen], header) {
+			damaged++
+		}
+	}
+
+	e = &ECCReader{
+		r:            br,
+		dataShards:   int(binary.BigEndian.Uint16(header[len(eccMagic):])),
+		parityShards: int(binary.BigEndian.Uint16(header[len(eccMagic)+2:])),
+		shardSize:    int(binary.BigEndian.Uint32(header[len(eccMagic)+4:])),
+		damaged:      damaged,
+	}
+	if e.dataShards < 1 || e.parityShards < 1 || e.shardSize < 1 || e.shardSize > eccMaxShardSize {
+		return nil, ErrInvalidECC
+	}
+
+	if e.enc, err = reedsolomon.New(e.dataShards, e.parityShards); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidECC, err)
+	}
+	e.raw = make([]byte, (e.dataShards+e.parityShards)*(e.shardSize+eccCRCLen))
+
+	return
+}
+
+// Params returns the layout of the ECC layer, for NewECCWriterWithParams.
+func (e *ECCReader) Params() (dataShards, parityShards, shardSize int) {
+	return e.dataShards, e.parityShards, e.shardSize
+}
+
+// Percent returns the parity as a percentage of the data, the inverse of NewECCWriter.
+func (e *ECCReader) Percent() int {
+	return e.parityShards * 100 / e.dataShards
+}
+
+// Damaged returns the number of damaged header copies and shards repaired so far.
+func (e *ECCReader) Damaged() int {
+	return e.damaged
+}
+
+func (e *ECCReader) Read(b []byte) (n int, err error) {
+	for len(e.out) == 0 {
+		if e.final {
+			return 0, io.EOF
+		}
+		if err = e.readGroup(); err != nil {
+			return
+		}
+	}
+
+	n = copy(b, e.out)
+	e.out = e.out[n:]
+
+	return
+}
+
+func (e *ECCReader) readGroup() (err error) {
+	var (
+		n   int
+		raw = e.raw
+	)
+
+	n, err = io.ReadFull(e.r, raw)
+	switch {
+	case err == nil:
+		if _, pErr := e.r.Peek(1); pErr == io.EOF {
+			e.final = true
+		}
+	case errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, io.EOF):
+		e.final = true
+		raw = raw[:n]
+	default:
+		return
+	}
+
+	total := e.dataShards + e.parityShards
+	if len(raw)%total != 0 || len(raw)/total <= eccCRCLen {
+		return ErrECCTruncated
+	}
+	size := len(raw)/total - eccCRCLen
+
+	shards := make([][]byte, total)
+	bad := 0
+	for i := range shards {
+		shard := raw[i*(size+eccCRCLen) : (i+1)*(size+eccCRCLen)]
+		if shardChecksum(e.group, i, shard[:size]) == binary.BigEndian.Uint32(shard[size:]) {
+			shards[i] = shard[:size]
+		} else {
+			bad++
+		}
+	}
+
+	if bad > e.parityShards {
+		return fmt.Errorf("%w: %d of %d shards damaged in group %d, at most %d can be repaired", ErrECCUnrepairable,
+			bad, total, e.group, e.parityShards)
+	}
+	if bad > 0 {
+		if err = e.enc.ReconstructData(shards); err != nil {
+			return fmt.Errorf("%w: %w", ErrECCUnrepairable, err)
+		}
+		e.damaged += bad
+	}
+
+	out := make([]byte, 0, e.dataShards*size)
+	for _, shard := range shards[:e.dataShards] {
+		out = append(out, shard...)
+	}
+
+	if e.final {
+		i := len(out) - 1
+		for i >= 0 && out[i] == 0 {
+			i--
+		}
+		if i < 0 || out[i] != eccPadding {
+			return fmt.Errorf("%w: no padding in the last group", ErrInvalidECC)
+		}
+		out = out[:i]
+	}
+
+	e.out = out
+	e.group++
+
+	return nil
+}
+
+func shardChecksum(group uint64, index int, shard []byte) uint32 {
+	var (
+		b [10]byte
+	)
+
+	binary.BigEndian.PutUint64(b[:], group)
+	binary.BigEndian.PutUint16(b[8:], uint16(index))
+
+	return crc32.Update(crc32.Checksum(b[:], eccCRCTable), eccCRCTable, shard)
+}
+
+// Repair reads the stream with the ECC layer from src and writes it to dst with the same layout, the damaged header
+// copies and shards rebuilt. It returns the number of damaged header copies and shards; when it's zero, dst is a copy
+// of src.
+func Repair(dst io.Writer, src io.Reader) (damaged int, err error) {
+	var (
+		r *ECCReader
+		w *ECCWriter
+	)
+
+	if r, err = NewECCReader(src); err != nil {
+		return
+	}
+
+	if w, err = NewECCWriterWithParams(dst, r.dataShards, r.parityShards, r.shardSize); err != nil {
+		return
+	}
+
+	if _, err = io.Copy(w, r); err != nil {
+		return
+	}
+
+	if err = w.Close(); err != nil {
+		return
+	}
+
+	return r.Damaged(), nil
+}
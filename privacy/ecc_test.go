@@ -0,0 +1,179 @@
+package privacy
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+)
+
+func eccEncode(t *testing.T, data []byte, dataShards, parityShards, shardSize int) []byte {
+	var (
+		buf bytes.Buffer
+	)
+
+	w, err := NewECCWriterWithParams(&buf, dataShards, parityShards, shardSize)
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+	if _, err = w.Write(data); err != nil {
+		t.Fatal("unexpected: Write failed", err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal("unexpected: Close failed", err)
+	}
+
+	return buf.Bytes()
+}
+
+func eccDecode(b []byte) ([]byte, *ECCReader, error) {
+	br := bufio.NewReader(bytes.NewReader(b))
+	if !IsECC(br) {
+		return nil, nil, ErrInvalidECC
+	}
+
+	r, err := NewECCReader(br)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	out, err := io.ReadAll(r)
+	return out, r, err
+}
+
+func TestECC(t *testing.T) {
+	const (
+		dataShards   = 4
+		parityShards = 2
+		shardSize    = 16
+		groupLen     = dataShards * shardSize
+		rawShardLen  = shardSize + eccCRCLen
+		headersLen   = eccHeaderLen * eccHeaderCopies
+	)
+
+	for _, size := range []int{0, 1, groupLen - 1, groupLen, groupLen + 1, 10*groupLen + 7} {
+		data := make([]byte, size)
+		if _, err := rand.Read(data); err != nil {
+			t.Fatal("test preparation failure:", err)
+		}
+
+		out, r, err := eccDecode(eccEncode(t, data, dataShards, parityShards, shardSize))
+		if err != nil || !bytes.Equal(out, data) || r.Damaged() != 0 {
+			t.Fatal("unexpected: round trip of", size, "bytes", err)
+		}
+	}
+
+	data := make([]byte, 3*groupLen+5)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+	encoded := eccEncode(t, data, dataShards, parityShards, shardSize)
+
+	// the first header copy, a data shard and a parity shard of the second group
+	damaged := bytes.Clone(encoded)
+	damaged[3] ^= 0xFF
+	second := headersLen + (dataShards+parityShards)*rawShardLen
+	damaged[second+5] ^= 0x01
+	damaged[second+(dataShards+1)*rawShardLen+2] ^= 0x80
+	out, r, err := eccDecode(damaged)
+	if err != nil || !bytes.Equal(out, data) {
+		t.Fatal("unexpected: it should repair the damage", err)
+	}
+	if r.Damaged() != 3 {
+		t.Fatal("unexpected: damaged header copies and shards", r.Damaged())
+	}
+
+	var repaired bytes.Buffer
+	if n, err := Repair(&repaired, bytes.NewReader(damaged)); err != nil || n != 3 {
+		t.Fatal("unexpected: Repair returned", n, err)
+	}
+	// the damaged header copy is rewritten too
+	if !bytes.Equal(repaired.Bytes(), encoded) {
+		t.Fatal("unexpected: the repaired stream differs from the original")
+	}
+
+	// only a header copy, which Repair has to rewrite as well
+	headerOnly := bytes.Clone(encoded)
+	headerOnly[eccHeaderLen+len(eccMagic)] ^= 0x01
+	repaired.Reset()
+	if n, err := Repair(&repaired, bytes.NewReader(headerOnly)); err != nil || n != 1 ||
+		!bytes.Equal(repaired.Bytes(), encoded) {
+		t.Fatal("unexpected: Repair of a damaged header copy returned", n, err)
+	}
+
+	damaged[second+2*rawShardLen] ^= 0x01
+	if _, _, err = eccDecode(damaged); !errors.Is(err, ErrECCUnrepairable) {
+		t.Fatal("unexpected: it should return ErrECCUnrepairable, got", err)
+	}
+
+	if _, _, err = eccDecode(encoded[:len(encoded)-3]); !errors.Is(err, ErrECCTruncated) {
+		t.Fatal("unexpected: it should return ErrECCTruncated, got", err)
+	}
+
+	if _, err = NewECCWriter(io.Discard, 0); err != ErrInvalidECCAmount {
+		t.Fatal("unexpected: it should return ErrInvalidECCAmount, got", err)
+	}
+}
+
+func TestECCPercent(t *testing.T) {
+	for percent := 1; percent <= 100; percent++ {
+		w, err := NewECCWriter(io.Discard, percent)
+		if err != nil {
+			t.Fatal("unexpected: NewECCWriter failed", err)
+		}
+		r := &ECCReader{dataShards: w.dataShards, parityShards: w.parityShards}
+		if r.Percent() != percent {
+			t.Fatal("unexpected: Percent", r.Percent(), "for", percent)
+		}
+	}
+}
+
+func TestEncryptECC(t *testing.T) {
+	keygen, err := NewArgon2WithParams(1, 4*1024, 2)
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	plain := make([]byte, 3*1024*1024+11)
+	if _, err = rand.Read(plain); err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	for _, opts := range [][]Option{
+		{WithECC(10)},
+		{WithECC(5), WithArmor(true), WithHint()},
+	} {
+		opts = append(opts, WithPassphrase("some passphrase"), WithKeyGen(keygen))
+		encrypted, err := EncryptBytes(plain, opts...)
+		if err != nil {
+			t.Fatal("unexpected: EncryptBytes failed", err)
+		}
+
+		// a scratch across a few shards in the middle
+		for i := len(encrypted) / 2; i < len(encrypted)/2+2*eccShardSize; i++ {
+			encrypted[i] ^= 0x55
+		}
+
+		_, format, err := Detect(bytes.NewReader(encrypted))
+		if err != nil || format.ECC == nil {
+			t.Fatal("unexpected: Detect should find the ECC layer", err)
+		}
+
+		decrypted, err := DecryptBytes(encrypted, opts...)
+		if err != nil || !bytes.Equal(decrypted, plain) {
+			t.Fatal("unexpected: DecryptBytes should repair the damage", err)
+		}
+	}
+
+	encrypted, err := EncryptBytes([]byte("hello"), WithECC(10), WithPassphrase("some passphrase"),
+		WithKeyGen(keygen))
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+	ra := NewPrivacyReaderAt(bytes.NewReader(encrypted), int64(len(encrypted)), keygen)
+	if err = ra.readMagic(); err != ErrECCRandomAccess {
+		t.Fatal("unexpected: it should return ErrECCRandomAccess, got", err)
+	}
+}
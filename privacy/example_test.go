@@ -0,0 +1,62 @@
+package privacy_test
+
+import (
+	"bytes"
+	"fmt"
+	"gitea.suyono.dev/suyono/simple-privacy-tool/privacy"
+	"log"
+	"strings"
+)
+
+func ExampleEncryptBytes() {
+	encrypted, err := privacy.EncryptBytes([]byte("my secret"), privacy.WithPassphrase("correct horse battery staple"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	plain, err := privacy.DecryptBytes(encrypted, privacy.WithPassphrase("correct horse battery staple"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println(string(plain))
+	// Output: my secret
+}
+
+func ExampleEncrypt() {
+	var armored bytes.Buffer
+
+	err := privacy.Encrypt(&armored, strings.NewReader("my secret"),
+		privacy.WithPassphrase("correct horse battery staple"),
+		privacy.WithArmor(true),
+		privacy.WithCompression(privacy.GzipCompression, privacy.DefaultCompressionLevel),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println(strings.HasPrefix(armored.String(), privacy.ArmorBegin))
+	// Output: true
+}
+
+func ExampleDecrypt() {
+	encrypted, err := privacy.EncryptBytes([]byte("my secret"), privacy.WithPassphrase("correct horse battery staple"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var plain bytes.Buffer
+	err = privacy.Decrypt(&plain, bytes.NewReader(encrypted),
+		privacy.WithPassphraseFunc(func(attempt int) (string, error) {
+			// ask the user here; attempt starts at 1
+			return "correct horse battery staple", nil
+		}),
+		privacy.WithAttempts(3),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println(plain.String())
+	// Output: my secret
+}
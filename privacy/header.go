@@ -0,0 +1,193 @@
+package privacy
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"golang.org/x/crypto/hkdf"
+	"io"
+)
+
+// The extended header is marked by the highest bit of the first salt byte. The layout is
+//
+//	salt       16 bytes, salt[0] = cipher method type | headerExtended
+//	fieldsLen  2 bytes, little endian
+//	fields     fieldsLen bytes, a sequence of tag (1 byte), length (1 byte), and value
+//	keyCheck   32 bytes, HMAC-SHA256 over all the preceding header bytes
+//
+// The HMAC key is derived from the encryption key with HKDF, so the check value confirms the passphrase
+// without revealing anything about the key itself.
+const (
+	headerExtended  byte = 0x80
+	fieldsLenBytes  int  = 2
+	keyCheckLen     int  = sha256.Size
+	maxHeaderFields int  = 4096
+
+	keyCheckInfo = "simple-privacy-tool key check"
+)
+
+const (
+	fieldCompression byte = 0x01
+	fieldPadding     byte = 0x02
+	fieldLengthMask  byte = 0x03
+	fieldKeyGen      byte = 0x04
+)
+
+var (
+	ErrWrongPassphrase     = errors.New("wrong passphrase")
+	ErrInvalidHeaderFields = errors.New("invalid header fields")
+	ErrHeaderWritten       = errors.New("header has been written")
+)
+
+func (p *Privacy) isExtended() bool {
+	return len(p.salt) > 0 && p.salt[0]&headerExtended != 0
+}
+
+func (p *Privacy) headerBytes() []byte {
+	b := make([]byte, 0, len(p.salt)+fieldsLenBytes+len(p.fields))
+	b = append(b, p.salt...)
+	b = binary.LittleEndian.AppendUint16(b, uint16(len(p.fields)))
+	return append(b, p.fields...)
+}
+
+func (p *Privacy) field(tag byte) ([]byte, bool) {
+	b := p.fields
+	for len(b) >= 2 {
+		l := int(b[1])
+		if len(b) < 2+l {
+			return nil, false
+		}
+		if b[0] == tag {
+			return b[2 : 2+l], true
+		}
+		b = b[2+l:]
+	}
+
+	return nil, false
+}
+
+func (p *Privacy) setField(tag byte, value []byte) error {
+	var (
+		fields []byte
+	)
+
+	if len(value) > 0xFF {
+		return ErrInvalidHeaderFields
+	}
+
+	b := p.fields
+	for len(b) >= 2 {
+		l := int(b[1])
+		if b[0] != tag {
+			fields = append(fields, b[:2+l]...)
+		}
+		b = b[2+l:]
+	}
+	fields = append(fields, tag, byte(len(value)))
+	fields = append(fields, value...)
+
+	if len(fields) > maxHeaderFields {
+		return ErrInvalidHeaderFields
+	}
+	p.fields = fields
+
+	return nil
+}
+
+func validateFields(b []byte) error {
+	for len(b) > 0 {
+		if len(b) < 2 || len(b) < 2+int(b[1]) {
+			return ErrInvalidHeaderFields
+		}
+		b = b[2+int(b[1]):]
+	}
+
+	return nil
+}
+
+func (p *Privacy) deriveCheckKey(key []byte) (err error) {
+	p.checkKey = make([]byte, sha256.Size)
+	_, err = io.ReadFull(hkdf.New(sha256.New, key, p.salt, []byte(keyCheckInfo)), p.checkKey)
+	return
+}
+
+func (p *Privacy) keyCheck() []byte {
+	mac := hmac.New(sha256.New, p.checkKey)
+	mac.Write(p.headerBytes())
+	return mac.Sum(nil)
+}
+
+func (wc *WriteCloser) setField(tag byte, value []byte) error {
+	if wc.magicWritten {
+		return ErrHeaderWritten
+	}
+
+	return wc.Privacy.setField(tag, value)
+}
+
+func (wc *WriteCloser) writeHeader() (err error) {
+	if !wc.isExtended() {
+		if len(wc.fields) > 0 {
+			return ErrInvalidHeaderFields
+		}
+		_, err = wc.writeUp(wc.salt)
+		return
+	}
+
+	if _, err = wc.writeUp(wc.headerBytes()); err != nil {
+		return
+	}
+
+	_, err = wc.writeUp(wc.keyCheck())
+	return
+}
+
+func (r *Reader) readExtendedHeader() (err error) {
+	var (
+		fieldsLen int
+	)
+
+	lb := make([]byte, fieldsLenBytes)
+	if _, err = r.readUp(lb); err != nil {
+		return
+	}
+
+	fieldsLen = int(binary.LittleEndian.Uint16(lb))
+	if fieldsLen > maxHeaderFields {
+		return ErrInvalidHeaderFields
+	}
+
+	r.fields = make([]byte, fieldsLen)
+	if fieldsLen > 0 {
+		if _, err = r.readUp(r.fields); err != nil {
+			return
+		}
+	}
+
+	if err = validateFields(r.fields); err != nil {
+		return
+	}
+
+	r.expectedKeyCheck = make([]byte, keyCheckLen)
+	if _, err = r.readUp(r.expectedKeyCheck); err != nil {
+		return
+	}
+
+	return
+}
+
+// GenerateKey derives the key from the passphrase. If the input carries an extended header, the passphrase is
+// verified against the key check value and ErrWrongPassphrase is returned on mismatch.
+func (r *Reader) GenerateKey(passphrase string) (err error) {
+	if err = r.Privacy.GenerateKey(passphrase); err != nil {
+		return
+	}
+
+	if r.expectedKeyCheck != nil && !hmac.Equal(r.keyCheck(), r.expectedKeyCheck) {
+		r.aead = nil
+		return ErrWrongPassphrase
+	}
+
+	return
+}
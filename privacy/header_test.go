@@ -0,0 +1,126 @@
+package privacy
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func encryptForTest(t *testing.T, wc *WriteCloser, passphrase string, plain []byte) {
+	t.Helper()
+
+	if err := wc.GenerateKey(passphrase); err != nil {
+		t.Fatal("unexpected: GenerateKey failed", err)
+	}
+
+	if _, err := wc.Write(plain); err != nil {
+		t.Fatal("unexpected: Write failed", err)
+	}
+
+	if err := wc.Close(); err != nil {
+		t.Fatal("unexpected: Close failed", err)
+	}
+}
+
+func TestReader_GenerateKey_WrongPassphrase(t *testing.T) {
+	keygen, err := NewArgon2WithParams(1, 4*1024, 2)
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	tb := newTBuf(4 * 1024)
+	wc := NewPrivacyWriteCloserWithKeyGen(tb, AES256GCMSimple, keygen)
+	if err = wc.NewSalt(); err != nil {
+		t.Fatal("unexpected: NewSalt failed", err)
+	}
+	encryptForTest(t, wc, "some passphrase", []byte("some plain text"))
+
+	if len(tb.buf) < len(wc.salt)+fieldsLenBytes+keyCheckLen {
+		t.Fatal("unexpected: header is too short")
+	}
+
+	r := NewPrivacyReaderWithKeyGen(bytes.NewReader(tb.buf), keygen)
+	if err = r.ReadMagic(); err != nil {
+		t.Fatal("unexpected: ReadMagic failed", err)
+	}
+
+	if err = r.GenerateKey("wrong passphrase"); err != ErrWrongPassphrase {
+		t.Fatal("unexpected: it should return ErrWrongPassphrase, got", err)
+	}
+
+	if _, err = r.Read(make([]byte, 16)); err != ErrInvalidKeyState {
+		t.Fatal("unexpected: Read should fail after a wrong passphrase, got", err)
+	}
+
+	if err = r.GenerateKey("some passphrase"); err != nil {
+		t.Fatal("unexpected: GenerateKey failed", err)
+	}
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal("unexpected: Read failed", err)
+	}
+
+	if string(b) != "some plain text" {
+		t.Fatal("unexpected: mismatch plain text")
+	}
+}
+
+func TestReader_GenerateKey_TamperedHeader(t *testing.T) {
+	keygen, err := NewArgon2WithParams(1, 4*1024, 2)
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	tb := newTBuf(4 * 1024)
+	wc := NewPrivacyWriteCloserWithKeyGen(tb, DefaultCipherMethod, keygen)
+	if err = wc.NewSalt(); err != nil {
+		t.Fatal("unexpected: NewSalt failed", err)
+	}
+	encryptForTest(t, wc, "some passphrase", []byte("some plain text"))
+
+	tb.buf[len(wc.salt)+fieldsLenBytes] ^= 0x01
+
+	r := NewPrivacyReaderWithKeyGen(bytes.NewReader(tb.buf), keygen)
+	if err = r.ReadMagic(); err != nil {
+		t.Fatal("unexpected: ReadMagic failed", err)
+	}
+
+	if err = r.GenerateKey("some passphrase"); err != ErrWrongPassphrase {
+		t.Fatal("unexpected: it should return ErrWrongPassphrase, got", err)
+	}
+}
+
+func TestReader_LegacyHeader(t *testing.T) {
+	keygen, err := NewArgon2WithParams(1, 4*1024, 2)
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	tb := newTBuf(4 * 1024)
+	wc := NewPrivacyWriteCloserWithKeyGen(tb, DefaultCipherMethod, keygen)
+	salt := make([]byte, 16)
+	salt[0] = byte(DefaultCipherMethod)
+	if err = wc.SetSalt(salt); err != nil {
+		t.Fatal("unexpected: SetSalt failed", err)
+	}
+	encryptForTest(t, wc, "some passphrase", []byte("some plain text"))
+
+	r := NewPrivacyReaderWithKeyGen(bytes.NewReader(tb.buf), keygen)
+	if err = r.ReadMagic(); err != nil {
+		t.Fatal("unexpected: ReadMagic failed", err)
+	}
+
+	if err = r.GenerateKey("some passphrase"); err != nil {
+		t.Fatal("unexpected: GenerateKey failed", err)
+	}
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal("unexpected: Read failed", err)
+	}
+
+	if string(b) != "some plain text" {
+		t.Fatal("unexpected: mismatch plain text")
+	}
+}
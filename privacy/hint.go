@@ -0,0 +1,98 @@
+package privacy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// A hint is an unauthenticated, human-readable copy of the KeyGen parameters placed in front of the encrypted
+// stream. The layout is a 0xFF marker, a 2-byte little endian length, and the JSON encoding of the KeyGen. The JSON is
+// zero padded to at least hintMinLen bytes, so a hint is never shorter than the magic bytes.
+const (
+	hintMarker    byte = 0xFF
+	hintHeaderLen int  = 3
+	hintMinLen    int  = 13
+)
+
+var (
+	ErrNotHint = errors.New("not a hint")
+)
+
+func WriteHint(k KeyGen, w io.Writer) (err error) {
+	var (
+		b     []byte
+		bb    *bytes.Buffer
+		total int
+		n     int
+	)
+
+	if b, err = k.MarshalJSON(); err != nil {
+		return
+	}
+
+	wb := make([]byte, hintHeaderLen)
+	wb[0] = hintMarker
+	binary.LittleEndian.PutUint16(wb[1:], uint16(max(len(b), hintMinLen)))
+	bb = bytes.NewBuffer(make([]byte, 0))
+	bb.Write(wb)
+	bb.Write(b)
+
+	if len(b) < hintMinLen {
+		bb.Write(make([]byte, hintMinLen-len(b)))
+	}
+
+	b = bb.Bytes()
+	for total < len(b) {
+		if n, err = w.Write(b[total:]); err != nil {
+			return
+		}
+		total += n
+	}
+	return
+}
+
+// SkipHint reads the hint at the start of br, if there is one, and returns its JSON content, nil without a hint.
+// Afterwards br is positioned at the magic bytes.
+func SkipHint(br *bufio.Reader) (hint []byte, err error) {
+	b, err := br.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+
+	if b[0] != hintMarker {
+		return nil, nil
+	}
+
+	return ReadHint(br)
+}
+
+// ReadHint reads a whole hint from r and returns its JSON content.
+func ReadHint(r io.Reader) (hint []byte, err error) {
+	var (
+		hLen int
+	)
+
+	tb := make([]byte, hintHeaderLen)
+	if _, err = io.ReadFull(r, tb); err != nil {
+		return
+	}
+
+	if tb[0] != hintMarker {
+		return nil, ErrNotHint
+	}
+
+	hLen = int(binary.LittleEndian.Uint16(tb[1:]))
+	hint = make([]byte, hLen)
+	if _, err = io.ReadFull(r, hint); err != nil {
+		return nil, err
+	}
+
+	if hLen == hintMinLen {
+		hint = bytes.TrimRight(hint, "\x00")
+	}
+
+	return
+}
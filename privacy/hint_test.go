@@ -0,0 +1,80 @@
+package privacy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestWriteReadHint(t *testing.T) {
+	var (
+		buf bytes.Buffer
+		m   map[string]any
+	)
+
+	keygen, err := NewArgon2WithParams(2, 8*1024, 2)
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	if err = WriteHint(keygen, &buf); err != nil {
+		t.Fatal("unexpected: WriteHint failed", err)
+	}
+
+	if buf.Bytes()[0] != hintMarker {
+		t.Fatal("unexpected: missing hint marker")
+	}
+
+	hint, err := ReadHint(&buf)
+	if err != nil {
+		t.Fatal("unexpected: ReadHint failed", err)
+	}
+
+	if err = json.Unmarshal(hint, &m); err != nil {
+		t.Fatal("unexpected: hint is not JSON", err)
+	}
+
+	if m["name"] != argon2KeyGenName || m["time"] != float64(2) {
+		t.Fatal("unexpected: hint content", string(hint))
+	}
+
+	if buf.Len() != 0 {
+		t.Fatal("unexpected: ReadHint left", buf.Len(), "bytes")
+	}
+}
+
+func TestReadHint_NotHint(t *testing.T) {
+	if _, err := ReadHint(bytes.NewReader([]byte{0x81, 0x00, 0x00})); err != ErrNotHint {
+		t.Fatal("unexpected: it should return ErrNotHint, got", err)
+	}
+}
+
+func TestSkipHint(t *testing.T) {
+	keygen, err := NewArgon2WithParams(1, 4*1024, 2)
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	for _, withHint := range []bool{false, true} {
+		opts := []Option{WithPassphrase("some passphrase"), WithKeyGen(keygen)}
+		if withHint {
+			opts = append(opts, WithHint())
+		}
+		enc, err := EncryptBytes([]byte("some plain text"), opts...)
+		if err != nil {
+			t.Fatal("test preparation failure:", err)
+		}
+
+		br := bufio.NewReader(bytes.NewReader(enc))
+		hint, err := SkipHint(br)
+		if err != nil || (hint != nil) != withHint {
+			t.Fatal("unexpected: SkipHint", string(hint), err)
+		}
+
+		r := NewPrivacyReaderWithKeyGen(br, keygen)
+		if err = r.ReadMagic(); err != nil {
+			t.Fatal("unexpected: ReadMagic after SkipHint failed", withHint, err)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package privacy
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"golang.org/x/crypto/hkdf"
+	"io"
+)
+
+type hkdfParams struct {
+	Name string
+}
+
+const (
+	hkdfKeyGenName = "hkdf"
+	hkdfInfo       = "simple-privacy-tool hkdf key"
+)
+
+// NewHKDF returns a KeyGen that derives the key with HKDF-SHA256 instead of a slow password hash. It is meant for
+// high-entropy secrets, e.g. a random master key, where Argon2 would only add cost. Never use it for passphrases.
+func NewHKDF() KeyGen {
+	return hkdfParams{
+		Name: hkdfKeyGenName,
+	}
+}
+
+func (h hkdfParams) GenerateKey(password, salt []byte) []byte {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, password, salt, []byte(hkdfInfo)), key); err != nil {
+		panic(err) // 32 bytes are far below the HKDF-SHA256 output limit
+	}
+
+	return key
+}
+
+func (h hkdfParams) MarshalJSON() ([]byte, error) {
+	m := map[string]any{
+		"name": h.Name,
+	}
+	return json.Marshal(&m)
+}
+
+// ParseKeyGen rebuilds a KeyGen from its JSON encoding, e.g. a hint.
+func ParseKeyGen(b []byte) (k KeyGen, err error) {
+	var (
+		m struct {
+			Name    string `json:"name"`
+			Time    uint32 `json:"time"`
+			Memory  uint32 `json:"memory"`
+			Threads uint8  `json:"threads"`
+		}
+	)
+
+	if err = json.Unmarshal(b, &m); err != nil {
+		return
+	}
+
+	switch m.Name {
+	case argon2KeyGenName:
+		return NewArgon2WithParams(m.Time, m.Memory, m.Threads)
+	case hkdfKeyGenName:
+		return NewHKDF(), nil
+	default:
+		return nil, ErrInvalidParameter
+	}
+}
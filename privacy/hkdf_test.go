@@ -0,0 +1,55 @@
+package privacy
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestParseKeyGen(t *testing.T) {
+	argon2, err := NewArgon2WithParams(2, 8*1024, 3)
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	for _, k := range []KeyGen{NewArgon2(), argon2, NewHKDF()} {
+		b, err := k.MarshalJSON()
+		if err != nil {
+			t.Fatal("unexpected: MarshalJSON failed", err)
+		}
+
+		got, err := ParseKeyGen(b)
+		if err != nil {
+			t.Fatal("unexpected: ParseKeyGen failed", err)
+		}
+		if !reflect.DeepEqual(got, k) {
+			t.Fatal("unexpected: mismatch KeyGen", got, k)
+		}
+	}
+
+	for _, b := range []string{`{"name":"unknown"}`, `{"name":"argon2","time":0}`,
+		`{"name":"argon2","time":1,"memory":4294967295,"threads":1}`, `not json`} {
+		if _, err = ParseKeyGen([]byte(b)); err == nil {
+			t.Fatal("unexpected: it should fail for", b)
+		}
+	}
+}
+
+func TestHKDF(t *testing.T) {
+	k := NewHKDF()
+	a := k.GenerateKey([]byte("master key"), []byte("salt 1"))
+	b := k.GenerateKey([]byte("master key"), []byte("salt 2"))
+	if len(a) != 32 || bytes.Equal(a, b) || !bytes.Equal(a, k.GenerateKey([]byte("master key"), []byte("salt 1"))) {
+		t.Fatal("unexpected: HKDF keys")
+	}
+
+	plain := []byte("some plain text")
+	enc, err := EncryptBytes(plain, WithPassphrase("master key"), WithKeyGen(k))
+	if err != nil {
+		t.Fatal("unexpected: EncryptBytes failed", err)
+	}
+	out, err := DecryptBytes(enc, WithPassphrase("master key"), WithKeyGen(k))
+	if err != nil || !bytes.Equal(out, plain) {
+		t.Fatal("unexpected: round trip failed", err)
+	}
+}
@@ -0,0 +1,72 @@
+package privacy
+
+import (
+	"encoding/binary"
+)
+
+// The KeyGen header field records the parameters of the key derivation, so a file can be decrypted and encrypted
+// again with the same parameters without asking the user for them. The layout for Argon2id is
+//
+//	kind     1 byte, keyGenArgon2
+//	time     4 bytes, little endian
+//	memory   4 bytes, little endian, in KB
+//	threads  1 byte
+//
+// The field is authenticated by the key check value like all the header fields, but it is read before the key is
+// derived, so RecordedKeyGen ignores parameters NewArgon2WithParams rejects, e.g. a forged memory of terabytes. The
+// HKDF KeyGen has no parameters, its field is only the kind byte keyGenHKDF.
+const (
+	keyGenArgon2    byte = 0x01
+	keyGenHKDF      byte = 0x02
+	keyGenArgon2Len int  = 10
+)
+
+// RecordKeyGen records the parameters of the KeyGen in the header, see Reader.RecordedKeyGen. KeyGens from outside
+// this package aren't recorded. It requires an extended header and has to be called before the first Write.
+func (wc *WriteCloser) RecordKeyGen() error {
+	var (
+		value []byte
+	)
+
+	switch k := wc.keygen.(type) {
+	case argon2Params:
+		value = []byte{keyGenArgon2}
+		value = binary.LittleEndian.AppendUint32(value, k.Time)
+		value = binary.LittleEndian.AppendUint32(value, k.Memory)
+		value = append(value, k.Threads)
+	case hkdfParams:
+		value = []byte{keyGenHKDF}
+	default:
+		return nil
+	}
+
+	if !wc.isExtended() {
+		return ErrInvalidHeaderFields
+	}
+
+	return wc.setField(fieldKeyGen, value)
+}
+
+// RecordedKeyGen returns the KeyGen recorded in the header, if any. It is only meaningful after ReadMagic.
+func (r *Reader) RecordedKeyGen() (KeyGen, bool) {
+	b, ok := r.field(fieldKeyGen)
+	if ok && len(b) == 1 && b[0] == keyGenHKDF {
+		return NewHKDF(), true
+	}
+
+	if !ok || len(b) != keyGenArgon2Len || b[0] != keyGenArgon2 {
+		return nil, false
+	}
+
+	k, err := NewArgon2WithParams(binary.LittleEndian.Uint32(b[1:]), binary.LittleEndian.Uint32(b[5:]), b[9])
+	if err != nil {
+		return nil, false
+	}
+
+	return k, true
+}
+
+// SetKeyGen replaces the KeyGen, e.g. with the one from RecordedKeyGen. It has to be called before GenerateKey.
+func (p *Privacy) SetKeyGen(k KeyGen) {
+	p.keygen = k
+}
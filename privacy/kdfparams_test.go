@@ -0,0 +1,97 @@
+package privacy
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRecordedKeyGen(t *testing.T) {
+	keygen, err := NewArgon2WithParams(2, 4*1024, 3)
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	enc, err := EncryptBytes([]byte("some content"), WithKeyGen(keygen), WithPassphrase("some passphrase"),
+		WithCipherMethod(AES256GCMSimple), WithMaskedLength(), WithHint())
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	s, format, err := Detect(bytes.NewReader(enc))
+	if err != nil {
+		t.Fatal("unexpected: Detect failed", err)
+	}
+
+	r := NewPrivacyReaderWithKeyGen(s, NewArgon2())
+	if err = r.ReadMagic(); err != nil {
+		t.Fatal("unexpected: ReadMagic failed", err)
+	}
+
+	recorded, ok := r.RecordedKeyGen()
+	if !ok || recorded != keygen {
+		t.Fatal("unexpected: RecordedKeyGen returned", recorded, ok)
+	}
+	if r.CipherMethod() != AES256GCMSimple || !r.MaskedLength() {
+		t.Fatal("unexpected: header", r.CipherMethod(), r.MaskedLength())
+	}
+
+	fromHint, err := ParseKeyGen(format.Hint)
+	if err != nil || fromHint != keygen {
+		t.Fatal("unexpected: ParseKeyGen returned", fromHint, err)
+	}
+
+	if err = r.GenerateKey("some passphrase"); err == nil {
+		t.Fatal("unexpected: the default KeyGen shouldn't derive the same key")
+	}
+
+	r.SetKeyGen(recorded)
+	if err = r.GenerateKey("some passphrase"); err != nil {
+		t.Fatal("unexpected: GenerateKey with the recorded KeyGen failed", err)
+	}
+}
+
+func TestRecordedHKDF(t *testing.T) {
+	enc, err := EncryptBytes([]byte("some content"), WithKeyGen(NewHKDF()), WithPassphrase("some key"))
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	r := NewPrivacyReaderWithKeyGen(bytes.NewReader(enc), NewArgon2())
+	if err = r.ReadMagic(); err != nil {
+		t.Fatal("unexpected: ReadMagic failed", err)
+	}
+
+	if k, ok := r.RecordedKeyGen(); !ok || k != NewHKDF() {
+		t.Fatal("unexpected: RecordedKeyGen returned", k, ok)
+	}
+}
+
+func TestRecordedKeyGenBounds(t *testing.T) {
+	keygen, err := NewArgon2WithParams(2, 4*1024, 3)
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	enc, err := EncryptBytes([]byte("some content"), WithKeyGen(keygen), WithPassphrase("some passphrase"))
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	field := []byte{keyGenArgon2, 2, 0, 0, 0, 0, 0x10, 0, 0, 3}
+	for _, forged := range [][]byte{
+		{keyGenArgon2, 2, 0, 0, 0, 0xFF, 0xFF, 0xFF, 0xFF, 3},
+		{keyGenArgon2, 0xFF, 0xFF, 0xFF, 0xFF, 0, 0x10, 0, 0, 3},
+	} {
+		if bytes.Count(enc, field) != 1 {
+			t.Fatal("test preparation failure: the KeyGen field isn't found")
+		}
+
+		r := NewPrivacyReaderWithKeyGen(bytes.NewReader(bytes.Replace(enc, field, forged, 1)), NewArgon2())
+		if err = r.ReadMagic(); err != nil {
+			t.Fatal("unexpected: ReadMagic failed", err)
+		}
+		if k, ok := r.RecordedKeyGen(); ok {
+			t.Fatal("unexpected: RecordedKeyGen should reject", k)
+		}
+	}
+}
@@ -0,0 +1,313 @@
+package privacy
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"golang.org/x/crypto/hkdf"
+	"io"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// Segments of a stream with an extended header use the two highest bits of the length prefix as flags. The final
+// flag marks the last segment, so truncation is detected. The padded flag marks a segment whose plain text ends
+// with padding, followed by a 4-byte little endian trailer holding the padding length. The associated data of every
+// segment is the length prefix followed by the segment counter, so segments can't be reordered either.
+const (
+	segmentFinal  uint32 = 1 << 31
+	segmentPadded uint32 = 1 << 30
+	segmentFlags         = segmentFinal | segmentPadded
+
+	segmentCounterLen int = 8
+	paddingTrailerLen int = 4
+
+	lengthMaskInfo = "simple-privacy-tool length mask"
+)
+
+type PaddingScheme byte
+
+const (
+	NoPadding    PaddingScheme = 0x00
+	PadmePadding PaddingScheme = 0x01
+	BlockPadding PaddingScheme = 0x02
+	FixedPadding PaddingScheme = 0x03
+)
+
+// Padding describes how the plain text length is hidden. Size is the block size for BlockPadding and the total
+// padded size for FixedPadding. The padded size accounts for the 4-byte padding trailer.
+type Padding struct {
+	Scheme PaddingScheme
+	Size   uint64
+}
+
+var (
+	ErrInvalidPadding  = errors.New("invalid padding")
+	ErrPaddingTooSmall = errors.New("plain text is larger than the fixed padding size")
+)
+
+// ParsePadding parses the padding notation used by the command line: none, padme, block:SIZE, or fixed:SIZE.
+func ParsePadding(s string) (p Padding, err error) {
+	scheme, size, hasSize := strings.Cut(s, ":")
+	switch scheme {
+	case "", "none":
+		p.Scheme = NoPadding
+	case "padme":
+		p.Scheme = PadmePadding
+	case "block":
+		p.Scheme = BlockPadding
+	case "fixed":
+		p.Scheme = FixedPadding
+	default:
+		return p, ErrInvalidPadding
+	}
+
+	if hasSize != (p.Scheme == BlockPadding || p.Scheme == FixedPadding) {
+		return p, ErrInvalidPadding
+	}
+
+	if hasSize {
+		if p.Size, err = ParseSize(size); err != nil {
+			return
+		}
+	}
+
+	return p, p.validate()
+}
+
+// ParseSize parses a size with an optional unit suffix. K, M, G, KiB, MiB, and GiB are powers of 1024, while KB,
+// MB, and GB are powers of 1000.
+func ParseSize(s string) (uint64, error) {
+	var (
+		multiplier uint64 = 1
+	)
+
+	units := []struct {
+		suffix     string
+		multiplier uint64
+	}{
+		{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30},
+		{"KB", 1000}, {"MB", 1000 * 1000}, {"GB", 1000 * 1000 * 1000},
+		{"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30},
+	}
+	for _, u := range units {
+		if strings.HasSuffix(s, u.suffix) {
+			s = strings.TrimSuffix(s, u.suffix)
+			multiplier = u.multiplier
+			break
+		}
+	}
+
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	if n > (1<<64-1)/multiplier {
+		return 0, strconv.ErrRange
+	}
+
+	return n * multiplier, nil
+}
+
+func (p Padding) validate() error {
+	switch p.Scheme {
+	case NoPadding, PadmePadding:
+	case BlockPadding, FixedPadding:
+		if p.Size < uint64(paddingTrailerLen) {
+			return ErrInvalidPadding
+		}
+	default:
+		return ErrInvalidPadding
+	}
+
+	return nil
+}
+
+func (p Padding) size(n uint64) (uint64, error) {
+	switch p.Scheme {
+	case NoPadding:
+		return n, nil
+	case PadmePadding:
+		if n < 2 {
+			return n, nil
+		}
+		e := bits.Len64(n) - 1
+		lastBits := e - bits.Len64(uint64(e))
+		mask := uint64(1)<<lastBits - 1
+		return (n + mask) &^ mask, nil
+	case BlockPadding:
+		return (n + p.Size - 1) / p.Size * p.Size, nil
+	case FixedPadding:
+		if n > p.Size {
+			return 0, ErrPaddingTooSmall
+		}
+		return p.Size, nil
+	default:
+		return 0, ErrInvalidPadding
+	}
+}
+
+// SetPadding pads the plain text on Close according to p, so the size of the output doesn't reveal the exact
+// plain text length. It requires an extended header and has to be called before the first Write.
+func (wc *WriteCloser) SetPadding(p Padding) (err error) {
+	if err = p.validate(); err != nil {
+		return
+	}
+
+	if !wc.isExtended() {
+		return ErrInvalidHeaderFields
+	}
+
+	if p.Scheme != NoPadding {
+		value := binary.LittleEndian.AppendUint64([]byte{byte(p.Scheme)}, p.Size)
+		if err = wc.setField(fieldPadding, value); err != nil {
+			return
+		}
+	}
+	wc.padding = p
+
+	return nil
+}
+
+// MaskSegmentLength encrypts the segment length prefixes, hiding the segment sizes and flags from observers.
+// It requires an extended header and has to be called before the first Write.
+func (wc *WriteCloser) MaskSegmentLength() error {
+	if !wc.isExtended() {
+		return ErrInvalidHeaderFields
+	}
+
+	return wc.setField(fieldLengthMask, nil)
+}
+
+// MaskedLength tells whether the header marks the segment length prefixes as encrypted. It is only meaningful after
+// ReadMagic.
+func (r *Reader) MaskedLength() bool {
+	_, ok := r.field(fieldLengthMask)
+	return ok
+}
+
+// Padding returns the padding recorded in the header. It is only meaningful after ReadMagic.
+func (r *Reader) Padding() (p Padding) {
+	if b, ok := r.field(fieldPadding); ok && len(b) == 9 {
+		p.Scheme = PaddingScheme(b[0])
+		p.Size = binary.LittleEndian.Uint64(b[1:])
+	}
+
+	return
+}
+
+func (p *Privacy) deriveMaskKey(key []byte) (err error) {
+	p.maskKey = make([]byte, sha256.Size)
+	_, err = io.ReadFull(hkdf.New(sha256.New, key, p.salt, []byte(lengthMaskInfo)), p.maskKey)
+	return
+}
+
+func (p *Privacy) additionalData(prefix []byte) []byte {
+	if !p.isExtended() {
+		return prefix
+	}
+
+	ad := append(p.ad[:0], prefix...)
+	return binary.LittleEndian.AppendUint64(ad, p.counter)
+}
+
+func (p *Privacy) lengthMask() []byte {
+	if _, ok := p.field(fieldLengthMask); !ok || !p.isExtended() {
+		return nil
+	}
+
+	mac := hmac.New(sha256.New, p.maskKey)
+	mac.Write(binary.LittleEndian.AppendUint64(nil, p.counter))
+	return mac.Sum(nil)
+}
+
+func (p *Privacy) maskLength(prefix []byte) []byte {
+	mask := p.lengthMask()
+	if mask == nil {
+		return prefix
+	}
+
+	masked := make([]byte, len(prefix))
+	for i := range prefix {
+		masked[i] = prefix[i] ^ mask[i]
+	}
+
+	return masked
+}
+
+func (p *Privacy) unmaskLength(prefix []byte) {
+	if mask := p.lengthMask(); mask != nil {
+		for i := range prefix {
+			prefix[i] ^= mask[i]
+		}
+	}
+}
+
+func (wc *WriteCloser) writePadding() (err error) {
+	var (
+		target    uint64
+		remaining uint64
+		free      int
+		fill      int
+	)
+
+	if int(wc.segmentSize) < 2*paddingTrailerLen {
+		return ErrInvalidPadding
+	}
+
+	if target, err = wc.padding.size(wc.written + uint64(paddingTrailerLen)); err != nil {
+		return
+	}
+
+	remaining = target - wc.written
+	for {
+		free = int(wc.segmentSize) - len(wc.bufSlice)
+		if remaining <= uint64(free) {
+			return wc.writePaddedSegment(int(remaining), segmentFinal)
+		}
+
+		fill = free
+		if remaining-uint64(fill) < uint64(paddingTrailerLen) {
+			fill = int(remaining) - paddingTrailerLen
+		}
+
+		if fill < paddingTrailerLen {
+			_, err = wc.writeSegment(0)
+		} else {
+			remaining -= uint64(fill)
+			err = wc.writePaddedSegment(fill, 0)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (wc *WriteCloser) writePaddedSegment(n int, flags uint32) (err error) {
+	wc.ensureBuf(len(wc.bufSlice) + n)
+	l := len(wc.bufSlice)
+	segment := wc.bufSlice[:l+n]
+	clear(segment[l : l+n-paddingTrailerLen])
+	binary.LittleEndian.PutUint32(segment[l+n-paddingTrailerLen:], uint32(n-paddingTrailerLen))
+	wc.bufSlice = segment
+
+	_, err = wc.writeSegment(flags | segmentPadded)
+	return
+}
+
+func stripPadding(plaintext []byte) ([]byte, error) {
+	if len(plaintext) < paddingTrailerLen {
+		return nil, ErrInvalidPadding
+	}
+
+	l := len(plaintext) - paddingTrailerLen
+	padLen := binary.LittleEndian.Uint32(plaintext[l:])
+	if uint64(padLen) > uint64(l) {
+		return nil, ErrInvalidPadding
+	}
+
+	return plaintext[:l-int(padLen)], nil
+}
@@ -0,0 +1,194 @@
+package privacy
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestParsePadding(t *testing.T) {
+	tests := []struct {
+		name    string
+		arg     string
+		want    Padding
+		wantErr bool
+	}{
+		{name: "empty", arg: "", want: Padding{Scheme: NoPadding}},
+		{name: "none", arg: "none", want: Padding{Scheme: NoPadding}},
+		{name: "padme", arg: "padme", want: Padding{Scheme: PadmePadding}},
+		{name: "block MiB", arg: "block:1MiB", want: Padding{Scheme: BlockPadding, Size: 1 << 20}},
+		{name: "fixed bytes", arg: "fixed:4096", want: Padding{Scheme: FixedPadding, Size: 4096}},
+		{name: "fixed MB", arg: "fixed:2MB", want: Padding{Scheme: FixedPadding, Size: 2 * 1000 * 1000}},
+		{name: "negative: unknown scheme", arg: "random", wantErr: true},
+		{name: "negative: block without size", arg: "block", wantErr: true},
+		{name: "negative: padme with size", arg: "padme:1K", wantErr: true},
+		{name: "negative: invalid size", arg: "fixed:1XB", wantErr: true},
+		{name: "negative: zero size", arg: "block:0", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePadding(tt.arg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePadding() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ParsePadding() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPadding_size(t *testing.T) {
+	tests := []struct {
+		name    string
+		padding Padding
+		arg     uint64
+		want    uint64
+		wantErr bool
+	}{
+		{name: "padme small", padding: Padding{Scheme: PadmePadding}, arg: 7, want: 7},
+		{name: "padme 1000", padding: Padding{Scheme: PadmePadding}, arg: 1000, want: 1024},
+		{name: "padme 1025", padding: Padding{Scheme: PadmePadding}, arg: 1025, want: 1088},
+		{name: "block", padding: Padding{Scheme: BlockPadding, Size: 100}, arg: 101, want: 200},
+		{name: "block exact", padding: Padding{Scheme: BlockPadding, Size: 100}, arg: 200, want: 200},
+		{name: "fixed", padding: Padding{Scheme: FixedPadding, Size: 100}, arg: 10, want: 100},
+		{name: "negative: fixed too small", padding: Padding{Scheme: FixedPadding, Size: 100}, arg: 101, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.padding.size(tt.arg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("size() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("size() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func encryptPadded(t *testing.T, keygen KeyGen, padding Padding, mask bool, plain []byte) []byte {
+	t.Helper()
+
+	tb := newTBuf(4*len(plain) + 64*1024)
+	wc := NewPrivacyWriteCloserWithKeyGen(tb, DefaultCipherMethod, keygen)
+	wc.SetSegmentSize(uint32(256))
+	if err := wc.NewSalt(); err != nil {
+		t.Fatal("unexpected: NewSalt failed", err)
+	}
+	if err := wc.SetPadding(padding); err != nil {
+		t.Fatal("unexpected: SetPadding failed", err)
+	}
+	if mask {
+		if err := wc.MaskSegmentLength(); err != nil {
+			t.Fatal("unexpected: MaskSegmentLength failed", err)
+		}
+	}
+	encryptForTest(t, wc, "some passphrase", plain)
+
+	return tb.buf
+}
+
+func decryptForTest(keygen KeyGen, b []byte) ([]byte, error) {
+	r := NewPrivacyReaderWithKeyGen(bytes.NewReader(b), keygen)
+	r.SetSegmentSize(uint32(256))
+	if err := r.ReadMagic(); err != nil {
+		return nil, err
+	}
+	if err := r.GenerateKey("some passphrase"); err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(r)
+}
+
+func TestPaddingRoundTrip(t *testing.T) {
+	keygen, err := NewArgon2WithParams(1, 4*1024, 2)
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	paddings := []Padding{
+		{Scheme: NoPadding},
+		{Scheme: PadmePadding},
+		{Scheme: BlockPadding, Size: 1000},
+		{Scheme: FixedPadding, Size: 4000},
+	}
+	lengths := []int{0, 1, 251, 252, 253, 256, 257, 999, 1500, 3000}
+
+	for _, padding := range paddings {
+		for _, mask := range []bool{false, true} {
+			for _, l := range lengths {
+				plain := bytes.Repeat([]byte{0xA5}, l)
+				out, err := decryptForTest(keygen, encryptPadded(t, keygen, padding, mask, plain))
+				if err != nil {
+					t.Fatalf("unexpected: decrypt failed, padding %v, mask %v, length %d: %v", padding, mask, l, err)
+				}
+				if !bytes.Equal(out, plain) {
+					t.Fatalf("unexpected: mismatch plain text, padding %v, mask %v, length %d", padding, mask, l)
+				}
+			}
+		}
+	}
+}
+
+func TestPaddingHidesLength(t *testing.T) {
+	keygen, err := NewArgon2WithParams(1, 4*1024, 2)
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	padding := Padding{Scheme: BlockPadding, Size: 1000}
+	a := encryptPadded(t, keygen, padding, true, make([]byte, 10))
+	b := encryptPadded(t, keygen, padding, true, make([]byte, 900))
+	if len(a) != len(b) {
+		t.Fatalf("unexpected: output sizes differ, %d and %d", len(a), len(b))
+	}
+
+	padding = Padding{Scheme: FixedPadding, Size: 100}
+	tb := newTBuf(64 * 1024)
+	wc := NewPrivacyWriteCloserWithKeyGen(tb, DefaultCipherMethod, keygen)
+	if err = wc.NewSalt(); err != nil {
+		t.Fatal("unexpected: NewSalt failed", err)
+	}
+	if err = wc.SetPadding(padding); err != nil {
+		t.Fatal("unexpected: SetPadding failed", err)
+	}
+	if err = wc.GenerateKey("some passphrase"); err != nil {
+		t.Fatal("unexpected: GenerateKey failed", err)
+	}
+	if _, err = wc.Write(make([]byte, 100)); err != nil {
+		t.Fatal("unexpected: Write failed", err)
+	}
+	if err = wc.Close(); err != ErrPaddingTooSmall {
+		t.Fatal("unexpected: it should return ErrPaddingTooSmall, got", err)
+	}
+}
+
+func TestReader_Truncated(t *testing.T) {
+	keygen, err := NewArgon2WithParams(1, 4*1024, 2)
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	b := encryptPadded(t, keygen, Padding{Scheme: NoPadding}, false, bytes.Repeat([]byte{0x5A}, 1000))
+	segment := segmentSizeBytesLen + 24 + 256 + 16
+	headerLen := len(b) - 3*segment - (segmentSizeBytesLen + 24 + 1000 - 3*256 + 16)
+
+	if _, err = decryptForTest(keygen, b[:len(b)-(segmentSizeBytesLen+24+1000-3*256+16)]); err != ErrTruncated {
+		t.Fatal("unexpected: it should return ErrTruncated, got", err)
+	}
+
+	swapped := make([]byte, 0, len(b))
+	swapped = append(swapped, b[:headerLen]...)
+	swapped = append(swapped, b[headerLen+segment:headerLen+2*segment]...)
+	swapped = append(swapped, b[headerLen:headerLen+segment]...)
+	swapped = append(swapped, b[headerLen+2*segment:]...)
+	if _, err = decryptForTest(keygen, swapped); err == nil {
+		t.Fatal("unexpected: reordered segments should fail")
+	}
+
+	if _, err = decryptForTest(keygen, append(b, b[headerLen:headerLen+segment]...)); err != ErrTrailingData {
+		t.Fatal("unexpected: it should return ErrTrailingData, got", err)
+	}
+}
@@ -27,6 +27,8 @@ const (
 	AES256GCMSimple CipherMethodType = 0x02
 
 	DefaultCipherMethod = XChaCha20Simple
+
+	MaxSegmentSize = segmentPadded - 1
 )
 
 var (
@@ -37,7 +39,9 @@ var (
 	ErrInvalidReadFlow      = errors.New("func ReadMagic should be called before calling Read")
 	ErrInvalidKeyState      = errors.New("func GenerateKey should be called first")
 	ErrInvalidSegmentLength = errors.New("segment length is too long")
-	segmentLenBytes         = make([]byte, segmentSizeBytesLen)
+	ErrTruncated            = errors.New("encrypted stream is truncated")
+	ErrTrailingData         = errors.New("unexpected data after the final segment")
+	ErrFinished             = errors.New("write after Finish or Close")
 )
 
 type InvalidCipherMethod []byte
@@ -48,26 +52,41 @@ func (i InvalidCipherMethod) Error() string {
 
 type Reader struct {
 	*Privacy
-	reader   io.Reader
-	buf      []byte
-	bufSlice []byte
-	isEOF    bool
+	reader           io.Reader
+	buf              []byte
+	bufSlice         []byte
+	isEOF            bool
+	finalRead        bool
+	expectedKeyCheck []byte
 }
 
 type WriteCloser struct {
 	*Privacy
-	writeCloser  io.WriteCloser
+	writer       io.Writer
+	closer       io.Closer
 	buf          []byte
 	bufSlice     []byte
 	magicWritten bool
+	finished     bool
+	written      uint64
+	padding      Padding
 }
 
 type Privacy struct {
-	salt        []byte
-	segmentSize uint32
-	cmType      CipherMethodType
-	aead        cipher.AEAD
-	keygen      KeyGen
+	salt          []byte
+	segmentSize   uint32
+	cmType        CipherMethodType
+	aead          cipher.AEAD
+	keygen        KeyGen
+	fields        []byte
+	checkKey      []byte
+	maskKey       []byte
+	nonceKey      []byte
+	counter       uint64
+	deterministic bool
+	onSegment     func(n int) error
+	lenBytes      [segmentSizeBytesLen]byte
+	ad            [segmentSizeBytesLen + segmentCounterLen]byte
 }
 
 func newPrivacy(k KeyGen) *Privacy {
@@ -99,11 +118,19 @@ func NewPrivacyWriteCloser(wc io.WriteCloser, cmType CipherMethodType) *WriteClo
 }
 
 func NewPrivacyWriteCloserWithKeyGen(wc io.WriteCloser, cmType CipherMethodType, keygen KeyGen) *WriteCloser {
+	w := NewPrivacyWriter(wc, cmType, keygen)
+	w.closer = wc
+	return w
+}
+
+// NewPrivacyWriter returns a WriteCloser on top of a plain io.Writer, e.g. an http.ResponseWriter, a bytes.Buffer,
+// or a tar entry. Close, like Finish, writes the final segment but never closes w.
+func NewPrivacyWriter(w io.Writer, cmType CipherMethodType, keygen KeyGen) *WriteCloser {
 	privacy := newPrivacy(keygen)
 	privacy.cmType = cmType
 	return &WriteCloser{
 		Privacy:      privacy,
-		writeCloser:  wc,
+		writer:       w,
 		magicWritten: false,
 	}
 }
@@ -122,12 +149,25 @@ func (p *Privacy) SetSalt(salt []byte) error {
 	return nil
 }
 
+// CipherMethod returns the cipher method, for a Reader the one read by ReadMagic.
+func (p *Privacy) CipherMethod() CipherMethodType {
+	return p.cmType
+}
+
 func (p *Privacy) GetSegmentSize() uint32 {
 	return p.segmentSize
 }
 
+// SetSegmentSize sets the segment size, capped at MaxSegmentSize, since the highest bits of the length prefix of an
+// extended header are flags.
 func (p *Privacy) SetSegmentSize(size uint32) {
-	p.segmentSize = size
+	p.segmentSize = min(size, MaxSegmentSize)
+}
+
+// SetSegmentHook registers f to be called after each segment is encrypted or decrypted, with the length of the
+// segment's plain text. An error returned by f aborts the Write or Read in progress.
+func (p *Privacy) SetSegmentHook(f func(n int) error) {
+	p.onSegment = f
 }
 
 func (p *Privacy) NewSalt() error {
@@ -139,7 +179,7 @@ func (p *Privacy) NewSalt() error {
 		return ErrUninitialisedMethod
 	}
 
-	p.salt[0] = byte(p.cmType)
+	p.salt[0] = byte(p.cmType) | headerExtended
 	_, err := rand.Read(p.salt[1:])
 	if err != nil {
 		return err
@@ -180,6 +220,20 @@ func (p *Privacy) GenerateKey(passphrase string) error {
 		return InvalidCipherMethod([]byte{})
 	}
 
+	if p.isExtended() {
+		if err = p.deriveCheckKey(key); err != nil {
+			return err
+		}
+		if err = p.deriveMaskKey(key); err != nil {
+			return err
+		}
+		if p.deterministic {
+			if err = p.deriveNonceKey(key); err != nil {
+				return err
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -195,14 +249,12 @@ func (wc *WriteCloser) Write(b []byte) (n int, err error) {
 		return 0, ErrInvalidKeyState
 	}
 
-	if cap(wc.buf) != int(wc.segmentSize)+wc.aead.NonceSize()+wc.aead.Overhead() {
-		wc.buf = make([]byte, int(wc.segmentSize)+wc.aead.NonceSize()+wc.aead.Overhead())
-		wc.bufSlice = wc.buf[wc.aead.NonceSize():wc.aead.NonceSize()]
+	if wc.finished {
+		return 0, ErrFinished
 	}
 
 	if !wc.magicWritten {
-		n, err = wc.writeUp(wc.salt)
-		if err != nil {
+		if err = wc.writeHeader(); err != nil {
 			return
 		}
 		wc.magicWritten = true
@@ -212,11 +264,12 @@ func (wc *WriteCloser) Write(b []byte) (n int, err error) {
 	copied = 0
 	for copied < len(b) {
 		if len(wc.bufSlice) == int(wc.segmentSize) {
-			n, err = wc.writeSegment()
+			n, err = wc.writeSegment(0)
 			if err != nil {
-				return
+				return copied, err
 			}
 		} else {
+			wc.ensureBuf(len(wc.bufSlice) + len(b[copied:]))
 			lastMarker = len(wc.bufSlice)
 			plaintext = wc.buf[nonceSize : nonceSize+len(wc.bufSlice)]
 			if len(b[copied:]) <= int(wc.segmentSize)-len(wc.bufSlice) {
@@ -226,6 +279,7 @@ func (wc *WriteCloser) Write(b []byte) (n int, err error) {
 				plaintext = plaintext[:int(wc.segmentSize)]
 				copied += copy(plaintext[lastMarker:], b[copied:])
 			}
+			wc.written += uint64(len(plaintext) - lastMarker)
 			wc.bufSlice = plaintext
 		}
 	}
@@ -233,35 +287,80 @@ func (wc *WriteCloser) Write(b []byte) (n int, err error) {
 	return copied, nil
 }
 
-func (wc *WriteCloser) writeSegment() (n int, err error) {
+// ensureBuf makes room for n bytes of plain text in the current segment, never more than the segment size. The
+// buffer grows by doubling, so a small payload only takes a small buffer from the pool.
+func (wc *WriteCloser) ensureBuf(n int) {
+	nonceSize := wc.aead.NonceSize()
+	n = min(n, int(wc.segmentSize))
+	if wc.buf != nil && wc.plainCap() >= n {
+		return
+	}
+
+	buf := getBuf(min(max(n, 2*wc.plainCap()), int(wc.segmentSize)))
+	l := copy(buf[nonceSize:], wc.bufSlice)
+	if wc.buf != nil {
+		putBuf(wc.buf)
+	}
+	wc.buf = buf
+	wc.bufSlice = buf[nonceSize : nonceSize+l]
+}
+
+// plainCap is the number of plain text bytes the current buffer holds, leaving room for the nonce and the tag.
+func (wc *WriteCloser) plainCap() int {
+	if wc.buf == nil {
+		return 0
+	}
+
+	return len(wc.buf) - wc.aead.NonceSize() - wc.aead.Overhead()
+}
+
+func (wc *WriteCloser) releaseBuf() {
+	if wc.buf != nil {
+		putBuf(wc.buf)
+		wc.buf, wc.bufSlice = nil, nil
+	}
+}
+
+func (wc *WriteCloser) writeSegment(flags uint32) (n int, err error) {
 	var (
 		nonce      []byte
 		ciphertext []byte
 		plaintext  []byte
+		ad         []byte
 		written    int
 	)
 
+	wc.ensureBuf(0)
 	written = len(wc.bufSlice)
-	binary.LittleEndian.PutUint32(segmentLenBytes, uint32(written))
-	n, err = wc.writeUp(segmentLenBytes)
+	binary.LittleEndian.PutUint32(wc.lenBytes[:], uint32(written)|flags)
+	ad = wc.additionalData(wc.lenBytes[:])
+	n, err = wc.writeUp(wc.maskLength(wc.lenBytes[:]))
 	if err != nil {
 		return
 	}
 
 	nonce = wc.buf[:wc.aead.NonceSize()]
-	_, err = rand.Read(nonce)
-	if err != nil {
+	plaintext = wc.buf[wc.aead.NonceSize() : wc.aead.NonceSize()+written]
+	if wc.nonceKey != nil {
+		wc.deterministicNonce(nonce, ad, plaintext)
+	} else if _, err = rand.Read(nonce); err != nil {
 		return
 	}
-	plaintext = wc.buf[wc.aead.NonceSize() : wc.aead.NonceSize()+written]
 	ciphertext = plaintext[:0]
 
-	wc.aead.Seal(ciphertext, nonce, plaintext, segmentLenBytes)
+	wc.aead.Seal(ciphertext, nonce, plaintext, ad)
 	n, err = wc.writeUp(wc.buf[:written+wc.aead.NonceSize()+wc.aead.Overhead()])
 	if err != nil {
 		return
 	}
 	wc.bufSlice = wc.buf[wc.aead.NonceSize():wc.aead.NonceSize()]
+	wc.counter++
+
+	if wc.onSegment != nil {
+		if err = wc.onSegment(written); err != nil {
+			return
+		}
+	}
 
 	return written, nil
 }
@@ -272,7 +371,7 @@ func (wc *WriteCloser) writeUp(b []byte) (n int, err error) {
 	)
 
 	for {
-		if n, err = wc.writeCloser.Write(b[total:]); err != nil {
+		if n, err = wc.writer.Write(b[total:]); err != nil {
 			return n + total, err
 		}
 
@@ -285,14 +384,123 @@ func (wc *WriteCloser) writeUp(b []byte) (n int, err error) {
 	return total, nil
 }
 
-func (wc *WriteCloser) Close() (err error) {
-	if len(wc.bufSlice) > 0 {
-		_, err = wc.writeSegment()
+// ReadFrom encrypts everything from r until EOF. The data is read straight into the segment buffer, so io.Copy
+// doesn't need an intermediate buffer. The buffer starts small and grows while r keeps delivering data.
+func (wc *WriteCloser) ReadFrom(r io.Reader) (n int64, err error) {
+	var (
+		nr        int
+		nonceSize int
+	)
+
+	if wc.aead == nil {
+		return 0, ErrInvalidKeyState
+	}
+
+	if wc.finished {
+		return 0, ErrFinished
+	}
+
+	if !wc.magicWritten {
+		if err = wc.writeHeader(); err != nil {
+			return
+		}
+		wc.magicWritten = true
+	}
+
+	nonceSize = wc.aead.NonceSize()
+	for {
+		if len(wc.bufSlice) == int(wc.segmentSize) {
+			if _, err = wc.writeSegment(0); err != nil {
+				return
+			}
+		}
+
+		l := len(wc.bufSlice)
+		wc.ensureBuf(l + minBufSize)
+		nr, err = r.Read(wc.buf[nonceSize+l : nonceSize+min(wc.plainCap(), int(wc.segmentSize))])
+		wc.bufSlice = wc.buf[nonceSize : nonceSize+l+nr]
+		wc.written += uint64(nr)
+		n += int64(nr)
+
 		if err != nil {
+			if err == io.EOF {
+				return n, nil
+			}
+			return
+		}
+	}
+}
+
+// Flush encrypts the buffered plain text as a segment and writes it to the underlying writer, without waiting for
+// the segment to fill up. Every flushed segment adds the segment overhead to the output.
+func (wc *WriteCloser) Flush() (err error) {
+	if wc.aead == nil {
+		return ErrInvalidKeyState
+	}
+
+	if wc.finished {
+		return ErrFinished
+	}
+
+	if len(wc.bufSlice) == 0 {
+		return
+	}
+
+	_, err = wc.writeSegment(0)
+	return
+}
+
+// Finish writes the header, if nothing has been written yet, and the final segment, without closing the
+// underlying writer. Further writes fail with ErrFinished. Calling Finish more than once is a no-op.
+func (wc *WriteCloser) Finish() (err error) {
+	if wc.aead == nil || wc.finished {
+		return
+	}
+
+	if !wc.magicWritten {
+		if err = wc.writeHeader(); err != nil {
 			return
 		}
+		wc.magicWritten = true
+	}
+
+	if err = wc.writeFinalSegment(); err != nil {
+		return
+	}
+	wc.finished = true
+	wc.releaseBuf()
+
+	return
+}
+
+// Close finishes the stream and closes the underlying writer, unless the WriteCloser was created with
+// NewPrivacyWriter.
+func (wc *WriteCloser) Close() (err error) {
+	if err = wc.Finish(); err != nil {
+		return
+	}
+
+	if wc.closer != nil {
+		return wc.closer.Close()
 	}
-	return wc.writeCloser.Close()
+
+	return
+}
+
+func (wc *WriteCloser) writeFinalSegment() (err error) {
+	if !wc.isExtended() {
+		if len(wc.bufSlice) > 0 {
+			_, err = wc.writeSegment(0)
+		}
+		return
+	}
+
+	if wc.padding.Scheme != NoPadding {
+		return wc.writePadding()
+	}
+
+	_, err = wc.writeSegment(segmentFinal)
+	return
 }
 
 func (r *Reader) ReadMagic() (err error) {
@@ -302,7 +510,7 @@ func (r *Reader) ReadMagic() (err error) {
 			return
 		}
 
-		switch CipherMethodType(magic[0]) {
+		switch CipherMethodType(magic[0] &^ headerExtended) {
 		case XChaCha20Simple:
 			r.cmType = XChaCha20Simple
 		case AES256GCMSimple:
@@ -314,6 +522,12 @@ func (r *Reader) ReadMagic() (err error) {
 		if err = r.SetSalt(magic); err != nil {
 			return
 		}
+
+		if r.isExtended() {
+			if err = r.readExtendedHeader(); err != nil {
+				return
+			}
+		}
 	}
 
 	return nil
@@ -340,11 +554,7 @@ func (r *Reader) readUp(b []byte) (n int, err error) {
 
 func (r *Reader) Read(b []byte) (n int, err error) {
 	var (
-		segmentLen uint32
-		nonce      []byte
-		ciphertext []byte
-		plaintext  []byte
-		copied     int
+		copied int
 	)
 
 	if r.cmType == Uninitialised {
@@ -359,47 +569,20 @@ func (r *Reader) Read(b []byte) (n int, err error) {
 		return 0, io.EOF
 	}
 
-	if cap(r.buf) != int(r.segmentSize)+r.aead.Overhead()+r.aead.NonceSize() {
-		r.buf = make([]byte, int(r.segmentSize)+r.aead.Overhead()+r.aead.NonceSize())
-	}
-
 	//log.Printf("Read for %d bytes\n", len(b))
 	copied = 0
 	for copied < len(b) {
 		if len(r.bufSlice) == 0 {
-			n, err = r.readUp(segmentLenBytes)
-			if err != nil {
+			if err = r.readSegment(); err != nil {
 				if err == io.EOF {
+					r.isEOF = true
+					r.releaseBuf()
 					if copied > 0 {
-						r.isEOF = true
 						return copied, nil
-					} else {
-						r.isEOF = true
-						return 0, err
 					}
 				}
-				return
-			}
-
-			segmentLen = binary.LittleEndian.Uint32(segmentLenBytes)
-			if segmentLen > r.segmentSize {
-				return 0, ErrInvalidSegmentLength
-			}
-
-			n, err = r.readUp(r.buf[:int(segmentLen)+r.aead.Overhead()+r.aead.NonceSize()])
-			if err != nil {
-				return
+				return copied, err
 			}
-
-			nonce = r.buf[:r.aead.NonceSize()]
-			ciphertext = r.buf[r.aead.NonceSize() : r.aead.NonceSize()+int(segmentLen)+r.aead.Overhead()]
-			plaintext = ciphertext[:0]
-
-			if _, err = r.aead.Open(plaintext, nonce, ciphertext, segmentLenBytes); err != nil {
-				return copied, fmt.Errorf("decrypt Read: %w", err)
-			}
-			plaintext = plaintext[:int(segmentLen)]
-			r.bufSlice = plaintext
 		} else {
 			if len(b[copied:]) <= len(r.bufSlice) {
 				cp := copy(b[copied:], r.bufSlice)
@@ -407,7 +590,7 @@ func (r *Reader) Read(b []byte) (n int, err error) {
 				copied += cp
 			} else {
 				copied += copy(b[copied:], r.bufSlice)
-				r.bufSlice = r.buf[r.aead.NonceSize():r.aead.NonceSize()]
+				r.bufSlice = nil
 			}
 		}
 	}
@@ -415,3 +598,155 @@ func (r *Reader) Read(b []byte) (n int, err error) {
 	n = copied
 	return
 }
+
+// WriteTo writes the plain text to w segment by segment, as soon as each segment is decrypted. io.Copy uses it, so
+// a stream that is still being written is passed on without waiting for a full buffer.
+func (r *Reader) WriteTo(w io.Writer) (n int64, err error) {
+	var (
+		written int
+	)
+
+	if r.cmType == Uninitialised {
+		return 0, ErrInvalidReadFlow
+	}
+
+	if r.aead == nil {
+		return 0, ErrInvalidKeyState
+	}
+
+	for !r.isEOF {
+		if len(r.bufSlice) == 0 {
+			if err = r.readSegment(); err != nil {
+				if err == io.EOF {
+					r.isEOF = true
+					r.releaseBuf()
+					return n, nil
+				}
+				return
+			}
+			continue
+		}
+
+		written, err = w.Write(r.bufSlice)
+		n += int64(written)
+		r.bufSlice = r.bufSlice[written:]
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func (r *Reader) readSegment() (err error) {
+	var (
+		n          int
+		segmentLen uint32
+		flags      uint32
+		plaintext  []byte
+	)
+
+	// a segment following a final segment starts the next session of an appended stream, anything else after a
+	// final segment is trailing data
+	session := r.finalRead
+	fail := func(err error) error {
+		if session {
+			return ErrTrailingData
+		}
+		return err
+	}
+
+	if n, err = r.readUp(r.lenBytes[:]); err != nil {
+		if err == io.EOF && n > 0 {
+			return fail(io.ErrUnexpectedEOF)
+		}
+		if err == io.EOF && r.isExtended() && !r.finalRead {
+			return ErrTruncated
+		}
+		return
+	}
+
+	if segmentLen, flags, err = r.parsePrefix(r.lenBytes[:]); err != nil {
+		return fail(err)
+	}
+
+	r.ensureBuf(int(segmentLen))
+	if _, err = r.readUp(r.buf[:int(segmentLen)+r.aead.Overhead()+r.aead.NonceSize()]); err != nil {
+		if err == io.EOF {
+			return fail(io.ErrUnexpectedEOF)
+		}
+		return
+	}
+
+	if plaintext, err = r.openSegment(r.buf, r.lenBytes[:], segmentLen, flags); err != nil {
+		return fail(err)
+	}
+	r.counter++
+	r.finalRead = flags&segmentFinal != 0
+	r.bufSlice = plaintext
+
+	if r.onSegment != nil {
+		err = r.onSegment(int(segmentLen))
+	}
+
+	return
+}
+
+// parsePrefix unmasks the length prefix of the segment at the current counter in place, and splits it into the
+// segment length and the flags.
+func (p *Privacy) parsePrefix(prefix []byte) (segmentLen uint32, flags uint32, err error) {
+	p.unmaskLength(prefix)
+	segmentLen = binary.LittleEndian.Uint32(prefix)
+	if p.isExtended() {
+		flags = segmentLen & segmentFlags
+		segmentLen &^= segmentFlags
+	}
+	if segmentLen > p.segmentSize {
+		return 0, 0, ErrInvalidSegmentLength
+	}
+
+	return
+}
+
+// openSegment decrypts the segment at the current counter in place. buf starts with the nonce, followed by the
+// cipher text and the tag; prefix is the unmasked length prefix. The returned plain text has the padding removed.
+func (p *Privacy) openSegment(buf, prefix []byte, segmentLen, flags uint32) (plaintext []byte, err error) {
+	var (
+		nonce      []byte
+		ciphertext []byte
+	)
+
+	nonce = buf[:p.aead.NonceSize()]
+	ciphertext = buf[p.aead.NonceSize() : p.aead.NonceSize()+int(segmentLen)+p.aead.Overhead()]
+
+	if _, err = p.aead.Open(ciphertext[:0], nonce, ciphertext, p.additionalData(prefix)); err != nil {
+		return nil, fmt.Errorf("decrypt Read: %w", err)
+	}
+
+	plaintext = ciphertext[:int(segmentLen)]
+	if flags&segmentPadded != 0 {
+		if plaintext, err = stripPadding(plaintext); err != nil {
+			return nil, err
+		}
+	}
+
+	return
+}
+
+// ensureBuf sizes the buffer for a segment of n plain text bytes. The size comes from the segment length prefix, so
+// a short stream only takes a small buffer from the pool.
+func (r *Reader) ensureBuf(n int) {
+	if len(r.buf) >= n+r.aead.NonceSize()+r.aead.Overhead() {
+		return
+	}
+
+	r.releaseBuf()
+	r.buf = getBuf(n)
+}
+
+func (r *Reader) releaseBuf() {
+	if r.buf != nil {
+		putBuf(r.buf)
+		r.buf, r.bufSlice = nil, nil
+	}
+}
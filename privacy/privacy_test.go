@@ -1,6 +1,7 @@
 package privacy
 
 import (
+	"bytes"
 	"crypto/cipher"
 	"crypto/rand"
 	"crypto/sha256"
@@ -11,6 +12,7 @@ import (
 	"io"
 	mr "math/rand"
 	"testing"
+	"testing/iotest"
 )
 
 type tBuffer struct {
@@ -239,3 +241,131 @@ func TestExample(t *testing.T) {
 		}
 	}
 }
+
+type closeTracker struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (c *closeTracker) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestNewPrivacyWriter(t *testing.T) {
+	var (
+		sink closeTracker
+	)
+
+	keygen, err := NewArgon2WithParams(1, 4*1024, 2)
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	w := NewPrivacyWriter(&sink, DefaultCipherMethod, keygen)
+	if err = w.NewSalt(); err != nil {
+		t.Fatal("unexpected: NewSalt failed", err)
+	}
+	if err = w.GenerateKey("some passphrase"); err != nil {
+		t.Fatal("unexpected: GenerateKey failed", err)
+	}
+	if _, err = w.Write([]byte("some plain text")); err != nil {
+		t.Fatal("unexpected: Write failed", err)
+	}
+
+	if err = w.Finish(); err != nil {
+		t.Fatal("unexpected: Finish failed", err)
+	}
+	finished := sink.Len()
+
+	if _, err = w.Write([]byte("more")); err != ErrFinished {
+		t.Fatal("unexpected: it should return ErrFinished, got", err)
+	}
+	if err = w.Finish(); err != nil {
+		t.Fatal("unexpected: second Finish failed", err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal("unexpected: Close failed", err)
+	}
+	if sink.closed || sink.Len() != finished {
+		t.Fatal("unexpected: the sink is closed or written after Finish")
+	}
+
+	out, err := decryptForTest(keygen, sink.Bytes())
+	if err != nil {
+		t.Fatal("unexpected: decrypt failed", err)
+	}
+	if string(out) != "some plain text" {
+		t.Fatal("unexpected: mismatch plain text")
+	}
+
+	sink.Reset()
+	wc := NewPrivacyWriteCloserWithKeyGen(&sink, DefaultCipherMethod, keygen)
+	if err = wc.NewSalt(); err != nil {
+		t.Fatal("unexpected: NewSalt failed", err)
+	}
+	encryptForTest(t, wc, "some passphrase", []byte("some plain text"))
+	if !sink.closed {
+		t.Fatal("unexpected: Close doesn't close the sink")
+	}
+}
+
+func TestWriteCloser_ReadFrom(t *testing.T) {
+	var (
+		sink bytes.Buffer
+	)
+
+	keygen, err := NewArgon2WithParams(1, 4*1024, 2)
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	plain := make([]byte, 5000)
+	mr.New(mr.NewSource(1)).Read(plain)
+
+	for _, src := range []io.Reader{bytes.NewReader(plain), iotest.HalfReader(bytes.NewReader(plain))} {
+		sink.Reset()
+		w := NewPrivacyWriter(&sink, DefaultCipherMethod, keygen)
+		w.SetSegmentSize(256)
+		if err = w.NewSalt(); err != nil {
+			t.Fatal("unexpected: NewSalt failed", err)
+		}
+		if err = w.GenerateKey("some passphrase"); err != nil {
+			t.Fatal("unexpected: GenerateKey failed", err)
+		}
+
+		if _, err = w.Write(plain[:100]); err != nil {
+			t.Fatal("unexpected: Write failed", err)
+		}
+		n, err := w.ReadFrom(src)
+		if err != nil || n != int64(len(plain)) {
+			t.Fatal("unexpected: ReadFrom failed", n, err)
+		}
+		if err = w.Finish(); err != nil {
+			t.Fatal("unexpected: Finish failed", err)
+		}
+
+		out, err := decryptForTest(keygen, sink.Bytes())
+		if err != nil {
+			t.Fatal("unexpected: decrypt failed", err)
+		}
+		if !bytes.Equal(out, append(append([]byte{}, plain[:100]...), plain...)) {
+			t.Fatal("unexpected: mismatch plain text")
+		}
+	}
+}
+
+func TestSetSegmentSize(t *testing.T) {
+	wc := NewPrivacyWriter(io.Discard, DefaultCipherMethod, NewArgon2())
+	for _, size := range []uint32{segmentPadded, segmentFinal, 1<<32 - 1} {
+		wc.SetSegmentSize(size)
+		if wc.GetSegmentSize() != MaxSegmentSize || wc.GetSegmentSize()&segmentFlags != 0 {
+			t.Fatal("unexpected: segment size", wc.GetSegmentSize(), "overlaps the length prefix flags")
+		}
+	}
+
+	wc.SetSegmentSize(1024)
+	if wc.GetSegmentSize() != 1024 {
+		t.Fatal("unexpected: segment size", wc.GetSegmentSize())
+	}
+}
@@ -0,0 +1,74 @@
+package privacy
+
+import (
+	"context"
+	"io"
+)
+
+// Progress receives updates from Encrypt, Decrypt, and the other high-level helpers. Bytes is the number of bytes
+// read from the source so far, so it can be compared with the source size, and Segments is the number of segments
+// encrypted or decrypted. Update is called after every segment and once more when the operation completes.
+type Progress interface {
+	Update(bytes, segments uint64)
+}
+
+// ProgressFunc adapts a function to the Progress interface.
+type ProgressFunc func(bytes, segments uint64)
+
+func (f ProgressFunc) Update(bytes, segments uint64) {
+	f(bytes, segments)
+}
+
+// WithContext makes the high-level helpers stop with the context's error once ctx is done. The context is checked
+// between segments and before reading from the source.
+func WithContext(ctx context.Context) Option {
+	return func(o *options) {
+		o.ctx = ctx
+	}
+}
+
+func WithProgress(p Progress) Option {
+	return func(o *options) {
+		o.progress = p
+	}
+}
+
+// tracker counts the bytes read from the source and the segments processed, checks the context, and reports to
+// the Progress.
+type tracker struct {
+	ctx      context.Context
+	progress Progress
+	src      io.Reader
+	bytes    uint64
+	segments uint64
+}
+
+func newTracker(o *options, src io.Reader) *tracker {
+	return &tracker{
+		ctx:      o.ctx,
+		progress: o.progress,
+		src:      src,
+	}
+}
+
+func (t *tracker) Read(b []byte) (n int, err error) {
+	if err = t.ctx.Err(); err != nil {
+		return
+	}
+
+	n, err = t.src.Read(b)
+	t.bytes += uint64(n)
+	return
+}
+
+func (t *tracker) segment(int) error {
+	t.segments++
+	t.report()
+	return t.ctx.Err()
+}
+
+func (t *tracker) report() {
+	if t.progress != nil {
+		t.progress.Update(t.bytes, t.segments)
+	}
+}
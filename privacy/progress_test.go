@@ -0,0 +1,86 @@
+package privacy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestProgress(t *testing.T) {
+	var (
+		last     [2]uint64
+		updates  int
+		progress = ProgressFunc(func(bytes, segments uint64) {
+			updates++
+			last = [2]uint64{bytes, segments}
+		})
+	)
+
+	keygen, err := NewArgon2WithParams(1, 4*1024, 2)
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	plain := bytes.Repeat([]byte{0xA5}, 1000)
+	b, err := EncryptBytes(plain, WithPassphrase("some passphrase"), WithKeyGen(keygen), WithSegmentSize(100),
+		WithProgress(progress))
+	if err != nil {
+		t.Fatal("unexpected: EncryptBytes failed", err)
+	}
+	// ten full segments, the empty final segment, and the completion update
+	if last != [2]uint64{1000, 11} || updates != 12 {
+		t.Fatal("unexpected: encrypt progress", last, updates)
+	}
+
+	updates = 0
+	if _, err = DecryptBytes(b, WithPassphrase("some passphrase"), WithKeyGen(keygen), WithSegmentSize(100),
+		WithProgress(progress)); err != nil {
+		t.Fatal("unexpected: DecryptBytes failed", err)
+	}
+	if last != [2]uint64{uint64(len(b)), 11} || updates != 12 {
+		t.Fatal("unexpected: decrypt progress", last, updates)
+	}
+}
+
+func TestContextCancel(t *testing.T) {
+	keygen, err := NewArgon2WithParams(1, 4*1024, 2)
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	plain := bytes.Repeat([]byte{0xA5}, 1000)
+	b, err := EncryptBytes(plain, WithPassphrase("some passphrase"), WithKeyGen(keygen), WithSegmentSize(100))
+	if err != nil {
+		t.Fatal("unexpected: EncryptBytes failed", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = EncryptBytes(plain, WithPassphrase("some passphrase"), WithKeyGen(keygen), WithContext(ctx)); !errors.Is(err, context.Canceled) {
+		t.Fatal("unexpected: it should return context.Canceled, got", err)
+	}
+
+	for _, encrypt := range []bool{true, false} {
+		var segments uint64
+
+		ctx, cancel = context.WithCancel(context.Background())
+		opts := []Option{WithPassphrase("some passphrase"), WithKeyGen(keygen), WithSegmentSize(100), WithContext(ctx),
+			WithProgress(ProgressFunc(func(_, s uint64) {
+				segments = s
+				if s == 3 {
+					cancel()
+				}
+			}))}
+
+		if encrypt {
+			_, err = EncryptBytes(plain, opts...)
+		} else {
+			_, err = DecryptBytes(b, opts...)
+		}
+		if !errors.Is(err, context.Canceled) || segments != 3 {
+			t.Fatal("unexpected: it should stop with context.Canceled after 3 segments, got", err, segments)
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,305 @@
+package privacy
+
+import (
+	"container/list"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sort"
+	"sync"
+)
+
+const (
+	defaultSegmentCacheSize = 4
+)
+
+var (
+	ErrNotRandomAccess = errors.New("compressed streams don't support random access")
+)
+
+type segmentInfo struct {
+	offset    int64
+	length    uint32
+	flags     uint32
+	plainOff  int64
+	plainSize int64
+}
+
+type cachedSegment struct {
+	index     int
+	buf       []byte
+	plaintext []byte
+}
+
+// segmentCache keeps the plain text of the most recently used segments.
+type segmentCache struct {
+	size     int
+	lru      *list.List
+	segments map[int]*list.Element
+}
+
+func newSegmentCache(size int) *segmentCache {
+	return &segmentCache{
+		size:     size,
+		lru:      list.New(),
+		segments: make(map[int]*list.Element),
+	}
+}
+
+func (c *segmentCache) get(index int) (*cachedSegment, bool) {
+	if e, ok := c.segments[index]; ok {
+		c.lru.MoveToFront(e)
+		return e.Value.(*cachedSegment), true
+	}
+
+	return nil, false
+}
+
+func (c *segmentCache) add(s *cachedSegment) {
+	c.segments[s.index] = c.lru.PushFront(s)
+	for c.lru.Len() > max(c.size, 1) {
+		e := c.lru.Back()
+		evicted := c.lru.Remove(e).(*cachedSegment)
+		delete(c.segments, evicted.index)
+		putBuf(evicted.buf)
+	}
+}
+
+// ReaderAt decrypts an encrypted stream stored in an io.ReaderAt, e.g. a file, at arbitrary offsets. GenerateKey
+// indexes the segments by scanning their length prefixes; ReadAt then only decrypts the segments it touches and
+// keeps the plain text of the most recently used ones in a cache. Compressed streams can't be read this way, and
+// neither can Base64 or armored ones. It is safe for concurrent use after GenerateKey.
+type ReaderAt struct {
+	r         *Reader
+	src       io.ReaderAt
+	size      int64
+	start     int64
+	segments  []segmentInfo
+	plainSize int64
+	mu        sync.Mutex
+	cache     *segmentCache
+}
+
+func NewPrivacyReaderAt(src io.ReaderAt, size int64, keygen KeyGen) *ReaderAt {
+	return &ReaderAt{
+		r:     NewPrivacyReaderWithKeyGen(io.NewSectionReader(src, 0, size), keygen),
+		src:   src,
+		size:  size,
+		cache: newSegmentCache(defaultSegmentCacheSize),
+	}
+}
+
+// SetSegmentSize sets the largest segment size accepted, like Reader.SetSegmentSize.
+func (ra *ReaderAt) SetSegmentSize(size uint32) {
+	ra.r.SetSegmentSize(size)
+}
+
+// SetCacheSize sets how many decrypted segments are kept in memory.
+func (ra *ReaderAt) SetCacheSize(n int) {
+	ra.mu.Lock()
+	defer ra.mu.Unlock()
+
+	ra.cache.size = n
+}
+
+// ReadMagic reads the header, skipping a hint if there is one.
+func (ra *ReaderAt) ReadMagic() (err error) {
+	if err = ra.readMagic(); err != nil {
+		return
+	}
+
+	if ra.r.Compression() != NoCompression {
+		return ErrNotRandomAccess
+	}
+
+	return
+}
+
+func (ra *ReaderAt) readMagic() (err error) {
+	var (
+		b     [1]byte
+		magic [len(eccMagic)]byte
+	)
+
+	if _, err = ra.src.ReadAt(b[:], 0); err != nil {
+		return
+	}
+
+	if _, mErr := ra.src.ReadAt(magic[:], 0); mErr == nil && string(magic[:]) == eccMagic {
+		return ErrECCRandomAccess
+	}
+
+	if b[0] == hintMarker {
+		if _, err = ReadHint(ra.r.reader); err != nil {
+			return
+		}
+	}
+
+	return ra.r.ReadMagic()
+}
+
+// GenerateKey derives the key, verifies it against the key check value if the header has one, and indexes the
+// segments.
+func (ra *ReaderAt) GenerateKey(passphrase string) (err error) {
+	if err = ra.r.GenerateKey(passphrase); err != nil {
+		return
+	}
+
+	return ra.index()
+}
+
+// Size returns the size of the plain text. It is only meaningful after GenerateKey.
+func (ra *ReaderAt) Size() int64 {
+	return ra.plainSize
+}
+
+func (ra *ReaderAt) index() (err error) {
+	var (
+		offset     int64
+		prefix     [segmentSizeBytesLen]byte
+		segmentLen uint32
+		flags      uint32
+		cs         *cachedSegment
+		n          int
+	)
+
+	if offset, err = ra.r.reader.(io.Seeker).Seek(0, io.SeekCurrent); err != nil {
+		return
+	}
+	ra.start = offset
+
+	ra.segments = ra.segments[:0]
+	ra.plainSize = 0
+	ra.cache = newSegmentCache(ra.cache.size)
+	overhead := int64(ra.r.aead.NonceSize() + ra.r.aead.Overhead())
+	for offset < ra.size {
+		// a segment following a final segment starts the next session of an appended stream. It is authenticated
+		// right away, anything else after a final segment is trailing data.
+		session := len(ra.segments) > 0 && ra.segments[len(ra.segments)-1].flags&segmentFinal != 0
+		fail := func(err error) error {
+			if session {
+				return ErrTrailingData
+			}
+			return err
+		}
+
+		if n, err = ra.src.ReadAt(prefix[:], offset); n < len(prefix) {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return fail(err)
+		}
+
+		ra.r.counter = uint64(len(ra.segments))
+		if segmentLen, flags, err = ra.r.parsePrefix(prefix[:]); err != nil {
+			return fail(err)
+		}
+
+		if offset+int64(len(prefix))+int64(segmentLen)+overhead > ra.size {
+			return fail(io.ErrUnexpectedEOF)
+		}
+
+		ra.segments = append(ra.segments, segmentInfo{
+			offset:    offset,
+			length:    segmentLen,
+			flags:     flags,
+			plainOff:  ra.plainSize,
+			plainSize: int64(segmentLen),
+		})
+
+		if flags&segmentPadded != 0 || session {
+			// the plain text size of a padded segment is only known after decryption
+			if cs, err = ra.loadSegment(len(ra.segments) - 1); err != nil {
+				return fail(err)
+			}
+			ra.segments[len(ra.segments)-1].plainSize = int64(len(cs.plaintext))
+			ra.cache.add(cs)
+		}
+
+		ra.plainSize += ra.segments[len(ra.segments)-1].plainSize
+		offset += int64(len(prefix)) + int64(segmentLen) + overhead
+	}
+
+	if ra.r.isExtended() && (len(ra.segments) == 0 || ra.segments[len(ra.segments)-1].flags&segmentFinal == 0) {
+		return ErrTruncated
+	}
+
+	return nil
+}
+
+// loadSegment reads and decrypts segment i. The caller has to hold mu, or have exclusive access during indexing.
+func (ra *ReaderAt) loadSegment(i int) (cs *cachedSegment, err error) {
+	var (
+		prefix [segmentSizeBytesLen]byte
+	)
+
+	s := ra.segments[i]
+	cs = &cachedSegment{
+		index: i,
+		buf:   getBuf(int(s.length)),
+	}
+
+	n := ra.r.aead.NonceSize() + int(s.length) + ra.r.aead.Overhead()
+	if _, err = ra.src.ReadAt(cs.buf[:n], s.offset+int64(len(prefix))); err != nil {
+		putBuf(cs.buf)
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	binary.LittleEndian.PutUint32(prefix[:], s.length|s.flags)
+	ra.r.counter = uint64(i)
+	if cs.plaintext, err = ra.r.openSegment(cs.buf, prefix[:], s.length, s.flags); err != nil {
+		putBuf(cs.buf)
+		return nil, err
+	}
+
+	return cs, nil
+}
+
+func (ra *ReaderAt) ReadAt(b []byte, off int64) (n int, err error) {
+	var (
+		cs *cachedSegment
+		ok bool
+	)
+
+	if ra.r.aead == nil {
+		return 0, ErrInvalidKeyState
+	}
+
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+
+	ra.mu.Lock()
+	defer ra.mu.Unlock()
+
+	i := sort.Search(len(ra.segments), func(i int) bool {
+		return ra.segments[i].plainOff+ra.segments[i].plainSize > off
+	})
+	for n < len(b) && i < len(ra.segments) {
+		s := ra.segments[i]
+		if s.plainSize == 0 {
+			i++
+			continue
+		}
+
+		if cs, ok = ra.cache.get(i); !ok {
+			if cs, err = ra.loadSegment(i); err != nil {
+				return
+			}
+			ra.cache.add(cs)
+		}
+
+		c := copy(b[n:], cs.plaintext[off+int64(n)-s.plainOff:])
+		n += c
+		i++
+	}
+
+	if n < len(b) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
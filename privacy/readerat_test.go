@@ -0,0 +1,132 @@
+package privacy
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	mr "math/rand"
+	"sync"
+	"testing"
+)
+
+func TestReaderAt(t *testing.T) {
+	keygen, err := NewArgon2WithParams(1, 4*1024, 2)
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	plain := make([]byte, 2500)
+	mr.New(mr.NewSource(1)).Read(plain)
+
+	tests := []struct {
+		name  string
+		plain []byte
+		opts  []Option
+	}{
+		{name: "default", plain: plain},
+		{name: "empty", plain: []byte{}},
+		{name: "aes and hint", plain: plain, opts: []Option{WithCipherMethod(AES256GCMSimple), WithHint()}},
+		{name: "masked length", plain: plain, opts: []Option{WithMaskedLength()}},
+		{name: "padding", plain: plain, opts: []Option{WithPadding(Padding{Scheme: BlockPadding, Size: 1000})}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := append([]Option{WithPassphrase("some passphrase"), WithKeyGen(keygen), WithSegmentSize(100)}, tt.opts...)
+			b, err := EncryptBytes(tt.plain, opts...)
+			if err != nil {
+				t.Fatal("unexpected: EncryptBytes failed", err)
+			}
+
+			ra := NewPrivacyReaderAt(bytes.NewReader(b), int64(len(b)), keygen)
+			ra.SetSegmentSize(100)
+			ra.SetCacheSize(2)
+			if err = ra.ReadMagic(); err != nil {
+				t.Fatal("unexpected: ReadMagic failed", err)
+			}
+			if err = ra.GenerateKey("some passphrase"); err != nil {
+				t.Fatal("unexpected: GenerateKey failed", err)
+			}
+			if ra.Size() != int64(len(tt.plain)) {
+				t.Fatal("unexpected: Size", ra.Size())
+			}
+
+			out, err := io.ReadAll(io.NewSectionReader(ra, 0, ra.Size()))
+			if err != nil || !bytes.Equal(out, tt.plain) {
+				t.Fatal("unexpected: mismatch plain text", err)
+			}
+
+			var wg sync.WaitGroup
+			for g := 0; g < 8; g++ {
+				wg.Add(1)
+				go func(g int) {
+					defer wg.Done()
+					rnd := mr.New(mr.NewSource(int64(g)))
+					for i := 0; i < 50 && len(tt.plain) > 0; i++ {
+						off := rnd.Intn(len(tt.plain))
+						buf := make([]byte, rnd.Intn(300))
+						n, err := ra.ReadAt(buf, int64(off))
+						if n != min(len(buf), len(tt.plain)-off) || (err != nil && err != io.EOF) {
+							t.Error("unexpected: ReadAt", off, len(buf), n, err)
+							return
+						}
+						if !bytes.Equal(buf[:n], tt.plain[off:off+n]) {
+							t.Error("unexpected: mismatch plain text at", off)
+							return
+						}
+					}
+				}(g)
+			}
+			wg.Wait()
+
+			if _, err = ra.ReadAt(make([]byte, 1), ra.Size()); err != io.EOF {
+				t.Fatal("unexpected: it should return io.EOF, got", err)
+			}
+		})
+	}
+}
+
+func TestReaderAt_Errors(t *testing.T) {
+	keygen, err := NewArgon2WithParams(1, 4*1024, 2)
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	plain := bytes.Repeat([]byte{0xA5}, 1000)
+	open := func(b []byte) error {
+		ra := NewPrivacyReaderAt(bytes.NewReader(b), int64(len(b)), keygen)
+		if err := ra.ReadMagic(); err != nil {
+			return err
+		}
+		return ra.GenerateKey("some passphrase")
+	}
+
+	b, err := EncryptBytes(plain, WithPassphrase("some passphrase"), WithKeyGen(keygen), WithCompression(GzipCompression, DefaultCompressionLevel))
+	if err != nil {
+		t.Fatal("unexpected: EncryptBytes failed", err)
+	}
+	if err = open(b); err != ErrNotRandomAccess {
+		t.Fatal("unexpected: it should return ErrNotRandomAccess, got", err)
+	}
+
+	b, err = EncryptBytes(plain, WithPassphrase("some passphrase"), WithKeyGen(keygen), WithSegmentSize(100))
+	if err != nil {
+		t.Fatal("unexpected: EncryptBytes failed", err)
+	}
+	if err = open(b[:len(b)-44]); err != ErrTruncated {
+		t.Fatal("unexpected: it should return ErrTruncated, got", err)
+	}
+	if err = open(b[:len(b)-10]); err != io.ErrUnexpectedEOF {
+		t.Fatal("unexpected: it should return io.ErrUnexpectedEOF, got", err)
+	}
+	if err = open(append(b, b[len(b)-44:]...)); err != ErrTrailingData {
+		t.Fatal("unexpected: it should return ErrTrailingData, got", err)
+	}
+
+	ra := NewPrivacyReaderAt(bytes.NewReader(b), int64(len(b)), keygen)
+	if err = ra.ReadMagic(); err != nil {
+		t.Fatal("unexpected: ReadMagic failed", err)
+	}
+	if err = ra.GenerateKey("wrong passphrase"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatal("unexpected: it should return ErrWrongPassphrase, got", err)
+	}
+}
@@ -0,0 +1,151 @@
+package privacy
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"os"
+)
+
+const (
+	shredBufferSize = 64 * 1024
+)
+
+var (
+	ErrVerifyFailed   = errors.New("the encrypted file doesn't decrypt to the source file")
+	ErrNotRegularFile = errors.New("not a regular file")
+)
+
+// WithShred makes EncryptFile overwrite and remove srcPath, once dstPath has been written, synced to disk, and
+// verified by decrypting it, see Shred. It is ignored by the other helpers.
+func WithShred() Option {
+	return func(o *options) {
+		o.shred = true
+	}
+}
+
+// Shred overwrites the regular file at path with random data, syncs it to disk, and removes it.
+//
+// Overwriting only helps where a write replaces the data in place. Copy-on-write and log-structured file systems
+// (btrfs, ZFS, APFS), data journaling, snapshots, backups, and the wear leveling of SSDs and flash memory may all
+// keep the old content somewhere else. On those, encrypt the disk instead, or encrypt files before they reach it.
+func Shred(path string) (err error) {
+	var (
+		fi   os.FileInfo
+		file *os.File
+	)
+
+	if fi, err = os.Lstat(path); err != nil {
+		return
+	}
+
+	if !fi.Mode().IsRegular() {
+		return ErrNotRegularFile
+	}
+
+	if file, err = os.OpenFile(path, os.O_WRONLY, 0); err != nil {
+		return
+	}
+	defer func() {
+		if cErr := file.Close(); err == nil {
+			err = cErr
+		}
+		if err == nil {
+			err = os.Remove(path)
+		}
+	}()
+
+	if _, err = io.CopyN(file, rand.Reader, fi.Size()); err != nil {
+		return
+	}
+
+	return file.Sync()
+}
+
+// VerifyFile decrypts encPath and compares the plain text with the content of plainPath. It returns ErrVerifyFailed
+// if they differ.
+func VerifyFile(encPath, plainPath string, opts ...Option) (err error) {
+	var (
+		enc   *os.File
+		plain *os.File
+	)
+
+	if enc, err = os.Open(encPath); err != nil {
+		return
+	}
+	defer func() {
+		_ = enc.Close()
+	}()
+
+	if plain, err = os.Open(plainPath); err != nil {
+		return
+	}
+	defer func() {
+		_ = plain.Close()
+	}()
+
+	c := &compareWriter{r: plain}
+	if err = Decrypt(c, enc, opts...); err != nil {
+		if errors.Is(err, ErrVerifyFailed) {
+			return ErrVerifyFailed
+		}
+		return
+	}
+
+	// the plain file must not be longer than the decrypted content
+	if n, err := plain.Read(make([]byte, 1)); n > 0 || !errors.Is(err, io.EOF) {
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		return ErrVerifyFailed
+	}
+
+	return nil
+}
+
+// compareWriter fails writes which don't match the next bytes of r.
+type compareWriter struct {
+	r   io.Reader
+	buf []byte
+}
+
+func (c *compareWriter) Write(b []byte) (n int, err error) {
+	if c.buf == nil {
+		c.buf = make([]byte, shredBufferSize)
+	}
+
+	for n < len(b) {
+		chunk := b[n:min(len(b), n+len(c.buf))]
+		if _, err = io.ReadFull(c.r, c.buf[:len(chunk)]); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				err = ErrVerifyFailed
+			}
+			return
+		}
+
+		if !bytes.Equal(chunk, c.buf[:len(chunk)]) {
+			return n, ErrVerifyFailed
+		}
+		n += len(chunk)
+	}
+
+	return
+}
+
+func syncFile(path string) (err error) {
+	var (
+		file *os.File
+	)
+
+	if file, err = os.Open(path); err != nil {
+		return
+	}
+	defer func() {
+		if cErr := file.Close(); err == nil {
+			err = cErr
+		}
+	}()
+
+	return file.Sync()
+}
@@ -0,0 +1,121 @@
+package privacy
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEncryptFileShred(t *testing.T) {
+	keygen, err := NewArgon2WithParams(1, 4*1024, 2)
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	dir := t.TempDir()
+	srcPath := filepath.Join(dir, "secret.txt")
+	dstPath := filepath.Join(dir, "secret.spt")
+	plain := bytes.Repeat([]byte("top secret\n"), 10000)
+	if err = os.WriteFile(srcPath, plain, 0600); err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	calls := 0
+	opts := []Option{
+		WithKeyGen(keygen),
+		WithCompression(ZstdCompression, DefaultCompressionLevel),
+		WithPassphraseFunc(func(attempt int) (string, error) {
+			calls++
+			return "some passphrase", nil
+		}),
+	}
+
+	if err = EncryptFile(dstPath, srcPath, append(opts, WithShred())...); err != nil {
+		t.Fatal("unexpected: EncryptFile failed", err)
+	}
+	if calls != 1 {
+		t.Fatal("unexpected: the passphrase should be asked once, asked", calls)
+	}
+	if _, err = os.Stat(srcPath); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("unexpected: the source file should be removed", err)
+	}
+
+	decPath := filepath.Join(dir, "secret.dec")
+	if err = DecryptFile(decPath, dstPath, opts...); err != nil {
+		t.Fatal("unexpected: DecryptFile failed", err)
+	}
+	if b, _ := os.ReadFile(decPath); !bytes.Equal(b, plain) {
+		t.Fatal("unexpected: decrypted content differs")
+	}
+
+	if err = EncryptFile(filepath.Join(dir, "dir.spt"), dir, append(opts, WithShred())...); err != ErrNotRegularFile {
+		t.Fatal("unexpected: it should return ErrNotRegularFile, got", err)
+	}
+}
+
+func TestVerifyFile(t *testing.T) {
+	keygen, err := NewArgon2WithParams(1, 4*1024, 2)
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+	opts := []Option{WithKeyGen(keygen), WithPassphrase("some passphrase")}
+
+	dir := t.TempDir()
+	encPath := filepath.Join(dir, "file.spt")
+	plain := bytes.Repeat([]byte("0123456789"), 1000)
+	if err = os.WriteFile(filepath.Join(dir, "file"), plain, 0600); err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+	if err = EncryptFile(encPath, filepath.Join(dir, "file"), opts...); err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	for _, tc := range []struct {
+		name  string
+		plain []byte
+		err   error
+	}{
+		{"equal", plain, nil},
+		{"changed", append(bytes.Clone(plain[:len(plain)-1]), 'x'), ErrVerifyFailed},
+		{"shorter", plain[:len(plain)-1], ErrVerifyFailed},
+		{"longer", append(bytes.Clone(plain), 'x'), ErrVerifyFailed},
+		{"empty", nil, ErrVerifyFailed},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(dir, tc.name)
+			if err := os.WriteFile(path, tc.plain, 0600); err != nil {
+				t.Fatal("test preparation failure:", err)
+			}
+
+			if err := VerifyFile(encPath, path, opts...); err != tc.err {
+				t.Fatal("unexpected: VerifyFile returned", err)
+			}
+		})
+	}
+}
+
+func TestShred(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file")
+	if err := os.WriteFile(path, []byte("some content"), 0600); err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink(path, link); err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+	if err := Shred(link); err != ErrNotRegularFile {
+		t.Fatal("unexpected: it should return ErrNotRegularFile, got", err)
+	}
+
+	if err := Shred(path); err != nil {
+		t.Fatal("unexpected: Shred failed", err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("unexpected: the file should be removed", err)
+	}
+}
@@ -0,0 +1,59 @@
+package slogcrypt
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+const (
+	DefaultPollInterval = 500 * time.Millisecond
+)
+
+type followReader struct {
+	ctx      context.Context
+	r        io.Reader
+	interval time.Duration
+}
+
+// Follow returns a reader which, instead of returning io.EOF at the end of r, waits for more data to be written to
+// r, like tail -f. It polls r every interval and returns the error of ctx once ctx is done. Read it with a
+// privacy.Reader and copy the plain text with io.Copy, which passes each segment on as soon as it is written.
+// privacy.Detect and privacy.Decrypt don't work on it: they peek ahead and would wait for data that isn't there yet.
+func Follow(ctx context.Context, r io.Reader, interval time.Duration) io.Reader {
+	return &followReader{
+		ctx:      ctx,
+		r:        r,
+		interval: interval,
+	}
+}
+
+func (f *followReader) Read(b []byte) (n int, err error) {
+	var (
+		ticker *time.Ticker
+	)
+
+	for {
+		if err = f.ctx.Err(); err != nil {
+			return
+		}
+
+		if n, err = f.r.Read(b); n > 0 || err != io.EOF {
+			if err == io.EOF {
+				err = nil
+			}
+			return
+		}
+
+		if ticker == nil {
+			ticker = time.NewTicker(f.interval)
+			defer ticker.Stop()
+		}
+
+		select {
+		case <-f.ctx.Done():
+			return 0, f.ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
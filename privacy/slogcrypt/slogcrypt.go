@@ -0,0 +1,225 @@
+// Package slogcrypt writes logs into an encrypted stream. The log records are buffered in the current segment, which
+// is flushed every few records or after a short delay, so a crash only loses the records of the last segment. Log
+// files are reopened with privacy.Appender, each run of the program adding a session.
+//
+// A program that crashed leaves its log file without a final segment, maybe with a torn segment at the end. Reading it
+// returns all flushed records, followed by privacy.ErrTruncated or io.ErrUnexpectedEOF. OpenFile resumes such a file:
+// it drops the torn segment, closes the cut-off session, and appends a new one, see privacy.Appender.Resume.
+package slogcrypt
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"gitea.suyono.dev/suyono/simple-privacy-tool/privacy"
+)
+
+const (
+	DefaultFlushRecords  = 100
+	DefaultFlushInterval = 5 * time.Second
+)
+
+var (
+	ErrCompressed = errors.New("compressed streams can't be used for logs")
+	ErrClosed     = errors.New("log writer is closed")
+)
+
+// Writer is an io.Writer encrypting into a privacy.WriteCloser. Each Write is counted as one record, which is how
+// the handlers of log/slog and the log package write. It is safe for concurrent use.
+type Writer struct {
+	mu       sync.Mutex
+	wc       *privacy.WriteCloser
+	closer   io.Closer
+	records  int
+	pending  int
+	interval time.Duration
+	timer    *time.Timer
+	err      error
+	closed   bool
+}
+
+type Option func(*Writer)
+
+// FlushRecords flushes a segment after every n records. Zero disables it.
+func FlushRecords(n int) Option {
+	return func(w *Writer) {
+		w.records = n
+	}
+}
+
+// FlushInterval flushes a segment at most d after a record is written. Zero disables it.
+func FlushInterval(d time.Duration) Option {
+	return func(w *Writer) {
+		w.interval = d
+	}
+}
+
+// NewWriter writes the records to wc, which has to be ready for writing, i.e. after GenerateKey. Closing the Writer
+// closes wc.
+func NewWriter(wc *privacy.WriteCloser, opts ...Option) *Writer {
+	w := &Writer{
+		wc:       wc,
+		records:  DefaultFlushRecords,
+		interval: DefaultFlushInterval,
+	}
+
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	return w
+}
+
+// OpenFile opens the encrypted log file at path for appending, or creates it. The passphrase and keygen have to
+// match the ones of an existing file. A file left behind by a crash is resumed after its last complete segment.
+func OpenFile(path, passphrase string, keygen privacy.KeyGen, opts ...Option) (w *Writer, err error) {
+	var (
+		f    *os.File
+		info os.FileInfo
+		wc   *privacy.WriteCloser
+	)
+
+	if f, err = os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600); err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = f.Close()
+		}
+	}()
+
+	if info, err = f.Stat(); err != nil {
+		return
+	}
+
+	if info.Size() == 0 {
+		wc = privacy.NewPrivacyWriter(f, privacy.DefaultCipherMethod, keygen)
+		if err = wc.NewSalt(); err != nil {
+			return
+		}
+		if err = wc.GenerateKey(passphrase); err != nil {
+			return
+		}
+	} else {
+		a := privacy.NewPrivacyAppender(f, f, info.Size(), keygen)
+		if err = a.ReadMagic(); err != nil {
+			return
+		}
+		if a.Compression() != privacy.NoCompression {
+			return nil, ErrCompressed
+		}
+		if err = a.Resume(passphrase, f.Truncate); err != nil {
+			return
+		}
+		wc = a.WriteCloser
+	}
+
+	w = NewWriter(wc, opts...)
+	w.closer = f
+
+	return
+}
+
+// NewHandler returns a slog handler writing JSON records to w.
+func NewHandler(w *Writer, opts *slog.HandlerOptions) slog.Handler {
+	return slog.NewJSONHandler(w, opts)
+}
+
+func (w *Writer) Write(b []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return 0, ErrClosed
+	}
+
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	if n, err = w.wc.Write(b); err != nil {
+		w.err = err
+		return
+	}
+
+	w.pending++
+	if w.records > 0 && w.pending >= w.records {
+		err = w.flush()
+	} else if w.interval > 0 && w.timer == nil {
+		w.timer = time.AfterFunc(w.interval, w.flushTimer)
+	}
+
+	return
+}
+
+// Flush writes the buffered records as a segment.
+func (w *Writer) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return ErrClosed
+	}
+
+	return w.flush()
+}
+
+func (w *Writer) flushTimer() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.timer = nil
+	if !w.closed {
+		_ = w.flush()
+	}
+}
+
+// flush requires mu to be held. A failed flush is kept and returned by every later call.
+func (w *Writer) flush() error {
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+
+	if w.err != nil {
+		return w.err
+	}
+
+	w.pending = 0
+	w.err = w.wc.Flush()
+	return w.err
+}
+
+// Close writes the remaining records and the final segment, and closes the file if the Writer was opened with
+// OpenFile.
+func (w *Writer) Close() (err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+
+	err = w.err
+	if cErr := w.wc.Close(); err == nil {
+		err = cErr
+	}
+
+	if w.closer != nil {
+		if cErr := w.closer.Close(); err == nil {
+			err = cErr
+		}
+	}
+
+	return
+}
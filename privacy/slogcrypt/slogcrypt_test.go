@@ -0,0 +1,270 @@
+package slogcrypt
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gitea.suyono.dev/suyono/simple-privacy-tool/privacy"
+)
+
+func testKeyGen(t *testing.T) privacy.KeyGen {
+	keygen, err := privacy.NewArgon2WithParams(1, 4*1024, 2)
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	return keygen
+}
+
+func decryptFile(t *testing.T, path string, keygen privacy.KeyGen) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal("unexpected: ReadFile failed", err)
+	}
+
+	var out bytes.Buffer
+	err = privacy.Decrypt(&out, bytes.NewReader(b), privacy.WithPassphrase("some passphrase"),
+		privacy.WithKeyGen(keygen))
+	return out.String(), err
+}
+
+func TestWriter(t *testing.T) {
+	keygen := testKeyGen(t)
+	path := filepath.Join(t.TempDir(), "app.log.spt")
+
+	w, err := OpenFile(path, "some passphrase", keygen, FlushRecords(2), FlushInterval(0))
+	if err != nil {
+		t.Fatal("unexpected: OpenFile failed", err)
+	}
+	logger := slog.New(NewHandler(w, nil))
+	for i := 0; i < 5; i++ {
+		logger.Info("started", "run", 1, "i", i)
+	}
+
+	// the fifth record is still buffered
+	out, err := decryptFile(t, path, keygen)
+	if !errors.Is(err, privacy.ErrTruncated) || strings.Count(out, "\n") != 4 {
+		t.Fatal("unexpected: decrypting a log being written", out, err)
+	}
+
+	if err = w.Close(); err != nil {
+		t.Fatal("unexpected: Close failed", err)
+	}
+	if _, err = w.Write([]byte("x")); err != ErrClosed {
+		t.Fatal("unexpected: it should return ErrClosed, got", err)
+	}
+
+	if _, err = OpenFile(path, "wrong", keygen); err != privacy.ErrWrongPassphrase {
+		t.Fatal("unexpected: it should return ErrWrongPassphrase, got", err)
+	}
+
+	if w, err = OpenFile(path, "some passphrase", keygen); err != nil {
+		t.Fatal("unexpected: OpenFile of an existing log failed", err)
+	}
+	slog.New(NewHandler(w, nil)).Warn("restarted", "run", 2)
+	if err = w.Close(); err != nil {
+		t.Fatal("unexpected: Close failed", err)
+	}
+
+	if out, err = decryptFile(t, path, keygen); err != nil {
+		t.Fatal("unexpected: decrypt failed", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 6 || !strings.Contains(lines[4], `"i":4`) || !strings.Contains(lines[5], `"msg":"restarted"`) {
+		t.Fatal("unexpected: log content", out)
+	}
+}
+
+func TestFlushInterval(t *testing.T) {
+	keygen := testKeyGen(t)
+	path := filepath.Join(t.TempDir(), "app.log.spt")
+
+	w, err := OpenFile(path, "some passphrase", keygen, FlushRecords(0), FlushInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatal("unexpected: OpenFile failed", err)
+	}
+	defer func() {
+		_ = w.Close()
+	}()
+
+	if _, err = w.Write([]byte("hello\n")); err != nil {
+		t.Fatal("unexpected: Write failed", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if out, _ := decryptFile(t, path, keygen); out == "hello\n" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("unexpected: the record wasn't flushed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+type lineWriter chan string
+
+func (l lineWriter) Write(b []byte) (int, error) {
+	for _, line := range strings.SplitAfter(string(b), "\n") {
+		if line != "" {
+			l <- line
+		}
+	}
+
+	return len(b), nil
+}
+
+func TestFollow(t *testing.T) {
+	keygen := testKeyGen(t)
+	path := filepath.Join(t.TempDir(), "app.log.spt")
+
+	w, err := OpenFile(path, "some passphrase", keygen, FlushRecords(1))
+	if err != nil {
+		t.Fatal("unexpected: OpenFile failed", err)
+	}
+	if _, err = w.Write([]byte("first\n")); err != nil {
+		t.Fatal("unexpected: Write failed", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal("unexpected: Open failed", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	lines := make(lineWriter, 10)
+	done := make(chan error, 1)
+	go func() {
+		r := privacy.NewPrivacyReaderWithKeyGen(Follow(ctx, f, time.Millisecond), keygen)
+		if err := r.ReadMagic(); err != nil {
+			done <- err
+			return
+		}
+		if err := r.GenerateKey("some passphrase"); err != nil {
+			done <- err
+			return
+		}
+		_, err := io.Copy(lines, r)
+		done <- err
+	}()
+
+	expect := func(want string) {
+		t.Helper()
+		select {
+		case line := <-lines:
+			if line != want {
+				t.Fatal("unexpected: line", line)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("unexpected: no line", want)
+		}
+	}
+
+	expect("first\n")
+	if _, err = w.Write([]byte("second\n")); err != nil {
+		t.Fatal("unexpected: Write failed", err)
+	}
+	expect("second\n")
+
+	// a new session after Close is followed as well
+	if err = w.Close(); err != nil {
+		t.Fatal("unexpected: Close failed", err)
+	}
+	if w, err = OpenFile(path, "some passphrase", keygen, FlushRecords(1)); err != nil {
+		t.Fatal("unexpected: OpenFile failed", err)
+	}
+	if _, err = w.Write([]byte("third\n")); err != nil {
+		t.Fatal("unexpected: Write failed", err)
+	}
+	expect("third\n")
+	_ = w.Close()
+
+	cancel()
+	if err = <-done; !errors.Is(err, context.Canceled) {
+		t.Fatal("unexpected: it should return context.Canceled, got", err)
+	}
+}
+
+// crashEnv makes the test binary run as the writer killed by TestCrash.
+const crashEnv = "SLOGCRYPT_CRASH_LOG"
+
+func TestCrash(t *testing.T) {
+	keygen := testKeyGen(t)
+
+	if path := os.Getenv(crashEnv); path != "" {
+		w, err := OpenFile(path, "some passphrase", keygen, FlushRecords(1), FlushInterval(0))
+		if err != nil {
+			t.Fatal("unexpected: OpenFile failed", err)
+		}
+		logger := slog.New(NewHandler(w, nil))
+		for i := 0; ; i++ {
+			logger.Info("running", "i", i, "padding", strings.Repeat("x", i%300))
+			if i == 100 {
+				_, _ = os.Stdout.WriteString("ready\n")
+			}
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "app.log.spt")
+	cmd := exec.Command(os.Args[0], "-test.run=^TestCrash$")
+	cmd.Env = append(os.Environ(), crashEnv+"="+path)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+	if err = cmd.Start(); err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+	if _, err = bufio.NewReader(stdout).ReadString('\n'); err != nil {
+		t.Fatal("unexpected: the writer didn't start", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if err = cmd.Process.Kill(); err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+	_ = cmd.Wait()
+
+	// the last segment may be torn as well
+	if out, err := decryptFile(t, path, keygen); !errors.Is(err, privacy.ErrTruncated) &&
+		!errors.Is(err, io.ErrUnexpectedEOF) || out == "" {
+		t.Fatal("unexpected: the killed writer should leave a truncated log", err)
+	}
+
+	w, err := OpenFile(path, "some passphrase", keygen)
+	if err != nil {
+		t.Fatal("unexpected: OpenFile of a crashed log failed", err)
+	}
+	slog.New(NewHandler(w, nil)).Warn("restarted")
+	if err = w.Close(); err != nil {
+		t.Fatal("unexpected: Close failed", err)
+	}
+
+	out, err := decryptFile(t, path, keygen)
+	if err != nil {
+		t.Fatal("unexpected: decrypting the resumed log failed", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) < 100 || !strings.Contains(lines[len(lines)-1], `"msg":"restarted"`) {
+		t.Fatal("unexpected: log content", len(lines))
+	}
+	for i, line := range lines[:len(lines)-1] {
+		if !strings.Contains(line, fmt.Sprintf(`"i":%d,`, i)) {
+			t.Fatal("unexpected: record", i, line)
+		}
+	}
+}
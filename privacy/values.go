@@ -0,0 +1,138 @@
+package privacy
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"hash"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// A sealed value is a single short plain text encrypted on its own, for documents where only some parts are secret.
+// It is written as
+//
+//	SPT[base64url(nonce || cipher text)]
+//
+// Every value of a document shares the key of a ValueCipher, and has its own random nonce. The additional data
+// passed to Seal and Open binds a value to its place, e.g. its path in the document, so values can't be swapped.
+const (
+	sealedValuePrefix = "SPT["
+	sealedValueSuffix = "]"
+
+	valueMACInfo = "simple-privacy-tool value mac"
+)
+
+var (
+	ErrInvalidSealedValue = errors.New("invalid sealed value")
+)
+
+// ValueCipher seals and opens values with a key derived from a passphrase, a salt, and a KeyGen, like the key of an
+// encrypted stream. The salt and the key check value have to be stored next to the values.
+type ValueCipher struct {
+	p      *Privacy
+	macKey []byte
+}
+
+// NewValueCipher derives a key for cmType with a fresh salt.
+func NewValueCipher(cmType CipherMethodType, keygen KeyGen, passphrase string) (v *ValueCipher, err error) {
+	p := newPrivacy(keygen)
+	p.cmType = cmType
+	if err = p.NewSalt(); err != nil {
+		return
+	}
+
+	return newValueCipher(p, passphrase)
+}
+
+// OpenValueCipher derives the key for salt, as returned by Salt, and verifies the passphrase against check, as
+// returned by Check. It returns ErrWrongPassphrase on mismatch.
+func OpenValueCipher(salt, check []byte, keygen KeyGen, passphrase string) (v *ValueCipher, err error) {
+	p := newPrivacy(keygen)
+	if err = p.SetSalt(salt); err != nil {
+		return
+	}
+	p.cmType = CipherMethodType(salt[0] &^ headerExtended)
+
+	if v, err = newValueCipher(p, passphrase); err != nil {
+		return
+	}
+
+	if !hmac.Equal(v.Check(), check) {
+		return nil, ErrWrongPassphrase
+	}
+
+	return
+}
+
+func newValueCipher(p *Privacy, passphrase string) (v *ValueCipher, err error) {
+	if err = p.GenerateKey(passphrase); err != nil {
+		return
+	}
+
+	v = &ValueCipher{
+		p:      p,
+		macKey: make([]byte, sha256.Size),
+	}
+	if _, err = io.ReadFull(hkdf.New(sha256.New, p.checkKey, p.salt, []byte(valueMACInfo)), v.macKey); err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+// Salt returns the salt, which also records the cipher method.
+func (v *ValueCipher) Salt() []byte {
+	return v.p.salt
+}
+
+// Check returns the key check value, see OpenValueCipher.
+func (v *ValueCipher) Check() []byte {
+	return v.p.keyCheck()
+}
+
+// NewMAC returns an HMAC-SHA256 keyed for authenticating a whole document, with a key independent of the value key.
+func (v *ValueCipher) NewMAC() hash.Hash {
+	return hmac.New(sha256.New, v.macKey)
+}
+
+// Seal encrypts plain and returns the sealed value. ad has to be passed to Open again.
+func (v *ValueCipher) Seal(plain, ad []byte) (string, error) {
+	nonce := make([]byte, v.p.aead.NonceSize(), v.p.aead.NonceSize()+len(plain)+v.p.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	b := v.p.aead.Seal(nonce, nonce, plain, ad)
+	return sealedValuePrefix + base64.RawURLEncoding.EncodeToString(b) + sealedValueSuffix, nil
+}
+
+// Open decrypts a value returned by Seal.
+func (v *ValueCipher) Open(sealed string, ad []byte) (plain []byte, err error) {
+	var (
+		b []byte
+	)
+
+	if !IsSealedValue(sealed) {
+		return nil, ErrInvalidSealedValue
+	}
+
+	if b, err = base64.RawURLEncoding.DecodeString(sealed[len(sealedValuePrefix) : len(sealed)-len(sealedValueSuffix)]); err != nil {
+		return nil, ErrInvalidSealedValue
+	}
+
+	if len(b) < v.p.aead.NonceSize()+v.p.aead.Overhead() {
+		return nil, ErrInvalidSealedValue
+	}
+
+	return v.p.aead.Open(nil, b[:v.p.aead.NonceSize()], b[v.p.aead.NonceSize():], ad)
+}
+
+// IsSealedValue tells whether s looks like a value returned by ValueCipher.Seal.
+func IsSealedValue(s string) bool {
+	return strings.HasPrefix(s, sealedValuePrefix) && strings.HasSuffix(s, sealedValueSuffix)
+}
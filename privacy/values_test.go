@@ -0,0 +1,51 @@
+package privacy
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestValueCipher(t *testing.T) {
+	keygen, err := NewArgon2WithParams(1, 4*1024, 2)
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	for _, cmType := range []CipherMethodType{XChaCha20Simple, AES256GCMSimple} {
+		v, err := NewValueCipher(cmType, keygen, "some passphrase")
+		if err != nil {
+			t.Fatal("unexpected: NewValueCipher failed", err)
+		}
+
+		sealed, err := v.Seal([]byte("hunter2"), []byte("/db/password"))
+		if err != nil || !IsSealedValue(sealed) {
+			t.Fatal("unexpected: Seal returned", sealed, err)
+		}
+		if other, _ := v.Seal([]byte("hunter2"), []byte("/db/password")); other == sealed {
+			t.Fatal("unexpected: equal values should seal differently")
+		}
+
+		if _, err = OpenValueCipher(v.Salt(), v.Check(), keygen, "wrong"); err != ErrWrongPassphrase {
+			t.Fatal("unexpected: it should return ErrWrongPassphrase, got", err)
+		}
+
+		opened, err := OpenValueCipher(v.Salt(), v.Check(), keygen, "some passphrase")
+		if err != nil {
+			t.Fatal("unexpected: OpenValueCipher failed", err)
+		}
+
+		if plain, err := opened.Open(sealed, []byte("/db/password")); err != nil || string(plain) != "hunter2" {
+			t.Fatal("unexpected: Open returned", string(plain), err)
+		}
+		if _, err = opened.Open(sealed, []byte("/db/user")); err == nil {
+			t.Fatal("unexpected: Open should fail with other additional data")
+		}
+		if _, err = opened.Open("SPT[AAAA]", nil); err != ErrInvalidSealedValue {
+			t.Fatal("unexpected: it should return ErrInvalidSealedValue, got", err)
+		}
+
+		if !bytes.Equal(v.NewMAC().Sum([]byte("doc")), opened.NewMAC().Sum([]byte("doc"))) {
+			t.Fatal("unexpected: the MAC keys differ")
+		}
+	}
+}
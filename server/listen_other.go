@@ -0,0 +1,22 @@
+//go:build !unix
+
+package server
+
+import (
+	"net"
+	"os"
+)
+
+// listenUnix creates the socket at path; without a umask it can only be restricted once it exists.
+func listenUnix(path string) (l net.Listener, err error) {
+	if l, err = net.Listen("unix", path); err != nil {
+		return
+	}
+
+	if err = os.Chmod(path, 0600); err != nil {
+		_ = l.Close()
+		return nil, err
+	}
+
+	return l, nil
+}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package server
+
+import (
+	"net"
+	"syscall"
+)
+
+// listenUnix creates the socket at path under a umask that leaves it only accessible by its owner, so nobody can
+// connect between its creation and a chmod. The umask is process wide; Listen is called once, at startup.
+func listenUnix(path string) (net.Listener, error) {
+	old := syscall.Umask(0177)
+	defer syscall.Umask(old)
+
+	return net.Listen("unix", path)
+}
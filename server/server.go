@@ -0,0 +1,190 @@
+// Package server exposes encryption and decryption over HTTP, for local tools that can't link the privacy package.
+// It only listens on a unix socket, whose file permissions decide who may use it:
+//
+//	POST /encrypt   the request body is encrypted into the response body
+//	POST /decrypt   the request body, in any format Detect recognizes, is decrypted into the response body
+//
+// The passphrase comes from the X-Spt-Passphrase header, or from a key file named by the X-Spt-Key header, read from
+// the key directory of the Server. Bodies are streamed, not buffered. Errors found before the response starts are
+// reported with a status code; an error in the middle of a stream, e.g. a tampered segment, aborts the connection,
+// so the client never mistakes a partial response for a complete one.
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gitea.suyono.dev/suyono/simple-privacy-tool/privacy"
+)
+
+const (
+	PassphraseHeader = "X-Spt-Passphrase"
+	KeyHeader        = "X-Spt-Key"
+
+	DefaultMaxSize = 1 << 30
+)
+
+var (
+	ErrNoKey          = errors.New("missing passphrase or key header")
+	ErrInvalidKeyName = errors.New("invalid key name")
+	ErrInvalidAddress = errors.New("invalid listen address, expected unix:PATH")
+)
+
+// Server is the http.Handler of the service.
+type Server struct {
+	// KeyGen derives the keys from the passphrases.
+	KeyGen privacy.KeyGen
+
+	// EncryptOptions set the output format of /encrypt, e.g. privacy.WithCompression.
+	EncryptOptions []privacy.Option
+
+	// KeyDir holds the key files, each containing a passphrase. Without it, X-Spt-Key is rejected.
+	KeyDir string
+
+	// MaxSize limits the request body size in bytes. Zero means no limit.
+	MaxSize int64
+
+	mux *http.ServeMux
+}
+
+func New(keygen privacy.KeyGen) *Server {
+	s := &Server{
+		KeyGen:  keygen,
+		MaxSize: DefaultMaxSize,
+		mux:     http.NewServeMux(),
+	}
+
+	s.mux.HandleFunc("POST /encrypt", func(w http.ResponseWriter, r *http.Request) {
+		s.process(w, r, privacy.Encrypt, s.EncryptOptions)
+	})
+	s.mux.HandleFunc("POST /decrypt", func(w http.ResponseWriter, r *http.Request) {
+		s.process(w, r, privacy.Decrypt, nil)
+	})
+
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Listen opens the listener for addr, unix:PATH. A stale socket is removed, and a new one is only accessible by its
+// owner. There is no TCP listener: the passphrases and the plain text go over the connection unencrypted and
+// unauthenticated.
+func Listen(addr string) (l net.Listener, err error) {
+	var (
+		fi os.FileInfo
+	)
+
+	network, address, ok := strings.Cut(addr, ":")
+	if !ok || network != "unix" || address == "" {
+		return nil, ErrInvalidAddress
+	}
+
+	if fi, err = os.Lstat(address); err == nil && fi.Mode()&fs.ModeSocket != 0 {
+		if err = os.Remove(address); err != nil {
+			return
+		}
+	}
+
+	return listenUnix(address)
+}
+
+func (s *Server) passphrase(r *http.Request) (string, error) {
+	if p := r.Header.Get(PassphraseHeader); p != "" {
+		return p, nil
+	}
+
+	name := r.Header.Get(KeyHeader)
+	if name == "" {
+		return "", ErrNoKey
+	}
+
+	if s.KeyDir == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return "", ErrInvalidKeyName
+	}
+
+	b, err := os.ReadFile(filepath.Join(s.KeyDir, name))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", ErrInvalidKeyName
+		}
+		return "", err
+	}
+
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+// responseWriter records whether the response has started.
+type responseWriter struct {
+	http.ResponseWriter
+	started bool
+}
+
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	rw.started = true
+	return rw.ResponseWriter.Write(b)
+}
+
+type processFunc func(dst io.Writer, src io.Reader, opts ...privacy.Option) error
+
+func (s *Server) process(w http.ResponseWriter, r *http.Request, process processFunc, format []privacy.Option) {
+	var (
+		body io.Reader = r.Body
+	)
+
+	passphrase, err := s.passphrase(r)
+	if err != nil {
+		s.fail(w, err)
+		return
+	}
+
+	if s.MaxSize > 0 {
+		if r.ContentLength > s.MaxSize {
+			s.fail(w, &http.MaxBytesError{Limit: s.MaxSize})
+			return
+		}
+		body = http.MaxBytesReader(w, r.Body, s.MaxSize)
+	}
+
+	opts := append([]privacy.Option{
+		privacy.WithContext(r.Context()),
+		privacy.WithKeyGen(s.KeyGen),
+	}, format...)
+	opts = append(opts, privacy.WithPassphrase(passphrase))
+
+	rw := &responseWriter{ResponseWriter: w}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	if err = process(rw, body, opts...); err != nil {
+		if rw.started {
+			panic(http.ErrAbortHandler)
+		}
+		s.fail(w, err)
+	}
+}
+
+func (s *Server) fail(w http.ResponseWriter, err error) {
+	var (
+		maxBytesErr *http.MaxBytesError
+		code        = http.StatusBadRequest
+	)
+
+	switch {
+	case errors.As(err, &maxBytesErr):
+		code = http.StatusRequestEntityTooLarge
+	case errors.Is(err, privacy.ErrWrongPassphrase):
+		code = http.StatusForbidden
+	case errors.Is(err, ErrNoKey), errors.Is(err, ErrInvalidKeyName):
+		code = http.StatusUnauthorized
+	}
+
+	w.Header().Del("Content-Type")
+	http.Error(w, fmt.Sprint(err), code)
+}
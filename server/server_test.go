@@ -0,0 +1,187 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gitea.suyono.dev/suyono/simple-privacy-tool/privacy"
+)
+
+func startServer(t *testing.T, s *Server) *http.Client {
+	sock := filepath.Join(t.TempDir(), "spt.sock")
+	l, err := Listen("unix:" + sock)
+	if err != nil {
+		t.Fatal("unexpected: Listen failed", err)
+	}
+
+	fi, err := os.Stat(sock)
+	if err != nil || fi.Mode().Perm() != 0600 {
+		t.Fatal("unexpected: socket permissions", err)
+	}
+
+	ts := httptest.NewUnstartedServer(s)
+	_ = ts.Listener.Close()
+	ts.Listener = l
+	ts.Start()
+	t.Cleanup(ts.Close)
+
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", sock)
+			},
+		},
+	}
+}
+
+func post(t *testing.T, c *http.Client, path string, body []byte, header ...string) (int, []byte, error) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPost, "http://spt"+path, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatal("unexpected: request failed", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	b, err := io.ReadAll(resp.Body)
+	return resp.StatusCode, b, err
+}
+
+func TestServer(t *testing.T) {
+	keygen, err := privacy.NewArgon2WithParams(1, 4*1024, 2)
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	s := New(keygen)
+	s.KeyDir = t.TempDir()
+	s.MaxSize = 1 << 20
+	s.EncryptOptions = []privacy.Option{
+		privacy.WithSegmentSize(1024),
+		privacy.WithCompression(privacy.GzipCompression, privacy.DefaultCompressionLevel),
+	}
+	if err = os.WriteFile(filepath.Join(s.KeyDir, "backup"), []byte("key file passphrase\n"), 0600); err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+	c := startServer(t, s)
+
+	plain := bytes.Repeat([]byte("some plain text\n"), 1000)
+	code, enc, err := post(t, c, "/encrypt", plain, PassphraseHeader, "some passphrase")
+	if err != nil || code != http.StatusOK || bytes.Contains(enc, []byte("plain")) {
+		t.Fatal("unexpected: encrypt", code, err)
+	}
+
+	out, err := privacy.DecryptBytes(enc, privacy.WithPassphrase("some passphrase"), privacy.WithKeyGen(keygen))
+	if err != nil || !bytes.Equal(out, plain) {
+		t.Fatal("unexpected: decrypting the response", err)
+	}
+
+	if code, out, err = post(t, c, "/decrypt", enc, PassphraseHeader, "some passphrase"); err != nil ||
+		code != http.StatusOK || !bytes.Equal(out, plain) {
+		t.Fatal("unexpected: decrypt", code, err)
+	}
+
+	if code, enc, err = post(t, c, "/encrypt", plain, KeyHeader, "backup"); err != nil || code != http.StatusOK {
+		t.Fatal("unexpected: encrypt with a key file", code, err)
+	}
+	if out, err = privacy.DecryptBytes(enc, privacy.WithPassphrase("key file passphrase"),
+		privacy.WithKeyGen(keygen)); err != nil || !bytes.Equal(out, plain) {
+		t.Fatal("unexpected: decrypting with the key file passphrase", err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		path   string
+		body   []byte
+		header []string
+		code   int
+	}{
+		{"no passphrase", "/encrypt", plain, nil, http.StatusUnauthorized},
+		{"key outside the key directory", "/encrypt", plain, []string{KeyHeader, "../backup"}, http.StatusUnauthorized},
+		{"unknown key", "/decrypt", enc, []string{KeyHeader, "other"}, http.StatusUnauthorized},
+		{"wrong passphrase", "/decrypt", enc, []string{PassphraseHeader, "wrong"}, http.StatusForbidden},
+		{"not encrypted", "/decrypt", plain, []string{PassphraseHeader, "wrong"}, http.StatusBadRequest},
+		{"too large", "/encrypt", make([]byte, 2<<20), []string{PassphraseHeader, "some passphrase"},
+			http.StatusRequestEntityTooLarge},
+	} {
+		if code, _, _ = post(t, c, tc.path, tc.body, tc.header...); code != tc.code {
+			t.Fatal("unexpected: status for", tc.name, code)
+		}
+	}
+
+	resp, err := c.Get("http://spt/encrypt")
+	if err != nil {
+		t.Fatal("unexpected: request failed", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatal("unexpected: GET status", resp.StatusCode)
+	}
+}
+
+func TestServerAbort(t *testing.T) {
+	keygen, err := privacy.NewArgon2WithParams(1, 4*1024, 2)
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	c := startServer(t, New(keygen))
+
+	plain := bytes.Repeat([]byte{0xA5}, 1<<20)
+	enc, err := privacy.EncryptBytes(plain, privacy.WithPassphrase("some passphrase"), privacy.WithKeyGen(keygen),
+		privacy.WithSegmentSize(64*1024))
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+	enc[len(enc)-100] ^= 1
+
+	// the first segments are sent before the tampered one is found, the client has to see an error
+	code, out, err := post(t, c, "/decrypt", enc, PassphraseHeader, "some passphrase")
+	if code != http.StatusOK || err == nil || len(out) == len(plain) {
+		t.Fatal("unexpected: tampered stream", code, len(out), err)
+	}
+}
+
+func TestListen(t *testing.T) {
+	for _, addr := range []string{"", "unix:", "udp:127.0.0.1:0", "tcp:127.0.0.1:0", "/tmp/sock"} {
+		if _, err := Listen(addr); err != ErrInvalidAddress {
+			t.Fatal("unexpected: it should return ErrInvalidAddress for", addr, err)
+		}
+	}
+
+	sock := filepath.Join(t.TempDir(), "spt.sock")
+	for i := 0; i < 2; i++ {
+		// the second Listen replaces the stale socket
+		l, err := Listen("unix:" + sock)
+		if err != nil {
+			t.Fatal("unexpected: Listen failed", err)
+		}
+		if !strings.HasSuffix(l.Addr().String(), "spt.sock") {
+			t.Fatal("unexpected: address", l.Addr())
+		}
+		if i == 0 {
+			// keep the socket file like a crashed server
+			l.(*net.UnixListener).SetUnlinkOnClose(false)
+		}
+		_ = l.Close()
+	}
+}
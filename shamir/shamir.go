@@ -0,0 +1,124 @@
+// Package shamir splits a secret into shares with Shamir's secret sharing over GF(256), so any threshold of them
+// recover the secret and fewer reveal nothing about it. Each byte of the secret is the constant term of its own
+// random polynomial of degree threshold-1; share i holds the values of all the polynomials at x = i.
+//
+// The field arithmetic uses the AES polynomial and avoids table lookups, so its timing doesn't depend on the secret.
+package shamir
+
+import (
+	"crypto/rand"
+	"errors"
+)
+
+const (
+	MaxShares = 255
+)
+
+var (
+	ErrInvalidThreshold = errors.New("invalid number of shares or threshold")
+	ErrEmptySecret      = errors.New("empty secret")
+	ErrInvalidShares    = errors.New("invalid shares")
+)
+
+// Split returns n shares of secret, any threshold of which recover it with Combine. The x coordinate of share i is
+// i+1.
+func Split(secret []byte, n, threshold int) (shares [][]byte, err error) {
+	var (
+		coeffs []byte
+	)
+
+	if threshold < 2 || n < threshold || n > MaxShares {
+		return nil, ErrInvalidThreshold
+	}
+
+	if len(secret) == 0 {
+		return nil, ErrEmptySecret
+	}
+
+	shares = make([][]byte, n)
+	for i := range shares {
+		shares[i] = make([]byte, len(secret))
+	}
+
+	coeffs = make([]byte, threshold)
+	defer clear(coeffs)
+	for b, s := range secret {
+		if _, err = rand.Read(coeffs[1:]); err != nil {
+			return nil, err
+		}
+		coeffs[0] = s
+
+		for i := range shares {
+			shares[i][b] = evaluate(coeffs, byte(i+1))
+		}
+	}
+
+	return
+}
+
+// Combine recovers the secret from shares and their x coordinates. With fewer shares than the threshold used by Split
+// the result is a random-looking value, not an error; callers have to check it, e.g. against a hash.
+func Combine(xs []byte, shares [][]byte) (secret []byte, err error) {
+	if len(xs) < 2 || len(xs) != len(shares) {
+		return nil, ErrInvalidShares
+	}
+
+	for i, x := range xs {
+		if x == 0 || len(shares[i]) == 0 || len(shares[i]) != len(shares[0]) {
+			return nil, ErrInvalidShares
+		}
+		for _, other := range xs[:i] {
+			if other == x {
+				return nil, ErrInvalidShares
+			}
+		}
+	}
+
+	// Lagrange interpolation at x = 0
+	secret = make([]byte, len(shares[0]))
+	for i, xi := range xs {
+		basis := byte(1)
+		for j, xj := range xs {
+			if i != j {
+				basis = mul(basis, div(xj, xj^xi))
+			}
+		}
+
+		for b := range secret {
+			secret[b] ^= mul(shares[i][b], basis)
+		}
+	}
+
+	return
+}
+
+// evaluate returns the polynomial with the coefficients, lowest degree first, at x, with Horner's method.
+func evaluate(coeffs []byte, x byte) (y byte) {
+	for i := len(coeffs) - 1; i >= 0; i-- {
+		y = mul(y, x) ^ coeffs[i]
+	}
+
+	return
+}
+
+// mul multiplies in GF(256) modulo x^8 + x^4 + x^3 + x + 1, in constant time.
+func mul(a, b byte) (p byte) {
+	for i := 0; i < 8; i++ {
+		p ^= a & -(b & 1)
+		carry := -(a >> 7)
+		a = a<<1 ^ 0x1b&carry
+		b >>= 1
+	}
+
+	return
+}
+
+// div divides by b, which must not be zero, as a * b^254.
+func div(a, b byte) byte {
+	inv := b
+	for i := 0; i < 6; i++ {
+		inv = mul(mul(inv, inv), b)
+	}
+
+	return mul(a, mul(inv, inv))
+}
@@ -0,0 +1,123 @@
+package shamir
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"testing"
+)
+
+func TestField(t *testing.T) {
+	for a := 1; a < 256; a++ {
+		if mul(byte(a), div(1, byte(a))) != 1 {
+			t.Fatal("unexpected: no inverse for", a)
+		}
+		if mul(byte(a), 1) != byte(a) || mul(byte(a), 0) != 0 {
+			t.Fatal("unexpected: mul identity for", a)
+		}
+	}
+
+	// 0x53 * 0xCA = 0x01 in the AES field
+	if mul(0x53, 0xCA) != 0x01 {
+		t.Fatal("unexpected: mul(0x53, 0xCA) =", mul(0x53, 0xCA))
+	}
+}
+
+func TestSplitCombine(t *testing.T) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	shares, err := Split(secret, 5, 3)
+	if err != nil {
+		t.Fatal("unexpected: Split failed", err)
+	}
+
+	for _, subset := range [][]int{{0, 1, 2}, {4, 2, 0}, {1, 3, 4}, {0, 1, 2, 3, 4}} {
+		var (
+			xs   []byte
+			data [][]byte
+		)
+		for _, i := range subset {
+			xs = append(xs, byte(i+1))
+			data = append(data, shares[i])
+		}
+
+		got, err := Combine(xs, data)
+		if err != nil || !bytes.Equal(got, secret) {
+			t.Fatal("unexpected: Combine", subset, err)
+		}
+	}
+
+	got, err := Combine([]byte{1, 2}, shares[:2])
+	if err != nil || bytes.Equal(got, secret) {
+		t.Fatal("unexpected: two of three shares shouldn't recover the secret", err)
+	}
+
+	for _, tc := range []struct{ n, threshold int }{{5, 1}, {2, 3}, {256, 3}} {
+		if _, err = Split(secret, tc.n, tc.threshold); err != ErrInvalidThreshold {
+			t.Fatal("unexpected: it should return ErrInvalidThreshold, got", err)
+		}
+	}
+	if _, err = Combine([]byte{1, 1}, shares[:2]); err != ErrInvalidShares {
+		t.Fatal("unexpected: it should return ErrInvalidShares, got", err)
+	}
+}
+
+func TestShareKey(t *testing.T) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	shares, err := SplitKey(key, 5, 3)
+	if err != nil {
+		t.Fatal("unexpected: SplitKey failed", err)
+	}
+
+	// through the text encoding
+	parsed := make([]Share, len(shares))
+	for i, s := range shares {
+		b, err := s.MarshalText()
+		if err != nil {
+			t.Fatal("unexpected: MarshalText failed", err)
+		}
+		if err = parsed[i].UnmarshalText(append(b, '\n')); err != nil {
+			t.Fatal("unexpected: UnmarshalText failed", err)
+		}
+	}
+
+	if got, err := CombineKey([]Share{parsed[4], parsed[1], parsed[3]}); err != nil || !bytes.Equal(got, key) {
+		t.Fatal("unexpected: CombineKey", err)
+	}
+
+	if _, err = CombineKey(parsed[:2]); !errors.Is(err, ErrNotEnoughShares) {
+		t.Fatal("unexpected: it should return ErrNotEnoughShares, got", err)
+	}
+	if _, err = CombineKey([]Share{parsed[0], parsed[1], parsed[1]}); err != ErrDuplicateShare {
+		t.Fatal("unexpected: it should return ErrDuplicateShare, got", err)
+	}
+
+	other, err := SplitKey(key[:16], 5, 3)
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+	if _, err = CombineKey([]Share{parsed[0], parsed[1], other[2]}); err != ErrMixedShares {
+		t.Fatal("unexpected: it should return ErrMixedShares, got", err)
+	}
+
+	corrupted := parsed[2]
+	corrupted.Data = bytes.Clone(corrupted.Data)
+	corrupted.Data[0] ^= 1
+	if _, err = CombineKey([]Share{parsed[0], parsed[1], corrupted}); err != ErrKeyCheckMismatch {
+		t.Fatal("unexpected: it should return ErrKeyCheckMismatch, got", err)
+	}
+
+	var s Share
+	for _, text := range []string{"", "hello", "spt-share-v1 0 3 0011223344556677 AAAA", "spt-share-v1 1 3 00 AAAA"} {
+		if err = s.UnmarshalText([]byte(text)); err != ErrNotShare {
+			t.Fatal("unexpected: it should return ErrNotShare for", text, err)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package shamir
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// A share file is one line of text
+//
+//	spt-share-v1 X THRESHOLD ID DATA
+//
+// with X and THRESHOLD in decimal, ID the hex encoded first idLen bytes of the SHA-256 of the key, and DATA the
+// base64 encoded share. The ID tells which shares belong together, and confirms the combined key.
+const (
+	shareMagic = "spt-share-v1"
+	idLen      = 8
+)
+
+var (
+	ErrNotShare         = errors.New("not a share")
+	ErrMixedShares      = errors.New("the shares belong to different keys")
+	ErrNotEnoughShares  = errors.New("not enough shares")
+	ErrDuplicateShare   = errors.New("duplicate share")
+	ErrKeyCheckMismatch = errors.New("the combined key doesn't match the shares")
+)
+
+// Share is one share of a key, as written to a share file.
+type Share struct {
+	X         byte
+	Threshold int
+	ID        []byte
+	Data      []byte
+}
+
+func keyID(key []byte) []byte {
+	sum := sha256.Sum256(key)
+	return sum[:idLen]
+}
+
+// SplitKey splits key into n shares, any threshold of which recover it with CombineKey.
+func SplitKey(key []byte, n, threshold int) (shares []Share, err error) {
+	var (
+		data [][]byte
+	)
+
+	if data, err = Split(key, n, threshold); err != nil {
+		return
+	}
+
+	id := keyID(key)
+	shares = make([]Share, n)
+	for i := range shares {
+		shares[i] = Share{X: byte(i + 1), Threshold: threshold, ID: id, Data: data[i]}
+	}
+
+	return
+}
+
+// CombineKey recovers the key from at least the threshold of its shares, and checks it against their ID.
+func CombineKey(shares []Share) (key []byte, err error) {
+	var (
+		xs   []byte
+		data [][]byte
+	)
+
+	if len(shares) == 0 {
+		return nil, ErrNotEnoughShares
+	}
+
+	for i, s := range shares {
+		if !bytes.Equal(s.ID, shares[0].ID) || s.Threshold != shares[0].Threshold {
+			return nil, ErrMixedShares
+		}
+		for _, other := range shares[:i] {
+			if other.X == s.X {
+				return nil, ErrDuplicateShare
+			}
+		}
+		xs = append(xs, s.X)
+		data = append(data, s.Data)
+	}
+
+	if len(shares) < shares[0].Threshold {
+		return nil, fmt.Errorf("%w: %d of %d", ErrNotEnoughShares, len(shares), shares[0].Threshold)
+	}
+
+	if key, err = Combine(xs, data); err != nil {
+		return
+	}
+
+	if subtle.ConstantTimeCompare(keyID(key), shares[0].ID) != 1 {
+		return nil, ErrKeyCheckMismatch
+	}
+
+	return
+}
+
+func (s Share) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%s %d %d %s %s", shareMagic, s.X, s.Threshold, hex.EncodeToString(s.ID),
+		base64.StdEncoding.EncodeToString(s.Data))), nil
+}
+
+func (s *Share) UnmarshalText(b []byte) (err error) {
+	var (
+		magic, id, data string
+		x               int
+	)
+
+	if _, err = fmt.Sscanf(strings.TrimSpace(string(b)), "%s %d %d %s %s", &magic, &x, &s.Threshold, &id, &data); err != nil ||
+		magic != shareMagic {
+		return ErrNotShare
+	}
+
+	if x < 1 || x > MaxShares || s.Threshold < 2 || s.Threshold > MaxShares {
+		return ErrNotShare
+	}
+	s.X = byte(x)
+
+	if s.ID, err = hex.DecodeString(id); err != nil || len(s.ID) != idLen {
+		return ErrNotShare
+	}
+
+	if s.Data, err = base64.StdEncoding.DecodeString(data); err != nil || len(s.Data) == 0 {
+		return ErrNotShare
+	}
+
+	return nil
+}
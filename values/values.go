@@ -0,0 +1,368 @@
+// Package values encrypts the values of a JSON or YAML document and leaves its keys readable, so an encrypted
+// configuration file still shows its structure in a code review or a diff. Every scalar value is replaced by a sealed
+// value, see privacy.ValueCipher, bound to its path in the document. The document gets a top-level spt key with
+//
+//	version  the format version
+//	keygen   the KeyGen parameters
+//	salt     the salt, which also records the cipher method, base64
+//	check    the key check value, base64
+//	mac      an HMAC over the paths, the node kinds along them, and the sealed values of the document, base64
+//
+// The MAC detects values that were added, removed, moved, or replaced, e.g. by a bad merge. Mapping keys, comments,
+// and the order of the keys are not encrypted; YAML anchors and aliases aren't supported.
+package values
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gitea.suyono.dev/suyono/simple-privacy-tool/privacy"
+	"gopkg.in/yaml.v3"
+)
+
+type Format byte
+
+const (
+	YAMLFormat Format = iota
+	JSONFormat
+)
+
+const (
+	MetadataKey = "spt"
+
+	version = 1
+)
+
+var (
+	ErrNotMapping         = errors.New("the document is not a mapping")
+	ErrEncrypted          = errors.New("the document is encrypted already")
+	ErrNotEncrypted       = errors.New("the document is not encrypted")
+	ErrUnsupportedVersion = errors.New("unsupported encrypted document version")
+	ErrMACMismatch        = errors.New("the document was modified, MAC mismatch")
+	ErrAlias              = errors.New("YAML aliases are not supported")
+)
+
+type metadata struct {
+	Version int            `yaml:"version" json:"version"`
+	KeyGen  map[string]any `yaml:"keygen" json:"keygen"`
+	Salt    string         `yaml:"salt" json:"salt"`
+	Check   string         `yaml:"check" json:"check"`
+	MAC     string         `yaml:"mac" json:"mac"`
+}
+
+// FormatFromName returns JSONFormat for names ending in .json, ignoring a trailing .spt, and YAMLFormat otherwise.
+func FormatFromName(name string) Format {
+	if strings.EqualFold(filepath.Ext(strings.TrimSuffix(name, ".spt")), ".json") {
+		return JSONFormat
+	}
+
+	return YAMLFormat
+}
+
+// Encrypt seals every scalar value of doc with a key derived from passphrase and a fresh salt, and adds the
+// metadata.
+func Encrypt(doc []byte, format Format, passphrase string, cmType privacy.CipherMethodType, keygen privacy.KeyGen) (
+	out []byte, err error) {
+	var (
+		root *yaml.Node
+		m    *yaml.Node
+		v    *privacy.ValueCipher
+		md   metadata
+		b    []byte
+	)
+
+	if root, m, err = parse(doc); err != nil {
+		return
+	}
+
+	if _, i := metadataNode(m); i >= 0 {
+		return nil, ErrEncrypted
+	}
+
+	if v, err = privacy.NewValueCipher(cmType, keygen, passphrase); err != nil {
+		return
+	}
+
+	mac := v.NewMAC()
+	mac.Write(v.Salt())
+	if err = walk(m, nil, nil, func(n *yaml.Node, path, kinds []byte) error {
+		plain := n.ShortTag() + "\n" + n.Value
+		sealed, err := v.Seal([]byte(plain), path)
+		if err != nil {
+			return err
+		}
+
+		*n = yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: sealed, LineComment: n.LineComment,
+			HeadComment: n.HeadComment, FootComment: n.FootComment}
+		macValue(mac, path, kinds, sealed)
+		return nil
+	}); err != nil {
+		return
+	}
+
+	if b, err = keygen.MarshalJSON(); err != nil {
+		return
+	}
+	if err = json.Unmarshal(b, &md.KeyGen); err != nil {
+		return
+	}
+	md.Version = version
+	md.Salt = base64.StdEncoding.EncodeToString(v.Salt())
+	md.Check = base64.StdEncoding.EncodeToString(v.Check())
+	md.MAC = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	var mdNode yaml.Node
+	if err = mdNode.Encode(&md); err != nil {
+		return
+	}
+	m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: MetadataKey}, &mdNode)
+
+	return encode(root, format)
+}
+
+// Decrypt verifies the MAC of doc and opens its sealed values. A wrong passphrase is reported as
+// privacy.ErrWrongPassphrase.
+func Decrypt(doc []byte, format Format, passphrase string) (out []byte, err error) {
+	var (
+		root  *yaml.Node
+		m     *yaml.Node
+		v     *privacy.ValueCipher
+		md    metadata
+		salt  []byte
+		check []byte
+		sum   []byte
+		b     []byte
+	)
+
+	if root, m, err = parse(doc); err != nil {
+		return
+	}
+
+	mdNode, i := metadataNode(m)
+	if i < 0 {
+		return nil, ErrNotEncrypted
+	}
+	if err = mdNode.Decode(&md); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrNotEncrypted, err)
+	}
+	if md.Version != version {
+		return nil, ErrUnsupportedVersion
+	}
+	m.Content = append(m.Content[:i], m.Content[i+2:]...)
+
+	if b, err = json.Marshal(md.KeyGen); err != nil {
+		return
+	}
+	keygen, err := privacy.ParseKeyGen(b)
+	if err != nil {
+		return
+	}
+
+	if salt, err = base64.StdEncoding.DecodeString(md.Salt); err != nil {
+		return nil, fmt.Errorf("%w: salt: %w", ErrNotEncrypted, err)
+	}
+	if check, err = base64.StdEncoding.DecodeString(md.Check); err != nil {
+		return nil, fmt.Errorf("%w: check: %w", ErrNotEncrypted, err)
+	}
+	if sum, err = base64.StdEncoding.DecodeString(md.MAC); err != nil {
+		return nil, fmt.Errorf("%w: mac: %w", ErrNotEncrypted, err)
+	}
+
+	if v, err = privacy.OpenValueCipher(salt, check, keygen, passphrase); err != nil {
+		return
+	}
+
+	// the whole document is authenticated before any value is opened
+	mac := v.NewMAC()
+	mac.Write(salt)
+	if err = walk(m, nil, nil, func(n *yaml.Node, path, kinds []byte) error {
+		macValue(mac, path, kinds, n.Value)
+		return nil
+	}); err != nil {
+		return
+	}
+	if !hmac.Equal(mac.Sum(nil), sum) {
+		return nil, ErrMACMismatch
+	}
+
+	if err = walk(m, nil, nil, func(n *yaml.Node, path, kinds []byte) error {
+		plain, err := v.Open(n.Value, path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		tag, value, ok := strings.Cut(string(plain), "\n")
+		if !ok {
+			return fmt.Errorf("%s: %w", path, privacy.ErrInvalidSealedValue)
+		}
+
+		n.Tag, n.Value, n.Style = tag, value, 0
+		if tag == "!!str" && strings.Contains(value, "\n") {
+			n.Style = yaml.LiteralStyle
+		}
+		return nil
+	}); err != nil {
+		return
+	}
+
+	return encode(root, format)
+}
+
+func parse(doc []byte) (root *yaml.Node, m *yaml.Node, err error) {
+	root = &yaml.Node{}
+	if err = yaml.Unmarshal(doc, root); err != nil {
+		return
+	}
+
+	if len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		return nil, nil, ErrNotMapping
+	}
+
+	return root, root.Content[0], nil
+}
+
+// metadataNode returns the value of the top-level metadata key and the index of the key in m.Content, or -1.
+func metadataNode(m *yaml.Node) (*yaml.Node, int) {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == MetadataKey {
+			return m.Content[i+1], i
+		}
+	}
+
+	return nil, -1
+}
+
+// walk calls fn for every scalar value below n, with its path encoded as a JSON array, in document order, and the
+// kinds of the nodes along the path, 'm' for a mapping and 's' for a sequence. The metadata key is skipped at the top
+// level.
+func walk(n *yaml.Node, path []string, kinds []byte, fn func(n *yaml.Node, path, kinds []byte) error) (err error) {
+	switch n.Kind {
+	case yaml.MappingNode:
+		kinds := append(kinds[:len(kinds):len(kinds)], 'm')
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key := n.Content[i].Value
+			if len(path) == 0 && key == MetadataKey {
+				continue
+			}
+			if err = walk(n.Content[i+1], append(path[:len(path):len(path)], key), kinds, fn); err != nil {
+				return
+			}
+		}
+	case yaml.SequenceNode:
+		kinds := append(kinds[:len(kinds):len(kinds)], 's')
+		for i, c := range n.Content {
+			if err = walk(c, append(path[:len(path):len(path)], strconv.Itoa(i)), kinds, fn); err != nil {
+				return
+			}
+		}
+	case yaml.ScalarNode:
+		b, err := json.Marshal(path)
+		if err != nil {
+			return err
+		}
+		return fn(n, b, kinds)
+	case yaml.AliasNode:
+		return ErrAlias
+	}
+
+	return nil
+}
+
+// macValue adds a sealed value to the MAC. The kinds keep a sequence from being swapped for a mapping with the same
+// keys, e.g. [x] for {"0": x}.
+func macValue(mac io.Writer, path, kinds []byte, sealed string) {
+	_, _ = mac.Write(path)
+	_, _ = mac.Write([]byte{0})
+	_, _ = mac.Write(kinds)
+	_, _ = mac.Write([]byte{0})
+	_, _ = mac.Write([]byte(sealed))
+	_, _ = mac.Write([]byte{0})
+}
+
+func encode(root *yaml.Node, format Format) ([]byte, error) {
+	var (
+		buf bytes.Buffer
+	)
+
+	if format == JSONFormat {
+		if err := writeJSON(&buf, root.Content[0], ""); err != nil {
+			return nil, err
+		}
+		buf.WriteByte('\n')
+		return buf.Bytes(), nil
+	}
+
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(root); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// writeJSON writes n as indented JSON, keeping the order of the keys.
+func writeJSON(buf *bytes.Buffer, n *yaml.Node, indent string) (err error) {
+	var (
+		b []byte
+	)
+
+	switch n.Kind {
+	case yaml.MappingNode, yaml.SequenceNode:
+		open, closing, step := byte('{'), byte('}'), 2
+		if n.Kind == yaml.SequenceNode {
+			open, closing, step = '[', ']', 1
+		}
+
+		buf.WriteByte(open)
+		for i := 0; i < len(n.Content); i += step {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			buf.WriteString("\n" + indent + "  ")
+			if n.Kind == yaml.MappingNode {
+				if b, err = json.Marshal(n.Content[i].Value); err != nil {
+					return
+				}
+				buf.Write(b)
+				buf.WriteString(": ")
+				if err = writeJSON(buf, n.Content[i+1], indent+"  "); err != nil {
+					return
+				}
+			} else if err = writeJSON(buf, n.Content[i], indent+"  "); err != nil {
+				return
+			}
+		}
+		if len(n.Content) > 0 {
+			buf.WriteString("\n" + indent)
+		}
+		buf.WriteByte(closing)
+	case yaml.ScalarNode:
+		switch n.ShortTag() {
+		case "!!int", "!!float", "!!bool":
+			buf.WriteString(n.Value)
+		case "!!null":
+			buf.WriteString("null")
+		default:
+			if b, err = json.Marshal(n.Value); err != nil {
+				return
+			}
+			buf.Write(b)
+		}
+	case yaml.AliasNode:
+		return ErrAlias
+	}
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package values
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"gitea.suyono.dev/suyono/simple-privacy-tool/privacy"
+)
+
+const (
+	yamlDoc = `# database settings
+db:
+  host: db.example.com
+  port: 5432
+  password: "5432" # quoted on purpose
+  debug: false
+  note: |
+    line 1
+    line 2
+servers:
+  - alpha
+  - beta
+empty: null
+`
+	jsonDoc = `{
+  "db": {
+    "host": "db.example.com",
+    "port": 5432,
+    "password": "5432",
+    "ratio": 0.5
+  },
+  "servers": [
+    "alpha",
+    "beta"
+  ],
+  "empty": null,
+  "list": []
+}
+`
+)
+
+func TestRoundTrip(t *testing.T) {
+	keygen, err := privacy.NewArgon2WithParams(1, 4*1024, 2)
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		format Format
+		doc    string
+	}{
+		{"yaml", YAMLFormat, yamlDoc},
+		{"json", JSONFormat, jsonDoc},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			enc, err := Encrypt([]byte(tc.doc), tc.format, "some passphrase", privacy.XChaCha20Simple, keygen)
+			if err != nil {
+				t.Fatal("unexpected: Encrypt failed", err)
+			}
+
+			for _, s := range []string{"db.example.com", "alpha", "5432", "line 1"} {
+				if bytes.Contains(enc, []byte(s)) {
+					t.Fatal("unexpected: the value is readable", s, string(enc))
+				}
+			}
+			for _, s := range []string{"db", "host", "servers", "password", "spt", "SPT["} {
+				if !bytes.Contains(enc, []byte(s)) {
+					t.Fatal("unexpected: missing from the encrypted document", s, string(enc))
+				}
+			}
+
+			if _, err = Encrypt(enc, tc.format, "some passphrase", privacy.XChaCha20Simple, keygen); err != ErrEncrypted {
+				t.Fatal("unexpected: it should return ErrEncrypted, got", err)
+			}
+
+			if _, err = Decrypt(enc, tc.format, "wrong"); err != privacy.ErrWrongPassphrase {
+				t.Fatal("unexpected: it should return ErrWrongPassphrase, got", err)
+			}
+
+			dec, err := Decrypt(enc, tc.format, "some passphrase")
+			if err != nil {
+				t.Fatal("unexpected: Decrypt failed", err)
+			}
+			if string(dec) != tc.doc {
+				t.Fatalf("unexpected: decrypted document\n%s", dec)
+			}
+		})
+	}
+}
+
+func TestTamper(t *testing.T) {
+	keygen, err := privacy.NewArgon2WithParams(1, 4*1024, 2)
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	doc := "a: x\nb: y\nc: [1, 2]\n"
+	enc, err := Encrypt([]byte(doc), YAMLFormat, "some passphrase", privacy.AES256GCMSimple, keygen)
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	lines := strings.Split(string(enc), "\n")
+	swapped := append([]string{strings.Replace(lines[0], "a:", "b:", 1), strings.Replace(lines[1], "b:", "a:", 1)},
+		lines[2:]...)
+	removed := append([]string{lines[0]}, lines[2:]...)
+	items := strings.Split(strings.TrimSuffix(strings.TrimPrefix(lines[2], "c: ["), "]"), ", ")
+	remapped := append(lines[:2:2], fmt.Sprintf("c: {'0': %s, '1': %s}", items[0], items[1]))
+	remapped = append(remapped, lines[3:]...)
+
+	for name, tampered := range map[string]string{
+		"swapped":  strings.Join(swapped, "\n"),
+		"removed":  strings.Join(removed, "\n"),
+		"added":    "d: x\n" + string(enc),
+		"remapped": strings.Join(remapped, "\n"),
+	} {
+		if _, err = Decrypt([]byte(tampered), YAMLFormat, "some passphrase"); err != ErrMACMismatch {
+			t.Fatal("unexpected: it should return ErrMACMismatch for", name, err)
+		}
+	}
+
+	if _, err = Decrypt([]byte(doc), YAMLFormat, "some passphrase"); err != ErrNotEncrypted {
+		t.Fatal("unexpected: it should return ErrNotEncrypted, got", err)
+	}
+	if _, err = Encrypt([]byte("- a\n"), YAMLFormat, "some passphrase", privacy.AES256GCMSimple, keygen); err != ErrNotMapping {
+		t.Fatal("unexpected: it should return ErrNotMapping, got", err)
+	}
+	if _, err = Encrypt([]byte("a: &x 1\nb: *x\n"), YAMLFormat, "some passphrase", privacy.AES256GCMSimple, keygen); err != ErrAlias {
+		t.Fatal("unexpected: it should return ErrAlias, got", err)
+	}
+}
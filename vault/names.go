@@ -0,0 +1,83 @@
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+)
+
+// File names are encrypted deterministically, so the same name always maps to the same encrypted name and a file
+// can be found without a directory index. The scheme follows SIV: the IV is an HMAC-SHA256 of the parent path and
+// the name, truncated to the AES block size, and the name is encrypted with AES-256-CTR under that IV. The IV doubles
+// as the authentication tag. Mixing in the parent path makes equal names in different directories encrypt
+// differently. The encrypted name is the base64url encoding of IV || cipher text.
+const (
+	nameIVLen = aes.BlockSize
+
+	// maxNameLen keeps the encoded name within the 255-byte limit of common file systems.
+	maxNameLen = 255/4*3 - nameIVLen
+)
+
+var (
+	ErrNameTooLong     = errors.New("file name is too long")
+	ErrInvalidFileName = errors.New("invalid encrypted file name")
+	nameEncoding       = base64.RawURLEncoding
+)
+
+type nameCipher struct {
+	macKey []byte
+	block  cipher.Block
+}
+
+func newNameCipher(macKey, encKey []byte) (*nameCipher, error) {
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &nameCipher{
+		macKey: macKey,
+		block:  block,
+	}, nil
+}
+
+func (n *nameCipher) iv(parent, name string) []byte {
+	mac := hmac.New(sha256.New, n.macKey)
+	mac.Write([]byte(parent))
+	mac.Write([]byte{0})
+	mac.Write([]byte(name))
+	return mac.Sum(nil)[:nameIVLen]
+}
+
+// encrypt encrypts one path element; parent is the plain path of its directory.
+func (n *nameCipher) encrypt(parent, name string) (string, error) {
+	if len(name) > maxNameLen {
+		return "", ErrNameTooLong
+	}
+
+	b := make([]byte, nameIVLen+len(name))
+	iv := n.iv(parent, name)
+	copy(b, iv)
+	cipher.NewCTR(n.block, iv).XORKeyStream(b[nameIVLen:], []byte(name))
+
+	return nameEncoding.EncodeToString(b), nil
+}
+
+func (n *nameCipher) decrypt(parent, encrypted string) (string, error) {
+	b, err := nameEncoding.DecodeString(encrypted)
+	if err != nil || len(b) < nameIVLen {
+		return "", ErrInvalidFileName
+	}
+
+	iv := b[:nameIVLen]
+	name := make([]byte, len(b)-nameIVLen)
+	cipher.NewCTR(n.block, iv).XORKeyStream(name, b[nameIVLen:])
+	if !hmac.Equal(iv, n.iv(parent, string(name))) {
+		return "", ErrInvalidFileName
+	}
+
+	return string(name), nil
+}
@@ -0,0 +1,321 @@
+// Package vault keeps files encrypted in a directory, one privacy stream per file, with encrypted file names. Each
+// file can be synced on its own, e.g. with rsync or git. The layout is
+//
+//	vault.json   the vault configuration: format version, KeyGen parameters, and the wrapped master key
+//	data/        the encrypted files, in encrypted directories mirroring the plain paths
+//
+// The master key is random and wrapped, as a privacy stream, with the passphrase and the configured KeyGen. File
+// contents and names use keys derived from the master key, so the passphrase is only stretched once per Open.
+// File sizes and the directory structure are not hidden, and files can be swapped by someone with write access to
+// the vault directory without being detected.
+package vault
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/hkdf"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gitea.suyono.dev/suyono/simple-privacy-tool/privacy"
+)
+
+const (
+	configName = "vault.json"
+	dataDir    = "data"
+	version    = 1
+
+	masterKeyLen  = 32
+	derivedKeyLen = 32
+
+	contentKeyInfo = "simple-privacy-tool vault content"
+	nameMacInfo    = "simple-privacy-tool vault name mac"
+	nameEncInfo    = "simple-privacy-tool vault name encryption"
+)
+
+var (
+	ErrNotVault           = errors.New("not a vault")
+	ErrExists             = errors.New("vault exists")
+	ErrUnsupportedVersion = errors.New("unsupported vault version")
+	ErrInvalidName        = errors.New("invalid file name")
+)
+
+type config struct {
+	Version int             `json:"version"`
+	KeyGen  json.RawMessage `json:"keygen"`
+	Key     string          `json:"key"`
+}
+
+type Vault struct {
+	dir        string
+	contentKey string
+	names      *nameCipher
+}
+
+// Init creates a new vault in dir, which may exist but must not be a vault already.
+func Init(dir, passphrase string, keygen privacy.KeyGen) (err error) {
+	var (
+		cfg     config
+		wrapped []byte
+		b       []byte
+	)
+
+	if _, err = os.Stat(filepath.Join(dir, configName)); err == nil {
+		return ErrExists
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+
+	master := make([]byte, masterKeyLen)
+	if _, err = rand.Read(master); err != nil {
+		return
+	}
+
+	if wrapped, err = privacy.EncryptBytes(master, privacy.WithPassphrase(passphrase), privacy.WithKeyGen(keygen)); err != nil {
+		return
+	}
+
+	cfg.Version = version
+	if cfg.KeyGen, err = keygen.MarshalJSON(); err != nil {
+		return
+	}
+	cfg.Key = base64.StdEncoding.EncodeToString(wrapped)
+
+	if b, err = json.MarshalIndent(&cfg, "", "  "); err != nil {
+		return
+	}
+
+	if err = os.MkdirAll(filepath.Join(dir, dataDir), 0700); err != nil {
+		return
+	}
+
+	return os.WriteFile(filepath.Join(dir, configName), append(b, '\n'), 0600)
+}
+
+// Open unlocks the vault in dir. A wrong passphrase is reported as privacy.ErrWrongPassphrase.
+func Open(dir, passphrase string) (v *Vault, err error) {
+	var (
+		cfg     config
+		b       []byte
+		keygen  privacy.KeyGen
+		wrapped []byte
+		master  []byte
+	)
+
+	if b, err = os.ReadFile(filepath.Join(dir, configName)); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNotVault
+		}
+		return
+	}
+
+	if err = json.Unmarshal(b, &cfg); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotVault, err)
+	}
+
+	if cfg.Version != version {
+		return nil, ErrUnsupportedVersion
+	}
+
+	if keygen, err = privacy.ParseKeyGen(cfg.KeyGen); err != nil {
+		return
+	}
+
+	if wrapped, err = base64.StdEncoding.DecodeString(cfg.Key); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotVault, err)
+	}
+
+	if master, err = privacy.DecryptBytes(wrapped, privacy.WithPassphrase(passphrase), privacy.WithKeyGen(keygen)); err != nil {
+		return
+	}
+
+	return newVault(dir, master)
+}
+
+func newVault(dir string, master []byte) (v *Vault, err error) {
+	var (
+		keys [3][]byte
+	)
+
+	for i, info := range []string{contentKeyInfo, nameMacInfo, nameEncInfo} {
+		keys[i] = make([]byte, derivedKeyLen)
+		if _, err = io.ReadFull(hkdf.New(sha256.New, master, nil, []byte(info)), keys[i]); err != nil {
+			return
+		}
+	}
+
+	v = &Vault{
+		dir:        dir,
+		contentKey: string(keys[0]),
+	}
+
+	if v.names, err = newNameCipher(keys[1], keys[2]); err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+// encryptPath maps a plain slash-separated path to the encrypted path in the data directory.
+func (v *Vault) encryptPath(name string) (p string, err error) {
+	var (
+		enc string
+	)
+
+	if !fs.ValidPath(name) || name == "." {
+		return "", ErrInvalidName
+	}
+
+	p = filepath.Join(v.dir, dataDir)
+	parent := ""
+	for _, elem := range strings.Split(name, "/") {
+		if enc, err = v.names.encrypt(parent, elem); err != nil {
+			return
+		}
+		p = filepath.Join(p, enc)
+		parent = path.Join(parent, elem)
+	}
+
+	return
+}
+
+// Add encrypts src and stores it as name, replacing an existing file. name is a slash-separated path.
+func (v *Vault) Add(name string, src io.Reader) (err error) {
+	var (
+		p   string
+		tmp *os.File
+	)
+
+	if p, err = v.encryptPath(name); err != nil {
+		return
+	}
+
+	if err = os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return
+	}
+
+	// encrypted names never start with a dot, so List skips a leftover temporary file
+	if tmp, err = os.CreateTemp(filepath.Dir(p), ".add-*"); err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	if err = privacy.Encrypt(tmp, src, privacy.WithPassphrase(v.contentKey), privacy.WithKeyGen(privacy.NewHKDF())); err != nil {
+		return
+	}
+
+	if err = tmp.Close(); err != nil {
+		return
+	}
+
+	return os.Rename(tmp.Name(), p)
+}
+
+// Get decrypts the file stored as name into dst.
+func (v *Vault) Get(name string, dst io.Writer) (err error) {
+	var (
+		p   string
+		src *os.File
+	)
+
+	if p, err = v.encryptPath(name); err != nil {
+		return
+	}
+
+	if src, err = os.Open(p); err != nil {
+		return &fs.PathError{Op: "get", Path: name, Err: unwrapPathError(err)}
+	}
+	defer func() {
+		_ = src.Close()
+	}()
+
+	return privacy.Decrypt(dst, src, privacy.WithPassphrase(v.contentKey), privacy.WithKeyGen(privacy.NewHKDF()))
+}
+
+// Remove deletes the file stored as name, and the directories it leaves empty.
+func (v *Vault) Remove(name string) (err error) {
+	var (
+		p string
+	)
+
+	if p, err = v.encryptPath(name); err != nil {
+		return
+	}
+
+	if err = os.Remove(p); err != nil {
+		return &fs.PathError{Op: "remove", Path: name, Err: unwrapPathError(err)}
+	}
+
+	root := filepath.Join(v.dir, dataDir)
+	for dir := filepath.Dir(p); dir != root; dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+
+	return nil
+}
+
+// List returns the plain paths of all files in the vault, sorted.
+func (v *Vault) List() (names []string, err error) {
+	if err = v.list(filepath.Join(v.dir, dataDir), "", &names); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(names)
+	return
+}
+
+func (v *Vault) list(dir, parent string, names *[]string) (err error) {
+	var (
+		entries []os.DirEntry
+		name    string
+	)
+
+	if entries, err = os.ReadDir(dir); err != nil {
+		return
+	}
+
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+
+		if name, err = v.names.decrypt(parent, e.Name()); err != nil {
+			return fmt.Errorf("%s: %w", filepath.Join(dir, e.Name()), err)
+		}
+
+		if e.IsDir() {
+			if err = v.list(filepath.Join(dir, e.Name()), path.Join(parent, name), names); err != nil {
+				return
+			}
+		} else {
+			*names = append(*names, path.Join(parent, name))
+		}
+	}
+
+	return nil
+}
+
+func unwrapPathError(err error) error {
+	var pe *fs.PathError
+	if errors.As(err, &pe) {
+		return pe.Err
+	}
+
+	return err
+}
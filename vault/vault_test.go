@@ -0,0 +1,138 @@
+package vault
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gitea.suyono.dev/suyono/simple-privacy-tool/privacy"
+)
+
+func TestVault(t *testing.T) {
+	keygen, err := privacy.NewArgon2WithParams(1, 4*1024, 2)
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	dir := t.TempDir()
+	if err = Init(dir, "some passphrase", keygen); err != nil {
+		t.Fatal("unexpected: Init failed", err)
+	}
+	if err = Init(dir, "some passphrase", keygen); err != ErrExists {
+		t.Fatal("unexpected: it should return ErrExists, got", err)
+	}
+
+	if _, err = Open(dir, "wrong passphrase"); !errors.Is(err, privacy.ErrWrongPassphrase) {
+		t.Fatal("unexpected: it should return ErrWrongPassphrase, got", err)
+	}
+	if _, err = Open(t.TempDir(), "some passphrase"); err != ErrNotVault {
+		t.Fatal("unexpected: it should return ErrNotVault, got", err)
+	}
+
+	v, err := Open(dir, "some passphrase")
+	if err != nil {
+		t.Fatal("unexpected: Open failed", err)
+	}
+
+	files := map[string]string{
+		"notes.txt":         "some notes",
+		"keys/id_ed25519":   "private key",
+		"keys/ssh/config":   "Host *",
+		"other/keys/config": "another config",
+	}
+	for name, content := range files {
+		if err = v.Add(name, strings.NewReader(content)); err != nil {
+			t.Fatal("unexpected: Add failed", err)
+		}
+	}
+	if err = v.Add("notes.txt", strings.NewReader("new notes")); err != nil {
+		t.Fatal("unexpected: Add failed", err)
+	}
+	files["notes.txt"] = "new notes"
+
+	// reopen, the names and contents only depend on the passphrase
+	if v, err = Open(dir, "some passphrase"); err != nil {
+		t.Fatal("unexpected: Open failed", err)
+	}
+
+	names, err := v.List()
+	if err != nil {
+		t.Fatal("unexpected: List failed", err)
+	}
+	if !reflect.DeepEqual(names, []string{"keys/id_ed25519", "keys/ssh/config", "notes.txt", "other/keys/config"}) {
+		t.Fatal("unexpected: List", names)
+	}
+
+	for name, content := range files {
+		var buf bytes.Buffer
+		if err = v.Get(name, &buf); err != nil || buf.String() != content {
+			t.Fatal("unexpected: Get", name, buf.String(), err)
+		}
+	}
+
+	err = filepath.WalkDir(filepath.Join(dir, dataDir), func(p string, d fs.DirEntry, err error) error {
+		for _, plain := range []string{"keys", "notes", "config", "ssh"} {
+			if strings.Contains(d.Name(), plain) {
+				t.Error("unexpected: plain name in the vault", p)
+			}
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal("unexpected: WalkDir failed", err)
+	}
+
+	if err = v.Remove("keys/ssh/config"); err != nil {
+		t.Fatal("unexpected: Remove failed", err)
+	}
+	if err = v.Get("keys/ssh/config", &bytes.Buffer{}); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("unexpected: it should return fs.ErrNotExist, got", err)
+	}
+	if err = v.Remove("keys/ssh/config"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("unexpected: it should return fs.ErrNotExist, got", err)
+	}
+	keysDir, _ := v.encryptPath("keys")
+	if entries, _ := os.ReadDir(keysDir); len(entries) != 1 {
+		t.Fatal("unexpected: empty directories are not removed", entries)
+	}
+
+	for _, name := range []string{"", ".", "../x", "/abs", "a//b", strings.Repeat("x", maxNameLen+1)} {
+		if err = v.Add(name, strings.NewReader("x")); err == nil {
+			t.Fatal("unexpected: Add should fail for", name)
+		}
+	}
+}
+
+func TestNameCipher(t *testing.T) {
+	n, err := newNameCipher(bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 32))
+	if err != nil {
+		t.Fatal("test preparation failure:", err)
+	}
+
+	a, _ := n.encrypt("", "name")
+	b, _ := n.encrypt("", "name")
+	c, _ := n.encrypt("dir", "name")
+	if a != b || a == c {
+		t.Fatal("unexpected: names should be deterministic and depend on the parent")
+	}
+
+	long, err := n.encrypt("", strings.Repeat("x", maxNameLen))
+	if err != nil || len(long) > 255 {
+		t.Fatal("unexpected: long name", len(long), err)
+	}
+
+	if name, err := n.decrypt("", a); err != nil || name != "name" {
+		t.Fatal("unexpected: decrypt", name, err)
+	}
+	if _, err = n.decrypt("other", a); err != ErrInvalidFileName {
+		t.Fatal("unexpected: it should return ErrInvalidFileName, got", err)
+	}
+	if _, err = n.decrypt("", "not-encrypted"); err != ErrInvalidFileName {
+		t.Fatal("unexpected: it should return ErrInvalidFileName, got", err)
+	}
+}